@@ -0,0 +1,68 @@
+// riconfig renders a configuration registry (as written by
+// ricommon.WriteConfigRegistryJson) as documentation and checks environments
+// against it.
+//
+//     riconfig -registry registry.json markdown
+//     riconfig -registry registry.json env
+//     riconfig -registry registry.json schema
+//     riconfig -registry registry.json check [-env-file .env] [-prefixes APP_,RI_]
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+func main() {
+    os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run Run the command and return the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+    fs := flag.NewFlagSet("riconfig", flag.ContinueOnError)
+    fs.SetOutput(stderr)
+
+    registryFilepath := fs.String("registry", "", "Registry JSON file (\"-\" for STDIN)")
+
+    fs.Usage = func() {
+        fmt.Fprintf(stderr, "Usage: riconfig -registry <file> (markdown|env|schema|json|check) [options]\n")
+        fs.PrintDefaults()
+    }
+
+    if err := fs.Parse(args); err != nil {
+        return 2
+    } else if *registryFilepath == "" || fs.NArg() == 0 {
+        fs.Usage()
+        return 2
+    }
+
+    r := stdin
+    if *registryFilepath != "-" {
+        f, err := os.Open(*registryFilepath)
+        if err != nil {
+            fmt.Fprintf(stderr, "Could not open registry: %s\n", err)
+            return 1
+        }
+
+        defer f.Close()
+
+        r = f
+    }
+
+    declarations, err := ricommon.ReadConfigRegistryJson(r)
+    if err != nil {
+        fmt.Fprintf(stderr, "Could not read registry: %s\n", err)
+        return 1
+    }
+
+    if err := ricommon.RunConfigCommand(fs.Args(), declarations, stdout); err != nil {
+        fmt.Fprintf(stderr, "%s\n", err)
+        return 1
+    }
+
+    return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "strings"
+    "testing"
+
+    "io/ioutil"
+    "path/filepath"
+)
+
+const (
+    testRegistry = `[{"name": "APP_TOKEN", "type": "secret", "required": true}]`
+)
+
+func TestRun_Stdin(t *testing.T) {
+    stdout := new(bytes.Buffer)
+    stderr := new(bytes.Buffer)
+
+    status := run([]string { "-registry", "-", "env" }, strings.NewReader(testRegistry), stdout, stderr)
+    if status != 0 {
+        t.Fatalf("Status not correct: (%d) [%s]", status, stderr.String())
+    } else if stdout.String() != "# (secret)\nAPP_TOKEN=\n" {
+        t.Fatalf("Output not correct: [%s]", stdout.String())
+    }
+}
+
+func TestRun_File(t *testing.T) {
+    path, err := ioutil.TempDir("", "riconfig")
+    if err != nil {
+        t.Fatalf("Temporary directory not created: %s", err)
+    }
+
+    defer os.RemoveAll(path)
+
+    registryFilepath := filepath.Join(path, "registry.json")
+    if err := ioutil.WriteFile(registryFilepath, []byte(testRegistry), 0644); err != nil {
+        t.Fatalf("Registry not written: %s", err)
+    }
+
+    envFilepath := filepath.Join(path, ".env")
+    if err := ioutil.WriteFile(envFilepath, []byte("APP_TOKEN=\n"), 0644); err != nil {
+        t.Fatalf("Environment not written: %s", err)
+    }
+
+    stdout := new(bytes.Buffer)
+    stderr := new(bytes.Buffer)
+
+    status := run([]string { "-registry", registryFilepath, "check", "-env-file", envFilepath }, nil, stdout, stderr)
+    if status != 1 {
+        t.Fatalf("Status not correct: (%d)", status)
+    } else if stdout.String() != "MISSING APP_TOKEN\n" {
+        t.Fatalf("Output not correct: [%s]", stdout.String())
+    }
+}
+
+func TestRun_Usage(t *testing.T) {
+    argsList := [][]string {
+        {},
+        { "markdown" },
+        { "-registry", "-" },
+        { "-unknown" },
+    }
+
+    for _, args := range argsList {
+        stderr := new(bytes.Buffer)
+
+        if status := run(args, strings.NewReader(testRegistry), new(bytes.Buffer), stderr); status != 2 {
+            t.Fatalf("Status for %v not correct: (%d)", args, status)
+        } else if strings.Contains(stderr.String(), "Usage:") != true {
+            t.Fatalf("Usage for %v not printed: [%s]", args, stderr.String())
+        }
+    }
+
+    stderr := new(bytes.Buffer)
+    if status := run([]string { "-registry", "missing.json", "markdown" }, nil, new(bytes.Buffer), stderr); status != 1 {
+        t.Fatalf("Status for a missing registry not correct: (%d)", status)
+    }
+}
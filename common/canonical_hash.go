@@ -0,0 +1,272 @@
+package ricommon
+
+// Canonical encoding
+//
+// The gob encoding embeds type-registration details and depends on map
+// iteration order, so it can't be reproduced by other processes or languages.
+// The canonical encoding can. Every value is a one-byte type tag followed by
+// its payload. Lengths and counts are unsigned 64-bit big-endian integers.
+//
+//     'N'  nil (no payload)
+//     'T'  true (no payload)
+//     'F'  false (no payload)
+//     'i'  integer: length, then the ASCII decimal value ("-12", "0", "42")
+//     'f'  float: the 8-byte big-endian IEEE-754 binary64 value
+//     's'  string: length, then the UTF-8 bytes
+//     'b'  bytes: length, then the bytes
+//     't'  time: length, then the UTC RFC 3339 value with nanoseconds
+//          (time.RFC3339Nano)
+//     'l'  list: count, then each element
+//     'm'  map: count, then each key followed by its value, sorted by the
+//          bytewise order of the encoded keys
+//
+// Numbers are normalized: integers are the same regardless of width or
+// signedness, and floats with integral values (within +/-2^53) are encoded as
+// integers, so 5, uint8(5), and 5.0 are all encoded as 'i', a length of one,
+// and "5". Negative zero is zero. NaN and the infinities are not allowed.
+//
+// Pointers and interfaces are encoded as what they point to (or nil). Arrays
+// and slices are lists, except for []byte. Structs are maps of their exported
+// field names (or the name in the "hash" tag) to their values, so a struct
+// hashes the same as the equivalent map. Fields tagged `hash:"-"` are skipped.
+// Channels, functions, and complex numbers can't be encoded.
+
+import (
+    "bytes"
+    "fmt"
+    "hash"
+    "io"
+    "math"
+    "reflect"
+    "sort"
+    "strconv"
+    "time"
+
+    "encoding/binary"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Canonical type tags
+const (
+    CanonicalTagNil = 'N'
+    CanonicalTagTrue = 'T'
+    CanonicalTagFalse = 'F'
+    CanonicalTagInteger = 'i'
+    CanonicalTagFloat = 'f'
+    CanonicalTagString = 's'
+    CanonicalTagBytes = 'b'
+    CanonicalTagTime = 't'
+    CanonicalTagList = 'l'
+    CanonicalTagMap = 'm'
+)
+
+// Constants
+const (
+    // The struct tag that renames or (with "-") skips a field.
+    CanonicalTagName = "hash"
+
+    // Floats with integral values up to this magnitude are encoded as
+    // integers.
+    canonicalMaxExactFloat = 1 << 53
+)
+
+// Other
+var (
+    timeType = reflect.TypeOf(time.Time{})
+)
+
+// EncodeCanonical Return the canonical encoding of the value.
+func EncodeCanonical(value interface{}) (encoded []byte, err error) {
+    b := new(bytes.Buffer)
+
+    err = WriteCanonical(b, value)
+    if err != nil {
+        return nil, err
+    }
+
+    return b.Bytes(), nil
+}
+
+// WriteCanonical Write the canonical encoding of the value.
+func WriteCanonical(w io.Writer, value interface{}) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    writeCanonicalValue(w, reflect.ValueOf(value))
+    return nil
+}
+
+// EncodeToCanonicalDigestString Return the self-describing digest of the
+// canonical encoding of the parts (as a list). Unlike EncodeToDigestString,
+// this is stable across processes, Go versions, and languages.
+func EncodeToCanonicalDigestString(algorithm string, parts []interface{}) (digest string) {
+    h := newHasherOrPanic(algorithm)
+
+    err := WriteCanonical(h, parts)
+    log.PanicIf(err)
+
+    return FormatDigest(algorithm, h.Sum(nil))
+}
+
+// VerifyCanonicalDigestString Return whether the digest matches the canonical
+// encoding of the parts. Digests that use non-cryptographic algorithms are
+// rejected.
+func VerifyCanonicalDigestString(digest string, parts []interface{}) (ok bool, err error) {
+    return verifyDigest(digest, nil, func(h hash.Hash) {
+        err := WriteCanonical(h, parts)
+        log.PanicIf(err)
+    })
+}
+
+func writeCanonicalValue(w io.Writer, v reflect.Value) {
+    if v.IsValid() == false {
+        writeCanonicalBytes(w, []byte { CanonicalTagNil })
+        return
+    }
+
+    if v.Type() == timeType {
+        t := v.Interface().(time.Time)
+        writeCanonicalPayload(w, CanonicalTagTime, []byte(t.UTC().Format(time.RFC3339Nano)))
+
+        return
+    }
+
+    switch v.Kind() {
+    case reflect.Ptr, reflect.Interface:
+        if v.IsNil() == true {
+            writeCanonicalBytes(w, []byte { CanonicalTagNil })
+        } else {
+            writeCanonicalValue(w, v.Elem())
+        }
+    case reflect.Bool:
+        if v.Bool() == true {
+            writeCanonicalBytes(w, []byte { CanonicalTagTrue })
+        } else {
+            writeCanonicalBytes(w, []byte { CanonicalTagFalse })
+        }
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        writeCanonicalPayload(w, CanonicalTagInteger, []byte(strconv.FormatInt(v.Int(), 10)))
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        writeCanonicalPayload(w, CanonicalTagInteger, []byte(strconv.FormatUint(v.Uint(), 10)))
+    case reflect.Float32, reflect.Float64:
+        writeCanonicalFloat(w, v.Float())
+    case reflect.String:
+        writeCanonicalPayload(w, CanonicalTagString, []byte(v.String()))
+    case reflect.Slice, reflect.Array:
+        if v.Type().Elem().Kind() == reflect.Uint8 {
+            raw := make([]byte, v.Len())
+            reflect.Copy(reflect.ValueOf(raw), v)
+
+            writeCanonicalPayload(w, CanonicalTagBytes, raw)
+            return
+        }
+
+        writeCanonicalHeader(w, CanonicalTagList, uint64(v.Len()))
+        for i := 0; i < v.Len(); i++ {
+            writeCanonicalValue(w, v.Index(i))
+        }
+    case reflect.Map:
+        entries := make([][2][]byte, 0, v.Len())
+        for _, key := range v.MapKeys() {
+            entries = append(entries, [2][]byte {
+                encodeCanonicalValue(key),
+                encodeCanonicalValue(v.MapIndex(key)),
+            })
+        }
+
+        writeCanonicalEntries(w, entries)
+    case reflect.Struct:
+        t := v.Type()
+        entries := make([][2][]byte, 0, t.NumField())
+
+        for i := 0; i < t.NumField(); i++ {
+            sf := t.Field(i)
+            if sf.PkgPath != "" {
+                continue
+            }
+
+            name := sf.Tag.Get(CanonicalTagName)
+            if name == "-" {
+                continue
+            } else if name == "" {
+                name = sf.Name
+            }
+
+            entries = append(entries, [2][]byte {
+                encodeCanonicalValue(reflect.ValueOf(name)),
+                encodeCanonicalValue(v.Field(i)),
+            })
+        }
+
+        writeCanonicalEntries(w, entries)
+    default:
+        log.Panic(fmt.Errorf("type can not be canonically encoded: [%s]", v.Type()))
+    }
+}
+
+func encodeCanonicalValue(v reflect.Value) []byte {
+    b := new(bytes.Buffer)
+    writeCanonicalValue(b, v)
+
+    return b.Bytes()
+}
+
+func writeCanonicalFloat(w io.Writer, f float64) {
+    if math.IsNaN(f) == true || math.IsInf(f, 0) == true {
+        log.Panic(fmt.Errorf("float can not be canonically encoded: [%v]", f))
+    }
+
+    if f == math.Trunc(f) && math.Abs(f) <= canonicalMaxExactFloat {
+        // This also turns negative zero into zero.
+        writeCanonicalPayload(w, CanonicalTagInteger, []byte(strconv.FormatInt(int64(f), 10)))
+        return
+    }
+
+    raw := make([]byte, 8)
+    binary.BigEndian.PutUint64(raw, math.Float64bits(f))
+
+    writeCanonicalBytes(w, []byte { CanonicalTagFloat })
+    writeCanonicalBytes(w, raw)
+}
+
+// writeCanonicalEntries Write the encoded key-value pairs, sorted by key.
+func writeCanonicalEntries(w io.Writer, entries [][2][]byte) {
+    sort.Slice(entries, func(i, j int) bool {
+        return bytes.Compare(entries[i][0], entries[j][0]) < 0
+    })
+
+    for i := 1; i < len(entries); i++ {
+        if bytes.Equal(entries[i - 1][0], entries[i][0]) == true {
+            log.Panic(fmt.Errorf("map has keys that are canonically equal"))
+        }
+    }
+
+    writeCanonicalHeader(w, CanonicalTagMap, uint64(len(entries)))
+    for _, entry := range entries {
+        writeCanonicalBytes(w, entry[0])
+        writeCanonicalBytes(w, entry[1])
+    }
+}
+
+func writeCanonicalPayload(w io.Writer, tag byte, payload []byte) {
+    writeCanonicalHeader(w, tag, uint64(len(payload)))
+    writeCanonicalBytes(w, payload)
+}
+
+func writeCanonicalHeader(w io.Writer, tag byte, n uint64) {
+    header := make([]byte, 9)
+    header[0] = tag
+    binary.BigEndian.PutUint64(header[1:], n)
+
+    writeCanonicalBytes(w, header)
+}
+
+func writeCanonicalBytes(w io.Writer, raw []byte) {
+    if _, err := w.Write(raw); err != nil {
+        log.Panic(err)
+    }
+}
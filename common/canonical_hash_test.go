@@ -0,0 +1,137 @@
+package ricommon
+
+import (
+    "testing"
+    "time"
+
+    "encoding/hex"
+)
+
+// canonicalHashVector A known value and its encoding and digest. Other
+// implementations should reproduce all of these.
+type canonicalHashVector struct {
+    Description string
+    Value interface{}
+
+    // Hex of the canonical encoding.
+    Encoding string
+
+    // EncodeToCanonicalDigestString(HashAlgorithmSha256, []interface{} { Value })
+    Digest string
+}
+
+// canonicalHashVectors The golden vectors for the canonical encoding.
+var canonicalHashVectors = []canonicalHashVector {
+    {
+        Description: "nil",
+        Value: nil,
+        Encoding: "4e",
+        Digest: "sha256:9818deca3da78caabc09ec36dac8e945c2d1c06bd19d0097403425e944a4d7a5",
+    },
+    {
+        Description: "true",
+        Value: true,
+        Encoding: "54",
+        Digest: "sha256:72126b28917dae150dd4f9cfecd86e71944cd92554fba1306eb5bae712cdb432",
+    },
+    {
+        Description: "negative integer",
+        Value: int8(-12),
+        Encoding: "6900000000000000032d3132",
+        Digest: "sha256:b0cd60a2563ef75073d39c91c788801f6b90d3817f8ae2e09c9c15d5b60fc979",
+    },
+    {
+        Description: "integral float is an integer",
+        Value: 42.0,
+        Encoding: "6900000000000000023432",
+        Digest: "sha256:60052b1e663d3190983901d3711147b630a3ef2f723426a241f6108ccd621d4b",
+    },
+    {
+        Description: "non-integral float",
+        Value: 0.5,
+        Encoding: "663fe0000000000000",
+        Digest: "sha256:f93b5911afe7d6995402b48ae21f903aa9be7640e1047e8d303818a224f460c6",
+    },
+    {
+        Description: "string",
+        Value: "héllo",
+        Encoding: "73000000000000000668c3a96c6c6f",
+        Digest: "sha256:8b693cbe33fe1526da35b4e338e8c9365fc20208ad3df711c31babfdbaf017c9",
+    },
+    {
+        Description: "bytes",
+        Value: []byte { 0x00, 0xff },
+        Encoding: "62000000000000000200ff",
+        Digest: "sha256:4d2cffbe393ed0a64355e8e9103189d1a8a8464ca973cbd7d4fa4ecf693f2f34",
+    },
+    {
+        Description: "time is normalized to UTC",
+        Value: time.Date(2020, 2, 29, 13, 30, 0, 500, time.FixedZone("", 3600)),
+        Encoding: "74000000000000001c323032302d30322d32395431323a33303a30302e303030303030355a",
+        Digest: "sha256:396cf15204e4847eb484f9886625736bf8b9b9547602603f081f6c19bd5f6e5d",
+    },
+    {
+        Description: "list",
+        Value: []interface{} { 1, "a", nil },
+        Encoding: "6c000000000000000369000000000000000131730000000000000001614e",
+        Digest: "sha256:f8f1d3e6a59b5a1cc4b0b06afe4873071c14480870bdfebbbad139327171eafe",
+    },
+    {
+        Description: "map keys are sorted",
+        Value: map[string]interface{} { "b": 2, "a": 1 },
+        Encoding: "6d000000000000000273000000000000000161690000000000000001317300000000000000016269000000000000000132",
+        Digest: "sha256:463479e41e6e7b495b18ae97c2b3c41b8e0ba4c0abfbd809967453bc7dae251d",
+    },
+}
+
+func TestEncodeCanonical_Vectors(t *testing.T) {
+    for _, chv := range canonicalHashVectors {
+        encoded, err := EncodeCanonical(chv.Value)
+        if err != nil {
+            t.Fatalf("Could not encode [%s]: %s", chv.Description, err)
+        }
+
+        if actual := hex.EncodeToString(encoded); actual != chv.Encoding {
+            t.Fatalf("Canonical encoding of [%s] is [%s] but should be [%s].", chv.Description, actual, chv.Encoding)
+        }
+    }
+}
+
+func TestEncodeToCanonicalDigestString_Vectors(t *testing.T) {
+    for _, chv := range canonicalHashVectors {
+        parts := []interface{} { chv.Value }
+
+        if actual := EncodeToCanonicalDigestString(HashAlgorithmSha256, parts); actual != chv.Digest {
+            t.Fatalf("Canonical digest of [%s] is [%s] but should be [%s].", chv.Description, actual, chv.Digest)
+        }
+
+        ok, err := VerifyCanonicalDigestString(chv.Digest, parts)
+        if err != nil {
+            t.Fatalf("Could not verify [%s]: %s", chv.Description, err)
+        } else if ok != true {
+            t.Fatalf("Digest of [%s] did not verify.", chv.Description)
+        }
+    }
+}
+
+func TestEncodeCanonical_StructMatchesMap(t *testing.T) {
+    type record struct {
+        A int
+        B string `hash:"b"`
+        Skipped string `hash:"-"`
+    }
+
+    fromStruct, err := EncodeCanonical(record{ A: 1, B: "x", Skipped: "y" })
+    if err != nil {
+        t.Fatalf("Could not encode struct: %s", err)
+    }
+
+    fromMap, err := EncodeCanonical(map[string]interface{} { "A": 1, "b": "x" })
+    if err != nil {
+        t.Fatalf("Could not encode map: %s", err)
+    }
+
+    if hex.EncodeToString(fromStruct) != hex.EncodeToString(fromMap) {
+        t.Fatalf("Struct and map encodings differ.")
+    }
+}
@@ -11,6 +11,8 @@ import (
 )
 
 func GetConfigValueString(name string) string {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeString, Required: true })
+
     value := os.Getenv(name)
     if value == "" {
         log.Panic(fmt.Errorf("string configuration value not found: [%s]", name))
@@ -20,6 +22,8 @@ func GetConfigValueString(name string) string {
 }
 
 func GetConfigValueStringWithDefault(name string, defaultValue string) string {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeString, Default: defaultValue })
+
     value := os.Getenv(name)
     if value == "" {
         value = defaultValue
@@ -29,6 +33,8 @@ func GetConfigValueStringWithDefault(name string, defaultValue string) string {
 }
 
 func GetConfigValueStringSlice(name string, separator string, forceToLower bool) []string {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeStringSlice, Required: true })
+
     values := GetConfigValueStringSliceWithDefault(name, separator, forceToLower, []string {})
 
     if len(values) == 0 {
@@ -45,6 +51,8 @@ func GetConfigValueStringSlice(name string, separator string, forceToLower bool)
 }
 
 func GetConfigValueStringSliceWithDefault(name string, separator string, forceToLower bool, defaultValues []string) []string {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeStringSlice, Default: strings.Join(defaultValues, separator) })
+
     value := os.Getenv(name)
     var values []string
     
@@ -58,6 +66,8 @@ func GetConfigValueStringSliceWithDefault(name string, separator string, forceTo
 }
 
 func GetConfigValueInt32(name string) int32 {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeInt32, Required: true })
+
     valueRaw := GetConfigValueString(name)
 
     value, err := strconv.ParseInt(valueRaw, 10, 32)
@@ -71,6 +81,8 @@ func GetConfigValueInt32(name string) int32 {
 // TODO(dustin): Adopt these "default" functions where we should already be using them.
 
 func GetConfigValueInt32WithDefault(name string, defaultValue int32) int32 {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeInt32, Default: fmt.Sprintf("%d", defaultValue) })
+
     valueRaw := GetConfigValueStringWithDefault(name, "")
 
     if valueRaw == "" {
@@ -86,10 +98,14 @@ func GetConfigValueInt32WithDefault(name string, defaultValue int32) int32 {
 }
 
 func GetConfigValueDuration(name string) time.Duration {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeDurationSeconds, Required: true })
+
     return time.Duration(GetConfigValueInt32(name)) * time.Second
 }
 
 func GetConfigValueDurationWithDefault(name string, defaultValue time.Duration) time.Duration {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeDurationSeconds, Default: fmt.Sprintf("%d", int64(defaultValue / time.Second)) })
+
     valueRaw := GetConfigValueStringWithDefault(name, "")
     if valueRaw == "" {
         return defaultValue
@@ -104,6 +120,8 @@ func GetConfigValueDurationWithDefault(name string, defaultValue time.Duration)
 }
 
 func GetConfigValueInt64(name string) int64 {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeInt64, Required: true })
+
     valueRaw := GetConfigValueString(name)
 
     value, err := strconv.ParseInt(valueRaw, 10, 64)
@@ -115,6 +133,8 @@ func GetConfigValueInt64(name string) int64 {
 }
 
 func GetConfigValueInt64WithDefault(name string, defaultValue int64) int64 {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeInt64, Default: fmt.Sprintf("%d", defaultValue) })
+
     valueRaw := GetConfigValueStringWithDefault(name, "")
 
     if valueRaw == "" {
@@ -130,6 +150,8 @@ func GetConfigValueInt64WithDefault(name string, defaultValue int64) int64 {
 }
 
 func GetConfigValueBool(name string) bool {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeBool, Required: true })
+
     valueRaw := GetConfigValueString(name)
 
     value, err := strconv.ParseBool(valueRaw)
@@ -141,6 +163,8 @@ func GetConfigValueBool(name string) bool {
 }
 
 func GetConfigValueBoolWithDefault(name string, defaultValue bool) bool {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeBool, Default: fmt.Sprintf("%t", defaultValue) })
+
     valueRaw := GetConfigValueStringWithDefault(name, "")
 
     if valueRaw == "" {
@@ -0,0 +1,288 @@
+package ricommon
+
+import (
+    "fmt"
+    "os"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "net/url"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Config-binding struct tags
+const (
+    // The name of the environment variable for the field.
+    ConfigTagName = "env"
+
+    // The value to use when the variable is empty or not set.
+    ConfigTagDefault = "default"
+
+    // "true" if the variable must be set (a default never satisfies this).
+    ConfigTagRequired = "required"
+
+    // The separator for slice values. Defaults to a comma.
+    ConfigTagSeparator = "separator"
+
+    // "true" to force string values to lowercase.
+    ConfigTagLowercase = "lowercase"
+
+    // The prefix applied to the variable names of a nested struct.
+    ConfigTagPrefix = "envPrefix"
+)
+
+// Other
+var (
+    durationType = reflect.TypeOf(time.Duration(0))
+    urlType = reflect.TypeOf(url.URL{})
+    secretType = reflect.TypeOf(Secret(""))
+)
+
+// ConfigProblem Describes one missing or malformed variable.
+type ConfigProblem struct {
+    Name string
+    Message string
+}
+
+// ConfigBindingError Describes every problem found while binding, so that
+// they can all be fixed at once.
+type ConfigBindingError struct {
+    Problems []ConfigProblem
+}
+
+func (cbe *ConfigBindingError) Error() string {
+    messages := make([]string, len(cbe.Problems))
+    for i, problem := range cbe.Problems {
+        messages[i] = fmt.Sprintf("[%s] %s", problem.Name, problem.Message)
+    }
+
+    return fmt.Sprintf("configuration not valid: %s", strings.Join(messages, "; "))
+}
+
+// BindConfigFromEnvironment Fill the struct pointed to by `output` from the
+// environment. See BindConfig.
+func BindConfigFromEnvironment(output interface{}) (err error) {
+    return BindConfig(output, os.LookupEnv)
+}
+
+// BindConfig Fill the struct pointed to by `output` using the variable names
+// and options in its field tags and the given lookup function. Fields without
+// an "env" tag are ignored unless they are structs, which are descended into.
+// Empty values are treated as missing, like the GetConfigValue* functions.
+// Durations may have unit suffixes ("1m30s") or be bare integers, which are
+// taken as seconds.
+//
+// If any variables were missing or malformed, a *ConfigBindingError listing
+// all of them is returned.
+func BindConfig(output interface{}, lookup func(name string) (string, bool)) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    v := reflect.ValueOf(output)
+    if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+        log.Panic(fmt.Errorf("config output must be a pointer to a struct: [%s]", v.Type()))
+    }
+
+    cbe := &ConfigBindingError{
+        Problems: make([]ConfigProblem, 0),
+    }
+
+    bindConfigStruct(v.Elem(), "", lookup, cbe)
+
+    if len(cbe.Problems) > 0 {
+        return cbe
+    }
+
+    return nil
+}
+
+func bindConfigStruct(v reflect.Value, prefix string, lookup func(name string) (string, bool), cbe *ConfigBindingError) {
+    t := v.Type()
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        fv := v.Field(i)
+
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        name := sf.Tag.Get(ConfigTagName)
+        if name == "" {
+            if nested, ok := nestedConfigStruct(fv); ok == true {
+                bindConfigStruct(nested, prefix + sf.Tag.Get(ConfigTagPrefix), lookup, cbe)
+            }
+
+            continue
+        }
+
+        name = prefix + name
+        declareBoundConfigField(name, sf)
+
+        raw, _ := lookup(name)
+        if raw == "" {
+            if sf.Tag.Get(ConfigTagRequired) == "true" {
+                cbe.Problems = append(cbe.Problems, ConfigProblem{ Name: name, Message: "missing" })
+                continue
+            }
+
+            raw = sf.Tag.Get(ConfigTagDefault)
+            if raw == "" {
+                continue
+            }
+        }
+
+        separator := sf.Tag.Get(ConfigTagSeparator)
+        if separator == "" {
+            separator = ","
+        }
+
+        lowercase := sf.Tag.Get(ConfigTagLowercase) == "true"
+
+        if err := setConfigValue(fv, raw, separator, lowercase, lookup); err != nil {
+            cbe.Problems = append(cbe.Problems, ConfigProblem{ Name: name, Message: err.Error() })
+        }
+    }
+}
+
+// nestedConfigStruct Return the struct to descend into, allocating it if the
+// field is a nil pointer.
+func nestedConfigStruct(fv reflect.Value) (nested reflect.Value, ok bool) {
+    if fv.Kind() == reflect.Struct && fv.Type() != urlType {
+        return fv, true
+    }
+
+    if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != urlType {
+        if fv.IsNil() == true {
+            fv.Set(reflect.New(fv.Type().Elem()))
+        }
+
+        return fv.Elem(), true
+    }
+
+    return reflect.Value{}, false
+}
+
+// setConfigValue Parse the raw value into the field. Secret references are
+// resolved with `lookup`.
+func setConfigValue(fv reflect.Value, raw string, separator string, lowercase bool, lookup func(name string) (string, bool)) (err error) {
+    if fv.Kind() == reflect.Slice {
+        parts := strings.Split(raw, separator)
+        slice := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+
+        for i, part := range parts {
+            if err := setConfigScalar(slice.Index(i), strings.TrimSpace(part), lowercase, lookup); err != nil {
+                return err
+            }
+        }
+
+        fv.Set(slice)
+        return nil
+    }
+
+    return setConfigScalar(fv, raw, lowercase, lookup)
+}
+
+// setConfigScalar Parse one value into the field. Secrets are lowercased after
+// their references are resolved so that the references aren't altered.
+func setConfigScalar(fv reflect.Value, raw string, lowercase bool, lookup func(name string) (string, bool)) (err error) {
+    if lowercase == true && fv.Type() != secretType {
+        raw = strings.ToLower(raw)
+    }
+
+    switch {
+    case fv.Type() == durationType:
+        duration, err := ParseConfigDuration(raw)
+        if err != nil {
+            return err
+        }
+
+        fv.SetInt(int64(duration))
+        return nil
+    case fv.Type() == secretType:
+        value, err := ResolveSecretReferenceWithLookup(raw, lookup)
+        if err != nil {
+            // The error may describe the value.
+            return fmt.Errorf("secret could not be resolved")
+        }
+
+        if lowercase == true {
+            value = strings.ToLower(value)
+        }
+
+        fv.SetString(value)
+        return nil
+    case fv.Type() == urlType || fv.Kind() == reflect.Ptr && fv.Type().Elem() == urlType:
+        u, err := url.Parse(raw)
+        if err != nil {
+            return fmt.Errorf("URL not valid: [%s]", raw)
+        }
+
+        if fv.Kind() == reflect.Ptr {
+            fv.Set(reflect.ValueOf(u))
+        } else {
+            fv.Set(reflect.ValueOf(*u))
+        }
+
+        return nil
+    }
+
+    switch fv.Kind() {
+    case reflect.String:
+        fv.SetString(raw)
+    case reflect.Bool:
+        value, err := strconv.ParseBool(raw)
+        if err != nil {
+            return fmt.Errorf("boolean not valid: [%s]", raw)
+        }
+
+        fv.SetBool(value)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        value, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("%d-bit integer not valid: [%s]", fv.Type().Bits(), raw)
+        }
+
+        fv.SetInt(value)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        value, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("%d-bit unsigned integer not valid: [%s]", fv.Type().Bits(), raw)
+        }
+
+        fv.SetUint(value)
+    case reflect.Float32, reflect.Float64:
+        value, err := strconv.ParseFloat(raw, fv.Type().Bits())
+        if err != nil {
+            return fmt.Errorf("float not valid: [%s]", raw)
+        }
+
+        fv.SetFloat(value)
+    default:
+        log.Panic(fmt.Errorf("config field type not supported: [%s]", fv.Type()))
+    }
+
+    return nil
+}
+
+// ParseConfigDuration Parse a duration with a unit suffix (e.g. "1m30s"). A
+// bare integer is taken as seconds to stay compatible with
+// GetConfigValueDuration.
+func ParseConfigDuration(raw string) (duration time.Duration, err error) {
+    if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+        return time.Duration(seconds) * time.Second, nil
+    }
+
+    duration, err = time.ParseDuration(raw)
+    if err != nil {
+        return 0, fmt.Errorf("duration not valid: [%s]", raw)
+    }
+
+    return duration, nil
+}
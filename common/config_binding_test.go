@@ -0,0 +1,66 @@
+package ricommon
+
+import (
+    "strings"
+    "testing"
+)
+
+type bindingTestConfig struct {
+    Mode string `env:"MODE" lowercase:"true"`
+    Password Secret `env:"PASSWORD" lowercase:"true"`
+    Token Secret `env:"TOKEN"`
+}
+
+func TestBindConfig_SecretReferences(t *testing.T) {
+    environ := map[string]string {
+        "MODE": "Fast",
+        "PASSWORD": "env:Db_Password",
+        "TOKEN": "env:TOKEN_VALUE",
+        "Db_Password": "Hunter2",
+        "TOKEN_VALUE": "AbC",
+    }
+
+    lookup := func(name string) (string, bool) {
+        value, found := environ[name]
+        return value, found
+    }
+
+    config := new(bindingTestConfig)
+
+    if err := BindConfig(config, lookup); err != nil {
+        t.Fatalf("Bind failed: %s", err)
+    }
+
+    // The reference is resolved through the lookup (not the process
+    // environment) before being lowercased.
+    if config.Mode != "fast" {
+        t.Fatalf("Mode not correct: [%s]", config.Mode)
+    } else if config.Password.Reveal() != "hunter2" {
+        t.Fatalf("Password not correct: [%s]", config.Password.Reveal())
+    } else if config.Token.Reveal() != "AbC" {
+        t.Fatalf("Token not correct: [%s]", config.Token.Reveal())
+    }
+}
+
+func TestBindConfig_SecretReferenceNotFound(t *testing.T) {
+    lookup := func(name string) (string, bool) {
+        if name == "TOKEN" {
+            return "env:MISSING_TOKEN", true
+        }
+
+        return "", false
+    }
+
+    err := BindConfig(new(bindingTestConfig), lookup)
+
+    cbe, ok := err.(*ConfigBindingError)
+    if ok != true || len(cbe.Problems) != 1 {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+
+    // Nothing about the reference is passed along.
+    problem := cbe.Problems[0]
+    if problem.Name != "TOKEN" || strings.Contains(problem.Message, "MISSING_TOKEN") == true {
+        t.Fatalf("Problem not correct: %v", problem)
+    }
+}
@@ -0,0 +1,531 @@
+package ricommon
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
+
+    "encoding"
+    "path/filepath"
+
+    "golang.org/x/net/context"
+    "gopkg.in/yaml.v2"
+    "github.com/dsoprea/go-logging"
+)
+
+// Config layers, from lowest to highest precedence
+const (
+    ConfigLayerDefault = "default"
+    ConfigLayerFile = "file"
+    ConfigLayerEnvironment = "environment"
+    ConfigLayerOverride = "override"
+)
+
+// Constants
+const (
+    // The top-level YAML key that lists other files to load first.
+    ConfigIncludeKey = "include"
+)
+
+// Other
+var (
+    textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+    binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// ConfigSource Describes where the value of a key came from.
+type ConfigSource struct {
+    // Layer One of the ConfigLayer* names.
+    Layer string
+
+    // Origin The file-path or variable name, where applicable.
+    Origin string
+}
+
+func (cs ConfigSource) String() string {
+    if cs.Origin == "" {
+        return cs.Layer
+    }
+
+    return fmt.Sprintf("%s:%s", cs.Layer, cs.Origin)
+}
+
+// LayeredConfigLoader Merges (in increasing order of precedence) the defaults
+// already set on the output struct, YAML files, environment variables, and
+// explicit overrides. Keys are the dotted YAML paths (e.g. "db.host").
+type LayeredConfigLoader struct {
+    filepaths []string
+    envPrefix string
+    overrides map[string]interface{}
+    lookup func(name string) (string, bool)
+}
+
+// NewLayeredConfigLoader Return a loader for the given files, which are applied
+// in order. Environment variables are named by upper-casing the key path,
+// replacing dots with underscores, and prepending `envPrefix` (e.g. "APP_" and
+// "db.host" gives "APP_DB_HOST"). Values for string fields are used as-is and
+// others are parsed as YAML into the field's type, so lists are given in
+// flow-syntax ("[a, b]").
+func NewLayeredConfigLoader(filepaths []string, envPrefix string) *LayeredConfigLoader {
+    return &LayeredConfigLoader{
+        filepaths: filepaths,
+        envPrefix: envPrefix,
+        overrides: make(map[string]interface{}),
+        lookup: os.LookupEnv,
+    }
+}
+
+// SetOverride Force the value of the given key, regardless of every other
+// layer.
+func (lcl *LayeredConfigLoader) SetOverride(keyPath string, value interface{}) {
+    lcl.overrides[keyPath] = value
+}
+
+// SetLookup Replace the function used to read environment variables.
+func (lcl *LayeredConfigLoader) SetLookup(lookup func(name string) (string, bool)) {
+    lcl.lookup = lookup
+}
+
+// Load Merge the layers into `output` and return the source of every key.
+func (lcl *LayeredConfigLoader) Load(ctx context.Context, output interface{}) (sources map[string]ConfigSource, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+            cuLogger.Errorf(ctx, nil, "Could not load layered config: [%s]", err)
+        }
+    }()
+
+    merged := make(map[string]interface{})
+    sources = make(map[string]ConfigSource)
+
+    // Defaults.
+
+    defaults := marshalConfigTree(output)
+    mergeConfigTree(merged, defaults, "", ConfigSource{ Layer: ConfigLayerDefault }, sources)
+
+    // Files.
+
+    for _, configFilepath := range lcl.filepaths {
+        lcl.mergeFile(ctx, merged, configFilepath, make(map[string]bool), sources)
+    }
+
+    // Environment.
+
+    t := reflect.TypeOf(output)
+    for _, keyPath := range ConfigKeyPaths(t) {
+        name := lcl.envPrefix + strings.ToUpper(strings.Replace(keyPath, ".", "_", -1))
+
+        raw, found := lcl.lookup(name)
+        if found == false || raw == "" {
+            continue
+        }
+
+        value, err := parseConfigEnvironmentValue(raw, configKeyPathType(t, keyPath))
+        if err != nil {
+            log.Panic(fmt.Errorf("environment variable [%s] not valid: %s", name, err))
+        }
+
+        setConfigTreeValue(merged, keyPath, value, ConfigSource{ Layer: ConfigLayerEnvironment, Origin: name }, sources)
+    }
+
+    // Overrides. Parents are applied before their children so that a more
+    // specific override always wins.
+
+    keyPaths := make([]string, 0, len(lcl.overrides))
+    for keyPath := range lcl.overrides {
+        keyPaths = append(keyPaths, keyPath)
+    }
+
+    sort.Slice(keyPaths, func(i, j int) bool {
+        iDepth := strings.Count(keyPaths[i], ".")
+        jDepth := strings.Count(keyPaths[j], ".")
+
+        if iDepth != jDepth {
+            return iDepth < jDepth
+        }
+
+        return keyPaths[i] < keyPaths[j]
+    })
+
+    for _, keyPath := range keyPaths {
+        setConfigTreeValue(merged, keyPath, normalizeConfigValue(lcl.overrides[keyPath]), ConfigSource{ Layer: ConfigLayerOverride }, sources)
+    }
+
+    // The defaults are already in the output, and they can't all survive a
+    // round-trip (secrets are redacted when marshaled).
+    pruneConfigDefaults(merged, "", sources)
+
+    encoded, err := yaml.Marshal(merged)
+    log.PanicIf(err)
+
+    err = yaml.Unmarshal(encoded, output)
+    log.PanicIf(err)
+
+    return sources, nil
+}
+
+// parseConfigEnvironmentValue Parse the variable as the type of the field
+// that it sets. Strings (including secrets) are taken as-is so that values like
+// "0755" and "yes" aren't reinterpreted as YAML, and numbers are decimal.
+func parseConfigEnvironmentValue(raw string, ft reflect.Type) (value interface{}, err error) {
+    for ft.Kind() == reflect.Ptr {
+        ft = ft.Elem()
+    }
+
+    target := reflect.New(ft)
+
+    switch ft.Kind() {
+    case reflect.String:
+        return raw, nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if ft == durationType {
+            // The same as for the other environment-bound values.
+            duration, err := ParseConfigDuration(raw)
+            if err != nil {
+                return nil, err
+            }
+
+            return duration, nil
+        }
+
+        n, err := strconv.ParseInt(raw, 10, ft.Bits())
+        if err != nil {
+            return nil, err
+        }
+
+        target.Elem().SetInt(n)
+        return target.Elem().Interface(), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        n, err := strconv.ParseUint(raw, 10, ft.Bits())
+        if err != nil {
+            return nil, err
+        }
+
+        target.Elem().SetUint(n)
+        return target.Elem().Interface(), nil
+    case reflect.Float32, reflect.Float64:
+        f, err := strconv.ParseFloat(raw, ft.Bits())
+        if err != nil {
+            return nil, err
+        }
+
+        target.Elem().SetFloat(f)
+        return target.Elem().Interface(), nil
+    }
+
+    // Booleans ("yes", "on", etc..), lists, mappings, and types that decode
+    // themselves.
+    if err := yaml.Unmarshal([]byte(raw), target.Interface()); err != nil {
+        return nil, err
+    }
+
+    return target.Elem().Interface(), nil
+}
+
+// configKeyPathType Return the type of the field at the key path (as given by
+// ConfigKeyPaths).
+func configKeyPathType(t reflect.Type, keyPath string) reflect.Type {
+    for _, part := range strings.Split(keyPath, ".") {
+        for t.Kind() == reflect.Ptr {
+            t = t.Elem()
+        }
+
+        fields := make(map[string]reflect.Type)
+        yamlStructFields(t, fields)
+
+        t = fields[part]
+    }
+
+    return t
+}
+
+// mergeFile Merge a file, and the files that it includes (first), into the
+// tree.
+func (lcl *LayeredConfigLoader) mergeFile(ctx context.Context, merged map[string]interface{}, configFilepath string, visiting map[string]bool, sources map[string]ConfigSource) {
+    absFilepath, err := filepath.Abs(configFilepath)
+    log.PanicIf(err)
+
+    if visiting[absFilepath] == true {
+        log.Panic(fmt.Errorf("config include cycle at [%s]", configFilepath))
+    }
+
+    visiting[absFilepath] = true
+    defer delete(visiting, absFilepath)
+
+    cuLogger.Debugf(ctx, "Loading config file: [%s]", configFilepath)
+
+    rawData, err := ioutil.ReadFile(configFilepath)
+    log.PanicIf(err)
+
+    var raw interface{}
+    if err := yaml.Unmarshal(rawData, &raw); err != nil {
+        log.Panic(fmt.Errorf("config file [%s] not valid: %s", configFilepath, err))
+    }
+
+    if raw == nil {
+        return
+    }
+
+    tree, ok := normalizeConfigValue(raw).(map[string]interface{})
+    if ok == false {
+        log.Panic(fmt.Errorf("config file [%s] must contain a mapping", configFilepath))
+    }
+
+    if includeRaw, found := tree[ConfigIncludeKey]; found == true {
+        delete(tree, ConfigIncludeKey)
+
+        var includes []interface{}
+        switch t := includeRaw.(type) {
+        case string:
+            includes = []interface{} { t }
+        case []interface{}:
+            includes = t
+        default:
+            log.Panic(fmt.Errorf("config includes in [%s] must be a string or list", configFilepath))
+        }
+
+        for _, includeRaw := range includes {
+            include := fmt.Sprintf("%v", includeRaw)
+            if filepath.IsAbs(include) == false {
+                include = filepath.Join(filepath.Dir(configFilepath), include)
+            }
+
+            lcl.mergeFile(ctx, merged, include, visiting, sources)
+        }
+    }
+
+    mergeConfigTree(merged, tree, "", ConfigSource{ Layer: ConfigLayerFile, Origin: configFilepath }, sources)
+}
+
+// marshalConfigTree Return the current values of the struct as a tree.
+func marshalConfigTree(output interface{}) map[string]interface{} {
+    encoded, err := yaml.Marshal(output)
+    log.PanicIf(err)
+
+    var raw interface{}
+    err = yaml.Unmarshal(encoded, &raw)
+    log.PanicIf(err)
+
+    if tree, ok := normalizeConfigValue(raw).(map[string]interface{}); ok == true {
+        return tree
+    }
+
+    return make(map[string]interface{})
+}
+
+// normalizeConfigValue Convert the maps that yaml.v2 produces into maps with
+// string keys.
+func normalizeConfigValue(value interface{}) interface{} {
+    switch t := value.(type) {
+    case map[interface{}]interface{}:
+        tree := make(map[string]interface{})
+        for k, v := range t {
+            tree[fmt.Sprintf("%v", k)] = normalizeConfigValue(v)
+        }
+
+        return tree
+    case map[string]interface{}:
+        tree := make(map[string]interface{})
+        for k, v := range t {
+            tree[k] = normalizeConfigValue(v)
+        }
+
+        return tree
+    case []interface{}:
+        list := make([]interface{}, len(t))
+        for i, v := range t {
+            list[i] = normalizeConfigValue(v)
+        }
+
+        return list
+    }
+
+    return value
+}
+
+// mergeConfigTree Merge `from` into `into`, recording the source of every leaf
+// that was set.
+func mergeConfigTree(into, from map[string]interface{}, prefix string, source ConfigSource, sources map[string]ConfigSource) {
+    for key, value := range from {
+        keyPath := key
+        if prefix != "" {
+            keyPath = prefix + "." + key
+        }
+
+        fromChild, fromIsTree := value.(map[string]interface{})
+        intoChild, intoIsTree := into[key].(map[string]interface{})
+
+        if fromIsTree == true && intoIsTree == true {
+            mergeConfigTree(intoChild, fromChild, keyPath, source, sources)
+            continue
+        }
+
+        forgetConfigSources(keyPath, sources)
+        into[key] = value
+        recordConfigSources(value, keyPath, source, sources)
+    }
+}
+
+// setConfigTreeValue Set the value at the dotted key path, creating any
+// intermediate mappings.
+func setConfigTreeValue(tree map[string]interface{}, keyPath string, value interface{}, source ConfigSource, sources map[string]ConfigSource) {
+    parts := strings.Split(keyPath, ".")
+
+    current := tree
+    for _, part := range parts[:len(parts) - 1] {
+        child, ok := current[part].(map[string]interface{})
+        if ok == false {
+            child = make(map[string]interface{})
+            current[part] = child
+        }
+
+        current = child
+    }
+
+    forgetConfigSources(keyPath, sources)
+    current[parts[len(parts) - 1]] = value
+    recordConfigSources(value, keyPath, source, sources)
+}
+
+// recordConfigSources Record the source of every leaf under the value.
+func recordConfigSources(value interface{}, keyPath string, source ConfigSource, sources map[string]ConfigSource) {
+    if tree, ok := value.(map[string]interface{}); ok == true && len(tree) > 0 {
+        for key, child := range tree {
+            recordConfigSources(child, keyPath + "." + key, source, sources)
+        }
+
+        return
+    }
+
+    sources[keyPath] = source
+}
+
+// pruneConfigDefaults Remove the leaves that still have their default values,
+// and the mappings that are left empty.
+func pruneConfigDefaults(tree map[string]interface{}, prefix string, sources map[string]ConfigSource) {
+    for key, value := range tree {
+        keyPath := key
+        if prefix != "" {
+            keyPath = prefix + "." + key
+        }
+
+        if child, ok := value.(map[string]interface{}); ok == true && len(child) > 0 {
+            pruneConfigDefaults(child, keyPath, sources)
+
+            if len(child) == 0 {
+                delete(tree, key)
+            }
+        } else if sources[keyPath].Layer == ConfigLayerDefault {
+            delete(tree, key)
+        }
+    }
+}
+
+// forgetConfigSources Remove the sources of the value being replaced at the key
+// path (and everything under it).
+func forgetConfigSources(keyPath string, sources map[string]ConfigSource) {
+    for existing := range sources {
+        if existing == keyPath || strings.HasPrefix(existing, keyPath + ".") == true {
+            delete(sources, existing)
+        }
+    }
+}
+
+// ConfigKeyPaths Return the dotted YAML paths of every leaf in the given struct
+// type, sorted. Maps, slices, and structs that hold a single value (see
+// isConfigLeafType) are leaves.
+func ConfigKeyPaths(t reflect.Type) (keyPaths []string) {
+    keyPaths = make([]string, 0)
+    collectConfigKeyPaths(t, "", &keyPaths)
+
+    sort.Strings(keyPaths)
+    return keyPaths
+}
+
+func collectConfigKeyPaths(t reflect.Type, prefix string, keyPaths *[]string) {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    if t.Kind() != reflect.Struct {
+        return
+    }
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        name, isInline := yamlFieldName(sf)
+        if name == "-" {
+            continue
+        }
+
+        ft := sf.Type
+        for ft.Kind() == reflect.Ptr {
+            ft = ft.Elem()
+        }
+
+        if isInline == true {
+            collectConfigKeyPaths(ft, prefix, keyPaths)
+            continue
+        }
+
+        keyPath := name
+        if prefix != "" {
+            keyPath = prefix + "." + name
+        }
+
+        if isConfigLeafType(ft) == false {
+            collectConfigKeyPaths(ft, keyPath, keyPaths)
+        } else {
+            *keyPaths = append(*keyPaths, keyPath)
+        }
+    }
+}
+
+// isConfigLeafType Return whether the type holds a single value rather than
+// nested config: anything but a struct, url.URL, and structs that decode
+// themselves (e.g. time.Time) or that have no exported fields.
+func isConfigLeafType(t reflect.Type) bool {
+    if t.Kind() != reflect.Struct || t == urlType {
+        return true
+    }
+
+    pt := reflect.PtrTo(t)
+    if pt.Implements(yamlUnmarshalerType) == true || pt.Implements(textUnmarshalerType) == true || pt.Implements(binaryUnmarshalerType) == true {
+        return true
+    }
+
+    for i := 0; i < t.NumField(); i++ {
+        if t.Field(i).PkgPath == "" {
+            return false
+        }
+    }
+
+    return true
+}
+
+// yamlFieldName Return the key that yaml.v2 uses for the field.
+func yamlFieldName(sf reflect.StructField) (name string, isInline bool) {
+    tag := sf.Tag.Get("yaml")
+    parts := strings.Split(tag, ",")
+
+    for _, option := range parts[1:] {
+        if option == "inline" {
+            isInline = true
+        }
+    }
+
+    name = parts[0]
+    if name == "" {
+        name = strings.ToLower(sf.Name)
+    }
+
+    return name, isInline
+}
@@ -0,0 +1,134 @@
+package ricommon
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "net/url"
+
+    "golang.org/x/net/context"
+)
+
+type layeredTestConfig struct {
+    Db struct {
+        Host string `yaml:"host"`
+        Password Secret `yaml:"password"`
+        Mode string `yaml:"mode"`
+        Port int `yaml:"port"`
+        Verbose bool `yaml:"verbose"`
+        Timeout time.Duration `yaml:"timeout"`
+        Tags []string `yaml:"tags"`
+    } `yaml:"db"`
+
+    Started time.Time `yaml:"started"`
+    Endpoint url.URL `yaml:"endpoint"`
+}
+
+func TestLayeredConfigLoader_Load_EnvironmentTypes(t *testing.T) {
+    environ := map[string]string{
+        "APP_DB_HOST": "yes",
+        "APP_DB_PASSWORD": "0755",
+        "APP_DB_MODE": "0755",
+        "APP_DB_PORT": "0755",
+        "APP_DB_VERBOSE": "yes",
+        "APP_DB_TIMEOUT": "1m30s",
+        "APP_DB_TAGS": "[a, b]",
+    }
+
+    lcl := NewLayeredConfigLoader(nil, "APP_")
+    lcl.SetLookup(func(name string) (string, bool) {
+        value, found := environ[name]
+        return value, found
+    })
+
+    config := new(layeredTestConfig)
+
+    sources, err := lcl.Load(context.Background(), config)
+    if err != nil {
+        t.Fatalf("Load failed: %s", err)
+    }
+
+    if config.Db.Host != "yes" {
+        t.Fatalf("Host not correct: [%s]", config.Db.Host)
+    } else if config.Db.Password.Reveal() != "0755" {
+        t.Fatalf("Password not correct: [%s]", config.Db.Password.Reveal())
+    } else if config.Db.Mode != "0755" {
+        t.Fatalf("Mode not correct: [%s]", config.Db.Mode)
+    } else if config.Db.Port != 755 {
+        t.Fatalf("Port not correct: (%d)", config.Db.Port)
+    } else if config.Db.Verbose != true {
+        t.Fatalf("Verbose not correct.")
+    } else if config.Db.Timeout != 90 * time.Second {
+        t.Fatalf("Timeout not correct: [%s]", config.Db.Timeout)
+    } else if reflect.DeepEqual(config.Db.Tags, []string { "a", "b" }) != true {
+        t.Fatalf("Tags not correct: %v", config.Db.Tags)
+    }
+
+    if source := sources["db.password"]; source.Layer != ConfigLayerEnvironment || source.Origin != "APP_DB_PASSWORD" {
+        t.Fatalf("Source not correct: [%s]", source)
+    }
+}
+
+func TestConfigKeyPaths_LeafStructs(t *testing.T) {
+    keyPaths := ConfigKeyPaths(reflect.TypeOf(layeredTestConfig{}))
+
+    expected := []string {
+        "db.host",
+        "db.mode",
+        "db.password",
+        "db.port",
+        "db.tags",
+        "db.timeout",
+        "db.verbose",
+        "endpoint",
+        "started",
+    }
+
+    if reflect.DeepEqual(keyPaths, expected) != true {
+        t.Fatalf("Key-paths not correct: %v", keyPaths)
+    }
+}
+
+func TestLayeredConfigLoader_Load_DurationSeconds(t *testing.T) {
+    lcl := NewLayeredConfigLoader(nil, "APP_")
+    lcl.SetLookup(func(name string) (string, bool) {
+        if name == "APP_DB_TIMEOUT" {
+            return "90", true
+        }
+
+        return "", false
+    })
+
+    config := new(layeredTestConfig)
+
+    if _, err := lcl.Load(context.Background(), config); err != nil {
+        t.Fatalf("Load failed: %s", err)
+    } else if config.Db.Timeout != time.Second * 90 {
+        t.Fatalf("Timeout not correct: [%s]", config.Db.Timeout)
+    }
+}
+
+func TestLayeredConfigLoader_Load_OverrideOrder(t *testing.T) {
+    // Enough times that map order would have been noticed.
+    for i := 0; i < 20; i++ {
+        lcl := NewLayeredConfigLoader(nil, "APP_")
+        lcl.SetLookup(func(name string) (string, bool) {
+            return "", false
+        })
+
+        lcl.SetOverride("db.host", "specific")
+        lcl.SetOverride("db", map[string]interface{} { "host": "general", "port": 5432 })
+
+        config := new(layeredTestConfig)
+
+        sources, err := lcl.Load(context.Background(), config)
+        if err != nil {
+            t.Fatalf("Load failed: %s", err)
+        } else if config.Db.Host != "specific" || config.Db.Port != 5432 {
+            t.Fatalf("Overrides not applied in order: [%s] (%d)", config.Db.Host, config.Db.Port)
+        } else if sources["db.host"].Layer != ConfigLayerOverride {
+            t.Fatalf("Source not correct: %v", sources["db.host"])
+        }
+    }
+}
@@ -0,0 +1,455 @@
+package ricommon
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "reflect"
+    "sort"
+    "strings"
+    "sync"
+
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Constants
+const (
+    // The struct tag that describes a bound field.
+    ConfigTagDescription = "description"
+)
+
+// Config value types (as recorded in the registry)
+const (
+    ConfigTypeString = "string"
+    ConfigTypeStringSlice = "[]string"
+    ConfigTypeInt32 = "int32"
+    ConfigTypeInt64 = "int64"
+    ConfigTypeBool = "bool"
+    ConfigTypeDuration = "duration"
+    ConfigTypeDurationSeconds = "seconds"
+    ConfigTypeSecret = "secret"
+)
+
+// Other
+var (
+    configRegistry = make(map[string]*ConfigDeclaration)
+    configRegistryLocker sync.Mutex
+
+    // Patterns that environment values of each type must match.
+    configTypePatterns = map[string]string {
+        "bool": "^(1|0|t|f|T|F|true|false|TRUE|FALSE|True|False)$",
+        "int": "^-?[0-9]+$",
+        "int8": "^-?[0-9]+$",
+        "int16": "^-?[0-9]+$",
+        "int32": "^-?[0-9]+$",
+        "int64": "^-?[0-9]+$",
+        "uint": "^[0-9]+$",
+        "uint8": "^[0-9]+$",
+        "uint16": "^[0-9]+$",
+        "uint32": "^[0-9]+$",
+        "uint64": "^[0-9]+$",
+        "float32": "^-?[0-9]*\\.?[0-9]+([eE][-+]?[0-9]+)?$",
+        "float64": "^-?[0-9]*\\.?[0-9]+([eE][-+]?[0-9]+)?$",
+        "duration": "^([0-9]+|([0-9]*\\.?[0-9]+(ns|us|µs|ms|s|m|h))+)$",
+
+        // GetConfigValueDuration() doesn't take unit suffixes.
+        "seconds": "^-?[0-9]+$",
+    }
+)
+
+// ConfigDeclaration Describes one environment variable that is consumed.
+type ConfigDeclaration struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+    Default string `json:"default,omitempty"`
+    Required bool `json:"required"`
+    Description string `json:"description,omitempty"`
+}
+
+// DeclareConfigValue Record a variable in the registry. The GetConfigValue*
+// functions and BindConfig call this for every variable that they read. Empty
+// fields don't replace what was previously recorded, so a variable can be
+// declared once with a description and then read normally.
+func DeclareConfigValue(cd ConfigDeclaration) {
+    configRegistryLocker.Lock()
+    defer configRegistryLocker.Unlock()
+
+    existing, found := configRegistry[cd.Name]
+    if found == false {
+        copied := cd
+        configRegistry[cd.Name] = &copied
+
+        return
+    }
+
+    // The typed getters read through the string getters, so a string type
+    // never replaces a more specific one.
+    if cd.Type != "" && (existing.Type == "" || existing.Type == ConfigTypeString) {
+        existing.Type = cd.Type
+    }
+
+    if cd.Default != "" {
+        existing.Default = cd.Default
+    }
+
+    if cd.Description != "" {
+        existing.Description = cd.Description
+    }
+
+    existing.Required = existing.Required || cd.Required
+}
+
+// DescribeConfigValue Attach a description to a variable.
+func DescribeConfigValue(name, description string) {
+    DeclareConfigValue(ConfigDeclaration{
+        Name: name,
+        Description: description,
+    })
+}
+
+// DeclaredConfigValues Return everything in the registry, sorted by name.
+func DeclaredConfigValues() (declarations []ConfigDeclaration) {
+    configRegistryLocker.Lock()
+    defer configRegistryLocker.Unlock()
+
+    declarations = make([]ConfigDeclaration, 0, len(configRegistry))
+    for _, cd := range configRegistry {
+        declarations = append(declarations, *cd)
+    }
+
+    sort.Slice(declarations, func(i, j int) bool {
+        return declarations[i].Name < declarations[j].Name
+    })
+
+    return declarations
+}
+
+// declareBoundConfigField Record a field that BindConfig is binding.
+func declareBoundConfigField(name string, sf reflect.StructField) {
+    typeName := sf.Type.String()
+    switch sf.Type {
+    case durationType:
+        typeName = ConfigTypeDuration
+    case secretType:
+        typeName = ConfigTypeSecret
+    }
+
+    DeclareConfigValue(ConfigDeclaration{
+        Name: name,
+        Type: typeName,
+        Default: sf.Tag.Get(ConfigTagDefault),
+        Required: sf.Tag.Get(ConfigTagRequired) == "true",
+        Description: sf.Tag.Get(ConfigTagDescription),
+    })
+}
+
+// WriteConfigRegistryJson Write the registry so that it can be rendered or
+// checked outside of the process (see RunConfigCommand).
+func WriteConfigRegistryJson(w io.Writer, declarations []ConfigDeclaration) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    e := json.NewEncoder(w)
+    e.SetIndent("", "  ")
+
+    err = e.Encode(declarations)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// ReadConfigRegistryJson Read a registry written by WriteConfigRegistryJson.
+func ReadConfigRegistryJson(r io.Reader) (declarations []ConfigDeclaration, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    err = json.NewDecoder(r).Decode(&declarations)
+    log.PanicIf(err)
+
+    return declarations, nil
+}
+
+// WriteConfigMarkdown Write the declarations as a Markdown table.
+func WriteConfigMarkdown(w io.Writer, declarations []ConfigDeclaration) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    escape := func(s string) string {
+        return strings.Replace(strings.Replace(s, "|", "\\|", -1), "\n", " ", -1)
+    }
+
+    writeConfigLine(w, "| Variable | Type | Default | Required | Description |")
+    writeConfigLine(w, "|---|---|---|---|---|")
+
+    for _, cd := range declarations {
+        defaultValue := ""
+        if cd.Default != "" {
+            defaultValue = "`" + escape(cd.Default) + "`"
+        }
+
+        required := "no"
+        if cd.Required == true {
+            required = "yes"
+        }
+
+        writeConfigLine(w, fmt.Sprintf("| `%s` | %s | %s | %s | %s |", cd.Name, escape(cd.Type), defaultValue, required, escape(cd.Description)))
+    }
+
+    return nil
+}
+
+// WriteConfigEnvExample Write an example ".env" file. Required variables are
+// left uncommented and empty; the rest are commented-out with their defaults.
+func WriteConfigEnvExample(w io.Writer, declarations []ConfigDeclaration) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    for i, cd := range declarations {
+        if i > 0 {
+            writeConfigLine(w, "")
+        }
+
+        if cd.Description != "" {
+            writeConfigLine(w, fmt.Sprintf("# %s", strings.Replace(cd.Description, "\n", "\n# ", -1)))
+        }
+
+        writeConfigLine(w, fmt.Sprintf("# (%s)", cd.Type))
+
+        if cd.Required == true {
+            writeConfigLine(w, fmt.Sprintf("%s=%s", cd.Name, cd.Default))
+        } else {
+            writeConfigLine(w, fmt.Sprintf("#%s=%s", cd.Name, cd.Default))
+        }
+    }
+
+    return nil
+}
+
+// WriteConfigJsonSchema Write a JSON schema for an object holding the
+// environment. Every value is a string (as in the environment) but must match
+// a pattern appropriate to its type.
+func WriteConfigJsonSchema(w io.Writer, declarations []ConfigDeclaration) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    properties := make(map[string]interface{})
+    required := make([]string, 0)
+
+    for _, cd := range declarations {
+        property := map[string]interface{} {
+            "type": "string",
+        }
+
+        if pattern, found := configTypePatterns[cd.Type]; found == true {
+            property["pattern"] = pattern
+        }
+
+        if cd.Default != "" {
+            property["default"] = cd.Default
+        }
+
+        if cd.Description != "" {
+            property["description"] = cd.Description
+        }
+
+        properties[cd.Name] = property
+
+        if cd.Required == true {
+            required = append(required, cd.Name)
+        }
+    }
+
+    schema := map[string]interface{} {
+        "$schema": "http://json-schema.org/draft-07/schema#",
+        "type": "object",
+        "properties": properties,
+        "required": required,
+    }
+
+    e := json.NewEncoder(w)
+    e.SetIndent("", "  ")
+
+    err = e.Encode(schema)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// CheckConfigEnvironment Compare an environment (as "NAME=value" pairs) with
+// the declarations. Returns the required variables that are missing and the
+// variables that start with one of the prefixes but weren't declared (usually
+// typos).
+func CheckConfigEnvironment(environ []string, declarations []ConfigDeclaration, prefixes []string) (missing, unknown []string) {
+    set := make(map[string]string)
+    for _, pair := range environ {
+        parts := strings.SplitN(pair, "=", 2)
+        if len(parts) == 2 {
+            set[parts[0]] = parts[1]
+        } else {
+            set[parts[0]] = ""
+        }
+    }
+
+    declared := make(map[string]bool)
+    missing = make([]string, 0)
+
+    for _, cd := range declarations {
+        declared[cd.Name] = true
+
+        if cd.Required == true && cd.Default == "" && set[cd.Name] == "" {
+            missing = append(missing, cd.Name)
+        }
+    }
+
+    unknown = make([]string, 0)
+    for name := range set {
+        if declared[name] == true {
+            continue
+        }
+
+        for _, prefix := range prefixes {
+            if strings.HasPrefix(name, prefix) == true {
+                unknown = append(unknown, name)
+                break
+            }
+        }
+    }
+
+    sort.Strings(unknown)
+    return missing, unknown
+}
+
+// ReadEnvFile Read "NAME=value" pairs from a ".env" file, skipping comments and
+// blank lines and removing surrounding quotes.
+func ReadEnvFile(r io.Reader) (environ []string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    environ = make([]string, 0)
+
+    s := bufio.NewScanner(r)
+    for s.Scan() {
+        line := strings.TrimSpace(s.Text())
+        if line == "" || strings.HasPrefix(line, "#") == true {
+            continue
+        }
+
+        line = strings.TrimPrefix(line, "export ")
+
+        parts := strings.SplitN(line, "=", 2)
+        if len(parts) != 2 {
+            log.Panic(fmt.Errorf("env line not valid: [%s]", line))
+        }
+
+        value := strings.TrimSpace(parts[1])
+        if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value) - 1] == value[0] {
+            value = value[1:len(value) - 1]
+        }
+
+        environ = append(environ, strings.TrimSpace(parts[0]) + "=" + value)
+    }
+
+    err = s.Err()
+    log.PanicIf(err)
+
+    return environ, nil
+}
+
+func writeConfigLine(w io.Writer, line string) {
+    if _, err := io.WriteString(w, line + "\n"); err != nil {
+        log.Panic(err)
+    }
+}
+
+// RunConfigCommand Implement the "markdown", "env", "schema", "json", and
+// "check" sub-commands over the given declarations. A service can call this
+// from its own main() with its live registry, or use the standalone command
+// with a registry exported by WriteConfigRegistryJson. "check" compares the
+// current environment (or the file given with -env-file) and fails if anything
+// is missing or unknown.
+func RunConfigCommand(args []string, declarations []ConfigDeclaration, stdout io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    if len(args) == 0 {
+        log.Panic(fmt.Errorf("command required: markdown, env, schema, json, or check"))
+    }
+
+    switch args[0] {
+    case "markdown":
+        err = WriteConfigMarkdown(stdout, declarations)
+    case "env":
+        err = WriteConfigEnvExample(stdout, declarations)
+    case "schema":
+        err = WriteConfigJsonSchema(stdout, declarations)
+    case "json":
+        err = WriteConfigRegistryJson(stdout, declarations)
+    case "check":
+        fs := flag.NewFlagSet("check", flag.ContinueOnError)
+        envFilepath := fs.String("env-file", "", "Check this file rather than the environment")
+        prefixes := fs.String("prefixes", "", "Comma-separated prefixes of variables that must be declared")
+
+        err := fs.Parse(args[1:])
+        log.PanicIf(err)
+
+        environ := os.Environ()
+        if *envFilepath != "" {
+            f, err := os.Open(*envFilepath)
+            log.PanicIf(err)
+
+            defer f.Close()
+
+            environ, err = ReadEnvFile(f)
+            log.PanicIf(err)
+        }
+
+        prefixList := make([]string, 0)
+        if *prefixes != "" {
+            prefixList = strings.Split(*prefixes, ",")
+        }
+
+        missing, unknown := CheckConfigEnvironment(environ, declarations, prefixList)
+
+        for _, name := range missing {
+            writeConfigLine(stdout, fmt.Sprintf("MISSING %s", name))
+        }
+
+        for _, name := range unknown {
+            writeConfigLine(stdout, fmt.Sprintf("UNKNOWN %s", name))
+        }
+
+        if len(missing) > 0 || len(unknown) > 0 {
+            log.Panic(fmt.Errorf("environment has (%d) missing and (%d) unknown variables", len(missing), len(unknown)))
+        }
+    default:
+        log.Panic(fmt.Errorf("command not valid: [%s]", args[0]))
+    }
+
+    log.PanicIf(err)
+    return nil
+}
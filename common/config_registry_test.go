@@ -0,0 +1,225 @@
+package ricommon
+
+import (
+    "bytes"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+
+    "encoding/json"
+    "io/ioutil"
+)
+
+var (
+    registryTestDeclarations = []ConfigDeclaration {
+        {
+            Name: "APP_PORT",
+            Type: ConfigTypeInt32,
+            Default: "8080",
+            Description: "The port | to listen on.",
+        },
+        {
+            Name: "APP_TOKEN",
+            Type: ConfigTypeSecret,
+            Required: true,
+        },
+    }
+)
+
+func TestDeclareConfigValue(t *testing.T) {
+    DescribeConfigValue("RI_TEST_REGISTRY_WORKERS", "How many workers.")
+
+    os.Setenv("RI_TEST_REGISTRY_WORKERS", "4")
+    defer os.Unsetenv("RI_TEST_REGISTRY_WORKERS")
+
+    // Reads through the string getter.
+    if workers := GetConfigValueInt32WithDefault("RI_TEST_REGISTRY_WORKERS", 2); workers != 4 {
+        t.Fatalf("Value not correct: (%d)", workers)
+    }
+
+    var cd ConfigDeclaration
+    for _, declared := range DeclaredConfigValues() {
+        if declared.Name == "RI_TEST_REGISTRY_WORKERS" {
+            cd = declared
+        }
+    }
+
+    expected := ConfigDeclaration{
+        Name: "RI_TEST_REGISTRY_WORKERS",
+        Type: ConfigTypeInt32,
+        Default: "2",
+        Description: "How many workers.",
+    }
+
+    if cd != expected {
+        t.Fatalf("Declaration not correct: %v", cd)
+    }
+}
+
+func TestDeclareConfigValue_Bound(t *testing.T) {
+    type config struct {
+        Port int `env:"RI_TEST_REGISTRY_PORT" default:"80" description:"The port."`
+        Token Secret `env:"RI_TEST_REGISTRY_TOKEN" required:"true"`
+    }
+
+    lookup := func(name string) (string, bool) {
+        if name == "RI_TEST_REGISTRY_TOKEN" {
+            return "x", true
+        }
+
+        return "", false
+    }
+
+    if err := BindConfig(new(config), lookup); err != nil {
+        t.Fatalf("Bind failed: %s", err)
+    }
+
+    declarations := make(map[string]ConfigDeclaration)
+    for _, cd := range DeclaredConfigValues() {
+        declarations[cd.Name] = cd
+    }
+
+    if cd := declarations["RI_TEST_REGISTRY_PORT"]; cd.Type != "int" || cd.Default != "80" || cd.Required != false || cd.Description != "The port." {
+        t.Fatalf("Port declaration not correct: %v", cd)
+    } else if cd := declarations["RI_TEST_REGISTRY_TOKEN"]; cd.Type != ConfigTypeSecret || cd.Required != true {
+        t.Fatalf("Token declaration not correct: %v", cd)
+    }
+}
+
+func TestWriteConfigMarkdown(t *testing.T) {
+    b := new(bytes.Buffer)
+    if err := WriteConfigMarkdown(b, registryTestDeclarations); err != nil {
+        t.Fatalf("Markdown not written: %s", err)
+    }
+
+    expected := "| Variable | Type | Default | Required | Description |\n" +
+        "|---|---|---|---|---|\n" +
+        "| `APP_PORT` | int32 | `8080` | no | The port \\| to listen on. |\n" +
+        "| `APP_TOKEN` | secret |  | yes |  |\n"
+
+    if b.String() != expected {
+        t.Fatalf("Markdown not correct:\n%s", b.String())
+    }
+}
+
+func TestWriteConfigEnvExample(t *testing.T) {
+    b := new(bytes.Buffer)
+    if err := WriteConfigEnvExample(b, registryTestDeclarations); err != nil {
+        t.Fatalf("Example not written: %s", err)
+    }
+
+    expected := "# The port | to listen on.\n" +
+        "# (int32)\n" +
+        "#APP_PORT=8080\n" +
+        "\n" +
+        "# (secret)\n" +
+        "APP_TOKEN=\n"
+
+    if b.String() != expected {
+        t.Fatalf("Example not correct:\n%s", b.String())
+    }
+
+    // The example reads back.
+    environ, err := ReadEnvFile(b)
+    if err != nil {
+        t.Fatalf("Example not read: %s", err)
+    } else if reflect.DeepEqual(environ, []string { "APP_TOKEN=" }) != true {
+        t.Fatalf("Example not read correctly: %v", environ)
+    }
+}
+
+func TestWriteConfigJsonSchema(t *testing.T) {
+    b := new(bytes.Buffer)
+    if err := WriteConfigJsonSchema(b, registryTestDeclarations); err != nil {
+        t.Fatalf("Schema not written: %s", err)
+    }
+
+    schema := struct {
+        Properties map[string]map[string]string `json:"properties"`
+        Required []string `json:"required"`
+    }{}
+
+    if err := json.Unmarshal(b.Bytes(), &schema); err != nil {
+        t.Fatalf("Schema not valid: %s", err)
+    }
+
+    port := schema.Properties["APP_PORT"]
+    if port["type"] != "string" || port["pattern"] != configTypePatterns[ConfigTypeInt32] || port["default"] != "8080" {
+        t.Fatalf("Property not correct: %v", port)
+    } else if _, found := schema.Properties["APP_TOKEN"]["pattern"]; found == true {
+        t.Fatalf("Secrets should not have a pattern.")
+    } else if reflect.DeepEqual(schema.Required, []string { "APP_TOKEN" }) != true {
+        t.Fatalf("Required not correct: %v", schema.Required)
+    }
+}
+
+func TestCheckConfigEnvironment(t *testing.T) {
+    environ := []string {
+        "APP_PROT=80",
+        "APP_TOKEN=",
+        "HOME=/root",
+    }
+
+    missing, unknown := CheckConfigEnvironment(environ, registryTestDeclarations, []string { "APP_" })
+
+    if reflect.DeepEqual(missing, []string { "APP_TOKEN" }) != true {
+        t.Fatalf("Missing not correct: %v", missing)
+    } else if reflect.DeepEqual(unknown, []string { "APP_PROT" }) != true {
+        t.Fatalf("Unknown not correct: %v", unknown)
+    }
+}
+
+func TestReadEnvFile(t *testing.T) {
+    data := "# Comment.\n\nexport APP_A=1\nAPP_B = \"two words\"\nAPP_C='3'\n"
+
+    environ, err := ReadEnvFile(strings.NewReader(data))
+    if err != nil {
+        t.Fatalf("File not read: %s", err)
+    } else if reflect.DeepEqual(environ, []string { "APP_A=1", "APP_B=two words", "APP_C=3" }) != true {
+        t.Fatalf("Environment not correct: %v", environ)
+    }
+
+    if _, err := ReadEnvFile(strings.NewReader("APP_A\n")); err == nil {
+        t.Fatalf("Expected error for a line without a value.")
+    }
+}
+
+func TestRunConfigCommand(t *testing.T) {
+    b := new(bytes.Buffer)
+    if err := RunConfigCommand([]string { "json" }, registryTestDeclarations, b); err != nil {
+        t.Fatalf("Registry not written: %s", err)
+    }
+
+    declarations, err := ReadConfigRegistryJson(b)
+    if err != nil {
+        t.Fatalf("Registry not read: %s", err)
+    } else if reflect.DeepEqual(declarations, registryTestDeclarations) != true {
+        t.Fatalf("Registry did not round-trip: %v", declarations)
+    }
+
+    f, err := ioutil.TempFile("", "config_registry_test.*.env")
+    if err != nil {
+        t.Fatalf("Temporary file not created: %s", err)
+    }
+
+    defer os.Remove(f.Name())
+
+    f.WriteString("APP_TOKEN=x\nAPP_PROT=80\n")
+    f.Close()
+
+    b = new(bytes.Buffer)
+    if err := RunConfigCommand([]string { "check", "-env-file", f.Name(), "-prefixes", "APP_" }, registryTestDeclarations, b); err == nil {
+        t.Fatalf("Expected error for an unknown variable.")
+    } else if b.String() != "UNKNOWN APP_PROT\n" {
+        t.Fatalf("Check output not correct: [%s]", b.String())
+    }
+
+    if err := RunConfigCommand([]string { "check", "-env-file", f.Name() }, registryTestDeclarations, new(bytes.Buffer)); err != nil {
+        t.Fatalf("Check should have passed without prefixes: %s", err)
+    }
+
+    if err := RunConfigCommand([]string { "yaml" }, registryTestDeclarations, new(bytes.Buffer)); err == nil {
+        t.Fatalf("Expected error for an unknown command.")
+    }
+}
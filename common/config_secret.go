@@ -0,0 +1,273 @@
+package ricommon
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "os"
+    "strings"
+    "sync"
+
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "encoding/hex"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Secret reference prefixes
+const (
+    // Read the value from a file (e.g. "file:///run/secrets/db").
+    SecretPrefixFile = "file://"
+
+    // Read the value from another environment variable (e.g. "env:DB_PASS").
+    SecretPrefixEnvironment = "env:"
+
+    // Decrypt the value (AES-256-GCM, base64 of the nonce followed by the
+    // ciphertext) with the key in the file named by SecretKeyFileVariable.
+    SecretPrefixEncrypted = "enc:aesgcm:"
+)
+
+// Constants
+const (
+    // The environment variable that names the key file for encrypted values.
+    // The file holds 32 bytes, either raw or in hex or base64.
+    SecretKeyFileVariable = "RI_SECRET_KEY_FILE"
+
+    // What secrets look like when printed.
+    SecretRedacted = "[REDACTED]"
+
+    secretKeySize = 32
+)
+
+// Other
+var (
+    secretKey []byte
+    secretKeyLocker sync.Mutex
+)
+
+// Secret Holds a sensitive config value. It always prints as a redaction
+// marker, regardless of the formatting verb, so that it can't leak through
+// logging. When loaded from YAML or the environment, references (see the
+// SecretPrefix* constants) are resolved to their values.
+type Secret string
+
+// Reveal Return the actual value.
+func (s Secret) Reveal() string {
+    return string(s)
+}
+
+func (s Secret) String() string {
+    return SecretRedacted
+}
+
+func (s Secret) GoString() string {
+    return SecretRedacted
+}
+
+// Format Redact for every verb (%s, %v, %q, %x, etc..).
+func (s Secret) Format(f fmt.State, verb rune) {
+    io.WriteString(f, SecretRedacted)
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+    return []byte("\"" + SecretRedacted + "\""), nil
+}
+
+// MarshalYAML Redact, as for JSON, so that writing a config doesn't write its
+// secrets.
+func (s Secret) MarshalYAML() (interface{}, error) {
+    return SecretRedacted, nil
+}
+
+// UnmarshalYAML Resolve the reference in the YAML value.
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) (err error) {
+    var reference string
+    if err := unmarshal(&reference); err != nil {
+        return err
+    }
+
+    value, err := ResolveSecretReference(reference)
+    if err != nil {
+        return err
+    }
+
+    *s = Secret(value)
+    return nil
+}
+
+// ResolveSecretReference Return the value that the reference points to. Values
+// without a recognized prefix are returned as-is.
+func ResolveSecretReference(reference string) (value string, err error) {
+    return ResolveSecretReferenceWithLookup(reference, os.LookupEnv)
+}
+
+// ResolveSecretReferenceWithLookup Like ResolveSecretReference, but "env:"
+// references are resolved with the given lookup function.
+func ResolveSecretReferenceWithLookup(reference string, lookup func(name string) (string, bool)) (value string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    if strings.HasPrefix(reference, SecretPrefixFile) == true {
+        filepath := reference[len(SecretPrefixFile):]
+
+        raw, err := ioutil.ReadFile(filepath)
+        log.PanicIf(err)
+
+        // Secret files are usually written with a trailing newline.
+        return strings.TrimRight(string(raw), "\r\n"), nil
+    } else if strings.HasPrefix(reference, SecretPrefixEnvironment) == true {
+        name := reference[len(SecretPrefixEnvironment):]
+
+        value, _ = lookup(name)
+        if value == "" {
+            log.Panic(fmt.Errorf("secret variable not found: [%s]", name))
+        }
+
+        return value, nil
+    } else if strings.HasPrefix(reference, SecretPrefixEncrypted) == true {
+        key, err := getSecretKey()
+        log.PanicIf(err)
+
+        value, err = DecryptSecretValue(reference, key)
+        log.PanicIf(err)
+
+        return value, nil
+    }
+
+    return reference, nil
+}
+
+// getSecretKey Load the key file the first time that it's needed.
+func getSecretKey() (key []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    secretKeyLocker.Lock()
+    defer secretKeyLocker.Unlock()
+
+    if secretKey == nil {
+        filepath := os.Getenv(SecretKeyFileVariable)
+        if filepath == "" {
+            log.Panic(fmt.Errorf("encrypted secret requires a key file: [%s]", SecretKeyFileVariable))
+        }
+
+        secretKey, err = LoadSecretKeyFile(filepath)
+        log.PanicIf(err)
+    }
+
+    return secretKey, nil
+}
+
+// LoadSecretKeyFile Read a 32-byte key stored raw or in hex or base64.
+func LoadSecretKeyFile(filepath string) (key []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    raw, err := ioutil.ReadFile(filepath)
+    log.PanicIf(err)
+
+    if len(raw) == secretKeySize {
+        return raw, nil
+    }
+
+    trimmed := string(bytes.TrimSpace(raw))
+
+    if key, err := hex.DecodeString(trimmed); err == nil && len(key) == secretKeySize {
+        return key, nil
+    } else if key, err := base64.StdEncoding.DecodeString(trimmed); err == nil && len(key) == secretKeySize {
+        return key, nil
+    }
+
+    log.Panic(fmt.Errorf("secret key file must hold (%d) bytes: [%s]", secretKeySize, filepath))
+    return nil, nil
+}
+
+// EncryptSecretValue Return an encrypted reference for the value that can be
+// stored in a config file.
+func EncryptSecretValue(value string, key []byte) (reference string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    gcm := newSecretCipher(key)
+
+    nonce := make([]byte, gcm.NonceSize())
+    _, err = io.ReadFull(rand.Reader, nonce)
+    log.PanicIf(err)
+
+    sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+    reference = SecretPrefixEncrypted + base64.StdEncoding.EncodeToString(sealed)
+
+    return reference, nil
+}
+
+// DecryptSecretValue Return the value in an encrypted reference.
+func DecryptSecretValue(reference string, key []byte) (value string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    if strings.HasPrefix(reference, SecretPrefixEncrypted) == false {
+        log.Panic(fmt.Errorf("secret is not encrypted"))
+    }
+
+    sealed, err := base64.StdEncoding.DecodeString(reference[len(SecretPrefixEncrypted):])
+    log.PanicIf(err)
+
+    gcm := newSecretCipher(key)
+    if len(sealed) < gcm.NonceSize() {
+        log.Panic(fmt.Errorf("encrypted secret is truncated"))
+    }
+
+    nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+    plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+    if err != nil {
+        // Don't pass along anything about the ciphertext.
+        log.Panic(fmt.Errorf("encrypted secret could not be decrypted"))
+    }
+
+    return string(plaintext), nil
+}
+
+func newSecretCipher(key []byte) cipher.AEAD {
+    if len(key) != secretKeySize {
+        log.Panic(fmt.Errorf("secret key must be (%d) bytes", secretKeySize))
+    }
+
+    block, err := aes.NewCipher(key)
+    log.PanicIf(err)
+
+    gcm, err := cipher.NewGCM(block)
+    log.PanicIf(err)
+
+    return gcm
+}
+
+// GetConfigValueSecret Return the resolved secret for the environment
+// variable.
+func GetConfigValueSecret(name string) Secret {
+    DeclareConfigValue(ConfigDeclaration{ Name: name, Type: ConfigTypeSecret, Required: true })
+
+    value, err := ResolveSecretReference(GetConfigValueString(name))
+    log.PanicIf(err)
+
+    return Secret(value)
+}
@@ -0,0 +1,170 @@
+package ricommon
+
+import (
+    "fmt"
+    "os"
+    "strings"
+    "testing"
+
+    "encoding/hex"
+    "encoding/json"
+    "io/ioutil"
+
+    "gopkg.in/yaml.v2"
+    "golang.org/x/net/context"
+)
+
+type secretTestConfig struct {
+    Password Secret `yaml:"password" json:"password"`
+}
+
+// writeSecretTestFile Write the data to a temporary file and return its path.
+func writeSecretTestFile(t *testing.T, data string) string {
+    f, err := ioutil.TempFile("", "config_secret_test.*")
+    if err != nil {
+        t.Fatalf("Temporary file not created: %s", err)
+    }
+
+    t.Cleanup(func() {
+        os.Remove(f.Name())
+    })
+
+    _, err = f.WriteString(data)
+    f.Close()
+
+    if err != nil {
+        t.Fatalf("Temporary file not written: %s", err)
+    }
+
+    return f.Name()
+}
+
+func TestSecret_Redaction(t *testing.T) {
+    config := secretTestConfig{
+        Password: Secret("hunter2"),
+    }
+
+    encodedJson, err := json.Marshal(config)
+    if err != nil {
+        t.Fatalf("Could not marshal JSON: %s", err)
+    }
+
+    encodedYaml, err := yaml.Marshal(config)
+    if err != nil {
+        t.Fatalf("Could not marshal YAML: %s", err)
+    }
+
+    outputs := map[string]string {
+        "json": string(encodedJson),
+        "yaml": string(encodedYaml),
+        "%v": fmt.Sprintf("%v", config),
+        "%+v": fmt.Sprintf("%+v", config),
+        "%#v": fmt.Sprintf("%#v", config),
+        "%s": fmt.Sprintf("%s", config.Password),
+        "%q": fmt.Sprintf("%q", config.Password),
+    }
+
+    for name, output := range outputs {
+        if strings.Contains(output, "hunter2") == true {
+            t.Fatalf("Secret leaked through [%s]: [%s]", name, output)
+        } else if strings.Contains(output, SecretRedacted) == false {
+            t.Fatalf("Secret not redacted through [%s]: [%s]", name, output)
+        }
+    }
+
+    if config.Password.Reveal() != "hunter2" {
+        t.Fatalf("Secret not revealed.")
+    }
+}
+
+func TestResolveSecretReference(t *testing.T) {
+    t.Setenv("SECRET_TEST_PASSWORD", "hunter2")
+
+    filepath := writeSecretTestFile(t, "hunter3\n")
+
+    references := map[string]string {
+        "plain": "plain",
+        "env:SECRET_TEST_PASSWORD": "hunter2",
+        SecretPrefixFile + filepath: "hunter3",
+    }
+
+    for reference, expected := range references {
+        value, err := ResolveSecretReference(reference)
+        if err != nil {
+            t.Fatalf("Reference [%s] not resolved: %s", reference, err)
+        } else if value != expected {
+            t.Fatalf("Reference [%s] not correct: [%s]", reference, value)
+        }
+    }
+
+    if _, err := ResolveSecretReference("env:SECRET_TEST_MISSING"); err == nil {
+        t.Fatalf("Expected error for missing variable.")
+    }
+}
+
+func TestEncryptSecretValue(t *testing.T) {
+    key := make([]byte, secretKeySize)
+    for i := range key {
+        key[i] = byte(i)
+    }
+
+    reference, err := EncryptSecretValue("hunter2", key)
+    if err != nil {
+        t.Fatalf("Value not encrypted: %s", err)
+    } else if strings.HasPrefix(reference, SecretPrefixEncrypted) != true || strings.Contains(reference, "hunter2") == true {
+        t.Fatalf("Reference not correct: [%s]", reference)
+    }
+
+    value, err := DecryptSecretValue(reference, key)
+    if err != nil {
+        t.Fatalf("Value not decrypted: %s", err)
+    } else if value != "hunter2" {
+        t.Fatalf("Value not correct: [%s]", value)
+    }
+
+    otherKey := make([]byte, secretKeySize)
+    if _, err := DecryptSecretValue(reference, otherKey); err == nil {
+        t.Fatalf("Expected error for the wrong key.")
+    }
+
+    // Keys are also read from files, in hex.
+    loadedKey, err := LoadSecretKeyFile(writeSecretTestFile(t, hex.EncodeToString(key) + "\n"))
+    if err != nil {
+        t.Fatalf("Key not loaded: %s", err)
+    } else if hex.EncodeToString(loadedKey) != hex.EncodeToString(key) {
+        t.Fatalf("Key not correct.")
+    }
+}
+
+func TestSecret_UnmarshalYAML(t *testing.T) {
+    filepath := writeSecretTestFile(t, "hunter2\n")
+
+    config := new(secretTestConfig)
+
+    err := yaml.Unmarshal([]byte("password: " + SecretPrefixFile + filepath + "\n"), config)
+    if err != nil {
+        t.Fatalf("Could not unmarshal: %s", err)
+    } else if config.Password.Reveal() != "hunter2" {
+        t.Fatalf("Reference not resolved: [%s]", config.Password.Reveal())
+    }
+}
+
+func TestLayeredConfigLoader_Load_SecretDefault(t *testing.T) {
+    lcl := NewLayeredConfigLoader(nil, "APP_")
+    lcl.SetLookup(func(name string) (string, bool) {
+        return "", false
+    })
+
+    config := &secretTestConfig{
+        Password: Secret("hunter2"),
+    }
+
+    _, err := lcl.Load(context.Background(), config)
+    if err != nil {
+        t.Fatalf("Load failed: %s", err)
+    }
+
+    if config.Password.Reveal() != "hunter2" {
+        t.Fatalf("Default secret not kept: [%s]", config.Password.Reveal())
+    }
+}
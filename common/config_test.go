@@ -0,0 +1,41 @@
+package ricommon
+
+import (
+    "os"
+    "regexp"
+    "testing"
+    "time"
+)
+
+func TestGetConfigValueDuration_SchemaPattern(t *testing.T) {
+    pattern := regexp.MustCompile(configTypePatterns[ConfigTypeDurationSeconds])
+
+    // The getters take seconds.
+    if pattern.MatchString("90") != true {
+        t.Fatalf("Pattern did not match seconds.")
+    } else if pattern.MatchString("1m30s") == true {
+        t.Fatalf("Pattern should not match a unit suffix.")
+    }
+
+    os.Setenv("RI_TEST_DURATION", "90")
+    defer os.Unsetenv("RI_TEST_DURATION")
+
+    if duration := GetConfigValueDuration("RI_TEST_DURATION"); duration != 90 * time.Second {
+        t.Fatalf("Duration not correct: [%s]", duration)
+    }
+
+    os.Unsetenv("RI_TEST_DURATION")
+
+    if duration := GetConfigValueDurationWithDefault("RI_TEST_DURATION", 30 * time.Second); duration != 30 * time.Second {
+        t.Fatalf("Default not correct: [%s]", duration)
+    }
+
+    declarations := make(map[string]ConfigDeclaration)
+    for _, cd := range DeclaredConfigValues() {
+        declarations[cd.Name] = cd
+    }
+
+    if cd := declarations["RI_TEST_DURATION"]; cd.Type != ConfigTypeDurationSeconds || cd.Default != "30" {
+        t.Fatalf("Declaration not correct: %v", cd)
+    }
+}
@@ -0,0 +1,545 @@
+package ricommon
+
+import (
+    "fmt"
+    "io/ioutil"
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "golang.org/x/net/context"
+    "gopkg.in/yaml.v2"
+    "github.com/dsoprea/go-logging"
+
+    yaml3 "gopkg.in/yaml.v3"
+)
+
+// Config-validation struct tags
+const (
+    // Comma-separated rules: "required", "min=N", "max=N", "oneof=a|b|c",
+    // and the cross-field rules "requiredwith=Field", "requiredwithout=Field",
+    // "eqfield=Field", "nefield=Field", "gtfield=Field", and "ltfield=Field"
+    // (which refer to sibling fields by their Go names). For strings, slices,
+    // and maps, "min" and "max" apply to the length (so "min=1" rejects empty
+    // values). For durations, the limits have unit suffixes ("min=30s").
+    ConfigTagValidate = "validate"
+
+    // A regular-expression that string values must match. Kept separate from
+    // the other rules so that it may contain commas.
+    ConfigTagPattern = "pattern"
+)
+
+// Other
+var (
+    yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// ConfigValidationProblem Describes one invalid value.
+type ConfigValidationProblem struct {
+    // Filepath, Line, and Column Locate the value, when it was loaded from a
+    // file and was present in it.
+    Filepath string
+    Line int
+    Column int
+
+    KeyPath string
+    Message string
+}
+
+func (cvp ConfigValidationProblem) String() string {
+    if cvp.Filepath == "" {
+        return fmt.Sprintf("[%s] %s", cvp.KeyPath, cvp.Message)
+    } else if cvp.Line == 0 {
+        return fmt.Sprintf("%s: [%s] %s", cvp.Filepath, cvp.KeyPath, cvp.Message)
+    }
+
+    return fmt.Sprintf("%s:%d:%d: [%s] %s", cvp.Filepath, cvp.Line, cvp.Column, cvp.KeyPath, cvp.Message)
+}
+
+// ConfigValidationError Describes every problem found in a config.
+type ConfigValidationError struct {
+    Problems []ConfigValidationProblem
+}
+
+func (cve *ConfigValidationError) Error() string {
+    messages := make([]string, len(cve.Problems))
+    for i, problem := range cve.Problems {
+        messages[i] = problem.String()
+    }
+
+    return fmt.Sprintf("configuration not valid: %s", strings.Join(messages, "; "))
+}
+
+// GetStaticConfigStrict Like GetStaticConfig, but rejects keys that don't
+// correspond to a field and applies the validation rules in the struct tags.
+// If there are any problems, a *ConfigValidationError with the file position
+// of each is returned.
+func GetStaticConfigStrict(ctx context.Context, relFilepath string, output interface{}) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+            cuLogger.Errorf(ctx, nil, "Could not get static config: [%s]", err)
+        }
+    }()
+
+    rawData, err := ioutil.ReadFile(relFilepath)
+    log.PanicIf(err)
+
+    return parseStaticConfigStrict(relFilepath, rawData, output)
+}
+
+// parseStaticConfigStrict Parse and validate config data that was read from
+// the given file. The problems are returned as a *ConfigValidationError.
+// Anything else panics.
+func parseStaticConfigStrict(relFilepath string, rawData []byte, output interface{}) error {
+    // We only use this parser to find where values are.
+    root := new(yaml3.Node)
+    if err := yaml3.Unmarshal(rawData, root); err != nil {
+        log.Panic(fmt.Errorf("%s: %s", relFilepath, err))
+    }
+
+    problems := make([]ConfigValidationProblem, 0)
+    findUnknownConfigKeys(root, reflect.TypeOf(output), "", &problems)
+
+    if len(problems) == 0 {
+        if err := yaml.UnmarshalStrict(rawData, output); err != nil {
+            log.Panic(fmt.Errorf("%s: %s", relFilepath, err))
+        }
+
+        collectConfigValidationProblems(reflect.ValueOf(output), "", &problems)
+    }
+
+    if len(problems) == 0 {
+        return nil
+    }
+
+    positions := make(map[string]*yaml3.Node)
+    indexYamlNodes(root, "", positions)
+
+    for i, problem := range problems {
+        problems[i].Filepath = relFilepath
+
+        if node, found := positions[problem.KeyPath]; found == true {
+            problems[i].Line, problems[i].Column = node.Line, node.Column
+        } else if problem.Line == 0 {
+            // The value is missing. Point at the nearest parent that exists.
+            for keyPath := problem.KeyPath; keyPath != ""; {
+                if j := strings.LastIndex(keyPath, "."); j == -1 {
+                    keyPath = ""
+                } else {
+                    keyPath = keyPath[:j]
+                }
+
+                if node, found := positions[keyPath]; found == true {
+                    problems[i].Line, problems[i].Column = node.Line, node.Column
+                    break
+                }
+            }
+        }
+    }
+
+    return &ConfigValidationError{
+        Problems: problems,
+    }
+}
+
+// ValidateConfig Apply the validation rules in the struct tags to an already-
+// loaded config. Returns a *ConfigValidationError if there are any problems.
+func ValidateConfig(config interface{}) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    problems := make([]ConfigValidationProblem, 0)
+    collectConfigValidationProblems(reflect.ValueOf(config), "", &problems)
+
+    if len(problems) == 0 {
+        return nil
+    }
+
+    return &ConfigValidationError{
+        Problems: problems,
+    }
+}
+
+func joinConfigKeyPath(prefix, key string) string {
+    if prefix == "" {
+        return key
+    }
+
+    return prefix + "." + key
+}
+
+// yamlStructFields Return the types of the fields of the struct by their YAML
+// keys, including those of inlined structs.
+func yamlStructFields(t reflect.Type, fields map[string]reflect.Type) {
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        name, isInline := yamlFieldName(sf)
+        if name == "-" {
+            continue
+        }
+
+        if isInline == true {
+            ft := sf.Type
+            for ft.Kind() == reflect.Ptr {
+                ft = ft.Elem()
+            }
+
+            if ft.Kind() == reflect.Struct {
+                yamlStructFields(ft, fields)
+            }
+
+            continue
+        }
+
+        fields[name] = sf.Type
+    }
+}
+
+// findUnknownConfigKeys Report every mapping key that doesn't correspond to a
+// field.
+func findUnknownConfigKeys(node *yaml3.Node, t reflect.Type, keyPath string, problems *[]ConfigValidationProblem) {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    if node.Kind == yaml3.AliasNode {
+        node = node.Alias
+    }
+
+    // Types that decode themselves can accept whatever they like.
+    if reflect.PtrTo(t).Implements(yamlUnmarshalerType) == true {
+        return
+    }
+
+    switch node.Kind {
+    case yaml3.DocumentNode:
+        for _, child := range node.Content {
+            findUnknownConfigKeys(child, t, keyPath, problems)
+        }
+
+        return
+    case yaml3.SequenceNode:
+        if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+            for i, child := range node.Content {
+                findUnknownConfigKeys(child, t.Elem(), joinConfigKeyPath(keyPath, strconv.Itoa(i)), problems)
+            }
+        }
+
+        return
+    case yaml3.MappingNode:
+    default:
+        return
+    }
+
+    if t.Kind() == reflect.Map {
+        for i := 0; i + 1 < len(node.Content); i += 2 {
+            findUnknownConfigKeys(node.Content[i + 1], t.Elem(), joinConfigKeyPath(keyPath, node.Content[i].Value), problems)
+        }
+
+        return
+    } else if t.Kind() != reflect.Struct {
+        return
+    }
+
+    fields := make(map[string]reflect.Type)
+    yamlStructFields(t, fields)
+
+    for i := 0; i + 1 < len(node.Content); i += 2 {
+        key := node.Content[i]
+        value := node.Content[i + 1]
+
+        // Merge keys bring in the keys of another mapping.
+        if key.Value == "<<" {
+            findUnknownConfigKeys(value, t, keyPath, problems)
+            continue
+        }
+
+        childKeyPath := joinConfigKeyPath(keyPath, key.Value)
+
+        ft, found := fields[key.Value]
+        if found == false {
+            problem := ConfigValidationProblem{
+                Line: key.Line,
+                Column: key.Column,
+                KeyPath: childKeyPath,
+                Message: "is not a known key",
+            }
+
+            *problems = append(*problems, problem)
+            continue
+        }
+
+        findUnknownConfigKeys(value, ft, childKeyPath, problems)
+    }
+}
+
+// indexYamlNodes Index the value nodes by their dotted key paths.
+func indexYamlNodes(node *yaml3.Node, keyPath string, positions map[string]*yaml3.Node) {
+    switch node.Kind {
+    case yaml3.DocumentNode:
+        for _, child := range node.Content {
+            indexYamlNodes(child, keyPath, positions)
+        }
+    case yaml3.MappingNode:
+        positions[keyPath] = node
+
+        for i := 0; i + 1 < len(node.Content); i += 2 {
+            indexYamlNodes(node.Content[i + 1], joinConfigKeyPath(keyPath, node.Content[i].Value), positions)
+        }
+    case yaml3.SequenceNode:
+        positions[keyPath] = node
+
+        for i, child := range node.Content {
+            indexYamlNodes(child, joinConfigKeyPath(keyPath, strconv.Itoa(i)), positions)
+        }
+    default:
+        positions[keyPath] = node
+    }
+}
+
+// collectConfigValidationProblems Apply the rules on every field of the
+// struct, descending into nested structs.
+func collectConfigValidationProblems(v reflect.Value, keyPath string, problems *[]ConfigValidationProblem) {
+    for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+        if v.IsNil() == true {
+            return
+        }
+
+        v = v.Elem()
+    }
+
+    switch v.Kind() {
+    case reflect.Struct:
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < v.Len(); i++ {
+            collectConfigValidationProblems(v.Index(i), joinConfigKeyPath(keyPath, strconv.Itoa(i)), problems)
+        }
+
+        return
+    default:
+        return
+    }
+
+    t := v.Type()
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        name, isInline := yamlFieldName(sf)
+        if name == "-" {
+            continue
+        }
+
+        fv := v.Field(i)
+
+        if isInline == true {
+            collectConfigValidationProblems(fv, keyPath, problems)
+            continue
+        }
+
+        fieldKeyPath := joinConfigKeyPath(keyPath, name)
+
+        for _, message := range checkConfigField(v, sf, fv) {
+            problem := ConfigValidationProblem{
+                KeyPath: fieldKeyPath,
+                Message: message,
+            }
+
+            *problems = append(*problems, problem)
+        }
+
+        collectConfigValidationProblems(fv, fieldKeyPath, problems)
+    }
+}
+
+// CheckValidationRules Return a message for every "validate" and "pattern"
+// rule that field `index` of the struct violates. This lets other binders (e.g.
+// for requests) apply the same rules as ValidateConfig.
+func CheckValidationRules(parent reflect.Value, index int) (messages []string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    messages = checkConfigField(parent, parent.Type().Field(index), parent.Field(index))
+    return messages, nil
+}
+
+// checkConfigField Return a message for every rule that the field violates.
+func checkConfigField(parent reflect.Value, sf reflect.StructField, fv reflect.Value) (messages []string) {
+    messages = make([]string, 0)
+
+    if pattern := sf.Tag.Get(ConfigTagPattern); pattern != "" && fv.Kind() == reflect.String {
+        re, err := regexp.Compile(pattern)
+        log.PanicIf(err)
+
+        if fv.String() != "" && re.MatchString(fv.String()) == false {
+            messages = append(messages, fmt.Sprintf("must match [%s]", pattern))
+        }
+    }
+
+    rules := sf.Tag.Get(ConfigTagValidate)
+    if rules == "" {
+        return messages
+    }
+
+    for _, rule := range strings.Split(rules, ",") {
+        name, argument := rule, ""
+        if i := strings.Index(rule, "="); i != -1 {
+            name, argument = rule[:i], rule[i + 1:]
+        }
+
+        switch name {
+        case "required":
+            if fv.IsZero() == true {
+                messages = append(messages, "is required")
+            }
+        case "min", "max":
+            target := fv
+            for target.Kind() == reflect.Ptr {
+                if target.IsNil() == true {
+                    // Absent values are the business of "required".
+                    break
+                }
+
+                target = target.Elem()
+            }
+
+            if target.Kind() == reflect.Ptr {
+                continue
+            }
+
+            limit, err := parseConfigLimit(target, argument)
+            if err != nil {
+                messages = append(messages, fmt.Sprintf("has a rule that is not valid: [%s]: %s", rule, err))
+                continue
+            }
+
+            actual := configMagnitude(target)
+
+            if name == "min" && actual < limit {
+                messages = append(messages, fmt.Sprintf("must be at least %s", argument))
+            } else if name == "max" && actual > limit {
+                messages = append(messages, fmt.Sprintf("must be at most %s", argument))
+            }
+        case "oneof":
+            if fv.IsZero() == true {
+                continue
+            }
+
+            allowed := strings.Split(argument, "|")
+            actual := fmt.Sprintf("%v", fv.Interface())
+
+            found := false
+            for _, candidate := range allowed {
+                if candidate == actual {
+                    found = true
+                    break
+                }
+            }
+
+            if found == false {
+                messages = append(messages, fmt.Sprintf("must be one of [%s]", strings.Join(allowed, ", ")))
+            }
+        case "requiredwith", "requiredwithout":
+            other := configSibling(parent, argument)
+
+            if name == "requiredwith" && other.IsZero() == false && fv.IsZero() == true {
+                messages = append(messages, fmt.Sprintf("is required when [%s] is set", argument))
+            } else if name == "requiredwithout" && other.IsZero() == true && fv.IsZero() == true {
+                messages = append(messages, fmt.Sprintf("is required when [%s] is not set", argument))
+            }
+        case "eqfield", "nefield", "gtfield", "ltfield":
+            other := configSibling(parent, argument)
+
+            if fv.IsZero() == true || other.IsZero() == true {
+                continue
+            }
+
+            switch name {
+            case "eqfield":
+                if reflect.DeepEqual(fv.Interface(), other.Interface()) == false {
+                    messages = append(messages, fmt.Sprintf("must equal [%s]", argument))
+                }
+            case "nefield":
+                if reflect.DeepEqual(fv.Interface(), other.Interface()) == true {
+                    messages = append(messages, fmt.Sprintf("must differ from [%s]", argument))
+                }
+            case "gtfield":
+                if configMagnitude(fv) <= configMagnitude(other) {
+                    messages = append(messages, fmt.Sprintf("must be greater than [%s]", argument))
+                }
+            case "ltfield":
+                if configMagnitude(fv) >= configMagnitude(other) {
+                    messages = append(messages, fmt.Sprintf("must be less than [%s]", argument))
+                }
+            }
+        default:
+            log.Panic(fmt.Errorf("config validation rule not valid: [%s]", rule))
+        }
+    }
+
+    return messages
+}
+
+// configSibling Return the named field of the struct containing the field
+// being validated.
+func configSibling(parent reflect.Value, name string) reflect.Value {
+    other := parent.FieldByName(name)
+    if other.IsValid() == false {
+        log.Panic(fmt.Errorf("config validation refers to unknown field: [%s]", name))
+    }
+
+    return other
+}
+
+// configMagnitude Return the value of a number, or the length of a string,
+// slice, or map.
+func configMagnitude(v reflect.Value) float64 {
+    switch v.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(v.Int())
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return float64(v.Uint())
+    case reflect.Float32, reflect.Float64:
+        return v.Float()
+    case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+        return float64(v.Len())
+    }
+
+    log.Panic(fmt.Errorf("config value has no magnitude: [%s]", v.Type()))
+    return 0
+}
+
+// parseConfigLimit Parse the argument of a "min" or "max" rule.
+func parseConfigLimit(fv reflect.Value, argument string) (limit float64, err error) {
+    if fv.Type() == durationType {
+        duration, err := time.ParseDuration(argument)
+        if err != nil {
+            return 0, fmt.Errorf("duration limits need a unit (e.g. \"30s\")")
+        }
+
+        return float64(duration), nil
+    }
+
+    limit, err = strconv.ParseFloat(argument, 64)
+    if err != nil {
+        return 0, fmt.Errorf("limit is not a number")
+    }
+
+    return limit, nil
+}
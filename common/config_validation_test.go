@@ -0,0 +1,192 @@
+package ricommon
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "io/ioutil"
+
+    "golang.org/x/net/context"
+)
+
+type validationTestServer struct {
+    Host string `yaml:"host" validate:"required"`
+    Port int `yaml:"port" validate:"min=1,max=65535"`
+}
+
+type validationTestConfig struct {
+    Mode string `yaml:"mode" validate:"oneof=fast|slow"`
+    Name string `yaml:"name" pattern:"^[a-z]+$"`
+    Server validationTestServer `yaml:"server"`
+    Username string `yaml:"username" validate:"requiredwith=Password"`
+    Password string `yaml:"password"`
+    Low int `yaml:"low" validate:"ltfield=High"`
+    High int `yaml:"high"`
+}
+
+// writeValidationTestConfig Write the YAML to a temporary file and return its
+// path.
+func writeValidationTestConfig(t *testing.T, data string) string {
+    f, err := ioutil.TempFile("", "config_validation_test.*.yaml")
+    if err != nil {
+        t.Fatalf("Temporary file not created: %s", err)
+    }
+
+    t.Cleanup(func() {
+        os.Remove(f.Name())
+    })
+
+    _, err = f.WriteString(data)
+    f.Close()
+
+    if err != nil {
+        t.Fatalf("Temporary file not written: %s", err)
+    }
+
+    return f.Name()
+}
+
+func TestGetStaticConfigStrict(t *testing.T) {
+    filepath := writeValidationTestConfig(t, "mode: fast\nname: abc\nserver:\n  host: localhost\n  port: 80\n")
+
+    config := new(validationTestConfig)
+
+    err := GetStaticConfigStrict(context.Background(), filepath, config)
+    if err != nil {
+        t.Fatalf("Config not loaded: %s", err)
+    } else if config.Mode != "fast" || config.Server.Host != "localhost" || config.Server.Port != 80 {
+        t.Fatalf("Config not correct: %v", config)
+    }
+}
+
+func TestGetStaticConfigStrict_UnknownKey(t *testing.T) {
+    filepath := writeValidationTestConfig(t, "mode: fast\nserver:\n  host: localhost\n  prot: 80\n")
+
+    err := GetStaticConfigStrict(context.Background(), filepath, new(validationTestConfig))
+
+    cve, ok := err.(*ConfigValidationError)
+    if ok != true || len(cve.Problems) != 1 {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+
+    problem := cve.Problems[0]
+    if problem.KeyPath != "server.prot" || problem.Filepath != filepath || problem.Line != 4 || problem.Column != 9 {
+        t.Fatalf("Problem not correct: %v", problem)
+    }
+}
+
+func TestGetStaticConfigStrict_Positions(t *testing.T) {
+    filepath := writeValidationTestConfig(t, "mode: medium\nserver:\n  port: 70000\n")
+
+    err := GetStaticConfigStrict(context.Background(), filepath, new(validationTestConfig))
+
+    cve, ok := err.(*ConfigValidationError)
+    if ok != true {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+
+    positions := make([]string, len(cve.Problems))
+    for i, problem := range cve.Problems {
+        positions[i] = problem.String()
+    }
+
+    // The missing host points at its parent.
+    expected := []string {
+        filepath + ":1:7: [mode] must be one of [fast, slow]",
+        filepath + ":3:3: [server.host] is required",
+        filepath + ":3:9: [server.port] must be at most 65535",
+    }
+
+    if strings.Join(positions, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Problems not correct:\n%s", strings.Join(positions, "\n"))
+    }
+}
+
+func TestValidateConfig(t *testing.T) {
+    config := &validationTestConfig{
+        Mode: "slow",
+        Name: "ABC",
+        Server: validationTestServer{
+            Host: "localhost",
+            Port: 80,
+        },
+        Password: "hunter2",
+        Low: 5,
+        High: 1,
+    }
+
+    err := ValidateConfig(config)
+
+    cve, ok := err.(*ConfigValidationError)
+    if ok != true {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+
+    messages := make([]string, len(cve.Problems))
+    for i, problem := range cve.Problems {
+        messages[i] = problem.String()
+    }
+
+    expected := []string {
+        "[name] must match [^[a-z]+$]",
+        "[username] is required when [Password] is set",
+        "[low] must be less than [High]",
+    }
+
+    if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Problems not correct:\n%s", strings.Join(messages, "\n"))
+    }
+
+    config.Name = "abc"
+    config.Username = "admin"
+    config.Low = 0
+
+    if err := ValidateConfig(config); err != nil {
+        t.Fatalf("Config should be valid: %s", err)
+    }
+}
+
+func TestValidateConfig_MinMaxOnZeroValues(t *testing.T) {
+    type config struct {
+        Workers int `yaml:"workers" validate:"min=1"`
+        Offset int `yaml:"offset" validate:"min=-5,max=-1"`
+        Hosts []string `yaml:"hosts" validate:"min=1"`
+        Limit *int `yaml:"limit" validate:"min=1"`
+    }
+
+    err := ValidateConfig(&config{})
+    if err == nil {
+        t.Fatalf("Expected validation error.")
+    }
+
+    cve := err.(*ConfigValidationError)
+
+    keyPaths := make([]string, len(cve.Problems))
+    for i, problem := range cve.Problems {
+        keyPaths[i] = problem.KeyPath
+    }
+
+    if strings.Join(keyPaths, ",") != "workers,offset,hosts" {
+        t.Fatalf("Problems not correct: %v", cve.Problems)
+    }
+}
+
+func TestValidateConfig_DurationLimitWithoutUnit(t *testing.T) {
+    type config struct {
+        Timeout time.Duration `yaml:"timeout" validate:"min=30"`
+    }
+
+    err := ValidateConfig(&config{ Timeout: time.Minute })
+    if err == nil {
+        t.Fatalf("Expected validation error.")
+    }
+
+    cve, ok := err.(*ConfigValidationError)
+    if ok == false {
+        t.Fatalf("Expected a validation error, not: [%s]", err)
+    } else if len(cve.Problems) != 1 || cve.Problems[0].KeyPath != "timeout" {
+        t.Fatalf("Problems not correct: %v", cve.Problems)
+    }
+}
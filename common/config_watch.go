@@ -0,0 +1,277 @@
+package ricommon
+
+import (
+    "bytes"
+    "os"
+    "sync"
+    "time"
+
+    "io/ioutil"
+    "path/filepath"
+    "sync/atomic"
+
+    "golang.org/x/net/context"
+    "github.com/fsnotify/fsnotify"
+    "github.com/dsoprea/go-logging"
+)
+
+// Constants
+const (
+    // How often we check the file when we can't be notified of changes.
+    ConfigWatchPollInterval = time.Second * 5
+
+    // How long we wait for writes to settle before reloading.
+    ConfigWatchSettleDuration = time.Millisecond * 100
+)
+
+// Misc
+var (
+    cwLogger = log.NewLogger("ri.common.config_watch")
+)
+
+// ConfigValidator Return an error if the newly-loaded config can't be used.
+type ConfigValidator func(candidate interface{}) (err error)
+
+// ConfigSubscriber Is called with the previous and current config whenever a
+// new version is swapped in.
+type ConfigSubscriber func(previous, current interface{})
+
+// WatchedConfig Keeps a YAML config up-to-date with its file. New versions
+// are only swapped in if they load and validate, so readers always see a
+// complete, valid config.
+type WatchedConfig struct {
+    ctx context.Context
+    filepath string
+    factory func() interface{}
+    validate ConfigValidator
+
+    current atomic.Value
+
+    // The content that the current version was loaded from.
+    currentData []byte
+
+    subscribers []ConfigSubscriber
+    subscribersLocker sync.Mutex
+
+    // Serializes reloads, including the notifications, so that subscribers
+    // see the versions in the order that they were swapped in.
+    reloadLocker sync.Mutex
+
+    done chan struct{}
+    closeOnce sync.Once
+}
+
+// NewWatchedConfig Load the file and start watching it. `factory` must return
+// a pointer to a new, empty config struct each time that it's called. If
+// `validate` is nil, every version that loads is accepted. It's an error if
+// the initial version doesn't load or validate.
+func NewWatchedConfig(ctx context.Context, configFilepath string, factory func() interface{}, validate ConfigValidator) (wc *WatchedConfig, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    wc = &WatchedConfig{
+        ctx: ctx,
+        filepath: configFilepath,
+        factory: factory,
+        validate: validate,
+        subscribers: make([]ConfigSubscriber, 0),
+        done: make(chan struct{}),
+    }
+
+    // Stat before loading so that a change made during the load is caught.
+    last, _ := os.Stat(configFilepath)
+
+    initial, data, err := wc.load()
+    log.PanicIf(err)
+
+    wc.current.Store(initial)
+    wc.currentData = data
+
+    if watcher, err := wc.newWatcher(); err == nil {
+        go wc.watch(watcher, last)
+    } else {
+        cwLogger.Warningf(ctx, "Could not watch [%s] for changes (%s). Polling instead.", configFilepath, err)
+        go wc.poll(last)
+    }
+
+    return wc, nil
+}
+
+// Get Return the current config. The value must be treated as read-only.
+func (wc *WatchedConfig) Get() interface{} {
+    return wc.current.Load()
+}
+
+// Subscribe Register a function to be called after every change.
+func (wc *WatchedConfig) Subscribe(subscriber ConfigSubscriber) {
+    wc.subscribersLocker.Lock()
+    defer wc.subscribersLocker.Unlock()
+
+    wc.subscribers = append(wc.subscribers, subscriber)
+}
+
+// Close Stop watching the file.
+func (wc *WatchedConfig) Close() {
+    wc.closeOnce.Do(func() {
+        close(wc.done)
+    })
+}
+
+// Reload Load and validate the file and, if successful, swap it in and notify
+// the subscribers. The current version is kept if there is an error, and
+// nothing happens if the content of the file hasn't changed. Subscribers are
+// called with the reload lock held, so they must not call Reload.
+func (wc *WatchedConfig) Reload() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+            cwLogger.Errorf(wc.ctx, nil, "Could not reload config [%s]: [%s]", wc.filepath, err)
+        }
+    }()
+
+    wc.reloadLocker.Lock()
+    defer wc.reloadLocker.Unlock()
+
+    candidate, data, err := wc.load()
+    log.PanicIf(err)
+
+    if bytes.Equal(data, wc.currentData) == true {
+        cwLogger.Debugf(wc.ctx, "Config not changed: [%s]", wc.filepath)
+        return nil
+    }
+
+    previous := wc.current.Load()
+
+    wc.current.Store(candidate)
+    wc.currentData = data
+
+    cwLogger.Infof(wc.ctx, "Config reloaded: [%s]", wc.filepath)
+
+    wc.subscribersLocker.Lock()
+    subscribers := make([]ConfigSubscriber, len(wc.subscribers))
+    copy(subscribers, wc.subscribers)
+    wc.subscribersLocker.Unlock()
+
+    for _, subscriber := range subscribers {
+        subscriber(previous, candidate)
+    }
+
+    return nil
+}
+
+// load Load the file strictly (see GetStaticConfigStrict) and apply the
+// validator. The content that it was loaded from is also returned.
+func (wc *WatchedConfig) load() (candidate interface{}, data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    data, err = ioutil.ReadFile(wc.filepath)
+    log.PanicIf(err)
+
+    candidate = wc.factory()
+
+    err = parseStaticConfigStrict(wc.filepath, data, candidate)
+    log.PanicIf(err)
+
+    if wc.validate != nil {
+        err := wc.validate(candidate)
+        log.PanicIf(err)
+    }
+
+    return candidate, data, nil
+}
+
+// newWatcher Watch the directory rather than the file since editors and
+// config-management tools usually replace the file rather than write to it.
+func (wc *WatchedConfig) newWatcher() (watcher *fsnotify.Watcher, err error) {
+    watcher, err = fsnotify.NewWatcher()
+    if err != nil {
+        return nil, err
+    }
+
+    if err := watcher.Add(filepath.Dir(wc.filepath)); err != nil {
+        watcher.Close()
+        return nil, err
+    }
+
+    return watcher, nil
+}
+
+// watch Reload after any change in the directory that changes the file. The
+// whole directory is considered since the file may be replaced indirectly
+// (e.g. Kubernetes swaps a "..data" symlink when a ConfigMap is updated).
+func (wc *WatchedConfig) watch(watcher *fsnotify.Watcher, last os.FileInfo) {
+    defer watcher.Close()
+
+    // Reloads are deferred until the writes stop.
+    settle := time.NewTimer(ConfigWatchSettleDuration)
+    settle.Stop()
+
+    for {
+        select {
+        case <-wc.done:
+            settle.Stop()
+            return
+        case _, ok := <-watcher.Events:
+            if ok == false {
+                return
+            }
+
+            settle.Reset(ConfigWatchSettleDuration)
+        case err, ok := <-watcher.Errors:
+            if ok == false {
+                return
+            }
+
+            cwLogger.Warningf(wc.ctx, "Error while watching [%s]: [%s]", wc.filepath, err)
+        case <-settle.C:
+            if fi, changed := configFileChanged(wc.filepath, last); changed == true {
+                last = fi
+                wc.Reload()
+            }
+        }
+    }
+}
+
+func (wc *WatchedConfig) poll(last os.FileInfo) {
+    ticker := time.NewTicker(ConfigWatchPollInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-wc.done:
+            return
+        case <-ticker.C:
+            if fi, changed := configFileChanged(wc.filepath, last); changed == true {
+                last = fi
+                wc.Reload()
+            }
+        }
+    }
+}
+
+// configFileChanged Stat the file (following symlinks) and return whether it's
+// a different file, or has a different size or modification time, than
+// `last`. Missing files are not changes.
+func configFileChanged(configFilepath string, last os.FileInfo) (fi os.FileInfo, changed bool) {
+    fi, err := os.Stat(configFilepath)
+    if err != nil {
+        return last, false
+    }
+
+    if last == nil || os.SameFile(fi, last) == false {
+        return fi, true
+    }
+
+    if fi.ModTime().Equal(last.ModTime()) == false || fi.Size() != last.Size() {
+        return fi, true
+    }
+
+    return last, false
+}
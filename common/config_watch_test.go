@@ -0,0 +1,174 @@
+package ricommon
+
+import (
+    "fmt"
+    "os"
+    "testing"
+    "time"
+
+    "io/ioutil"
+    "path/filepath"
+
+    "golang.org/x/net/context"
+)
+
+type watchTestConfig struct {
+    Workers int `yaml:"workers" validate:"min=1"`
+}
+
+// newWatchTestConfig Write the config to a temporary directory and watch it.
+// Every version that's swapped in is sent to the returned channel.
+func newWatchTestConfig(t *testing.T, data string, validate ConfigValidator) (wc *WatchedConfig, configFilepath string, changes chan int) {
+    path, err := ioutil.TempDir("", "config_watch_test")
+    if err != nil {
+        t.Fatalf("Temporary directory not created: %s", err)
+    }
+
+    t.Cleanup(func() {
+        os.RemoveAll(path)
+    })
+
+    configFilepath = filepath.Join(path, "config.yaml")
+    writeWatchTestConfig(t, configFilepath, data)
+
+    factory := func() interface{} {
+        return new(watchTestConfig)
+    }
+
+    wc, err = NewWatchedConfig(context.Background(), configFilepath, factory, validate)
+    if err != nil {
+        t.Fatalf("Config not loaded: %s", err)
+    }
+
+    t.Cleanup(wc.Close)
+
+    changes = make(chan int, 10)
+    wc.Subscribe(func(previous, current interface{}) {
+        changes <- current.(*watchTestConfig).Workers
+    })
+
+    return wc, configFilepath, changes
+}
+
+func writeWatchTestConfig(t *testing.T, configFilepath string, data string) {
+    // Replace the file, as editors do.
+    temporaryFilepath := configFilepath + ".tmp"
+
+    if err := ioutil.WriteFile(temporaryFilepath, []byte(data), 0644); err != nil {
+        t.Fatalf("Config not written: %s", err)
+    } else if err := os.Rename(temporaryFilepath, configFilepath); err != nil {
+        t.Fatalf("Config not replaced: %s", err)
+    }
+}
+
+func getWatchTestWorkers(wc *WatchedConfig) int {
+    return wc.Get().(*watchTestConfig).Workers
+}
+
+func TestNewWatchedConfig_NotValid(t *testing.T) {
+    path, err := ioutil.TempDir("", "config_watch_test")
+    if err != nil {
+        t.Fatalf("Temporary directory not created: %s", err)
+    }
+
+    defer os.RemoveAll(path)
+
+    configFilepath := filepath.Join(path, "config.yaml")
+    writeWatchTestConfig(t, configFilepath, "workers: 0\n")
+
+    factory := func() interface{} {
+        return new(watchTestConfig)
+    }
+
+    if _, err := NewWatchedConfig(context.Background(), configFilepath, factory, nil); err == nil {
+        t.Fatalf("Expected error for a config that doesn't validate.")
+    }
+}
+
+func TestWatchedConfig_Reload(t *testing.T) {
+    wc, configFilepath, changes := newWatchTestConfig(t, "workers: 1\n", nil)
+
+    writeWatchTestConfig(t, configFilepath, "workers: 2\n")
+
+    if err := wc.Reload(); err != nil {
+        t.Fatalf("Config not reloaded: %s", err)
+    } else if workers := getWatchTestWorkers(wc); workers != 2 {
+        t.Fatalf("Config not swapped in: (%d)", workers)
+    } else if workers := <-changes; workers != 2 {
+        t.Fatalf("Subscriber not notified of the new version: (%d)", workers)
+    }
+
+    // The content hasn't changed.
+    if err := wc.Reload(); err != nil {
+        t.Fatalf("Config not reloaded: %s", err)
+    } else if len(changes) != 0 {
+        t.Fatalf("Subscriber should not have been notified.")
+    }
+}
+
+func TestWatchedConfig_Reload_KeepsCurrentOnError(t *testing.T) {
+    validate := func(candidate interface{}) error {
+        if candidate.(*watchTestConfig).Workers > 10 {
+            return fmt.Errorf("too many workers")
+        }
+
+        return nil
+    }
+
+    wc, configFilepath, changes := newWatchTestConfig(t, "workers: 1\n", validate)
+
+    versions := []string {
+        // Not valid YAML.
+        "workers: [\n",
+
+        // Unknown key.
+        "workers: 2\nthreads: 2\n",
+
+        // Violates a rule.
+        "workers: 0\n",
+
+        // Rejected by the validator.
+        "workers: 11\n",
+    }
+
+    for _, data := range versions {
+        writeWatchTestConfig(t, configFilepath, data)
+
+        if err := wc.Reload(); err == nil {
+            t.Fatalf("Expected error for [%s].", data)
+        } else if workers := getWatchTestWorkers(wc); workers != 1 {
+            t.Fatalf("Current version not kept for [%s]: (%d)", data, workers)
+        } else if len(changes) != 0 {
+            t.Fatalf("Subscriber should not have been notified for [%s].", data)
+        }
+    }
+
+    // A valid version is still picked-up afterward.
+    writeWatchTestConfig(t, configFilepath, "workers: 3\n")
+
+    if err := wc.Reload(); err != nil {
+        t.Fatalf("Config not reloaded: %s", err)
+    } else if workers := <-changes; workers != 3 {
+        t.Fatalf("Subscriber not notified of the new version: (%d)", workers)
+    }
+}
+
+func TestWatchedConfig_Watch(t *testing.T) {
+    wc, configFilepath, changes := newWatchTestConfig(t, "workers: 1\n", nil)
+
+    writeWatchTestConfig(t, configFilepath, "workers: 2\n")
+
+    // Long enough to also catch the change by polling.
+    select {
+    case workers := <-changes:
+        if workers != 2 {
+            t.Fatalf("Subscriber not notified of the new version: (%d)", workers)
+        }
+    case <-time.After(ConfigWatchPollInterval * 2):
+        t.Fatalf("Change not noticed.")
+    }
+
+    if workers := getWatchTestWorkers(wc); workers != 2 {
+        t.Fatalf("Config not swapped in: (%d)", workers)
+    }
+}
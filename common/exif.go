@@ -15,9 +15,15 @@ var (
 
 // ImageExif Describes information retrieved from EXIF
 type ImageExif struct {
+    // Timestamp In local time if the zone couldn't be determined (see
+    // TimestampMethod).
     Timestamp time.Time
     Latitude float64
     Longitude float64
+
+    // TimestampMethod How the zone of the timestamp was determined (one of
+    // the TimestampMethod* constants). Empty if there was no timestamp.
+    TimestampMethod string
 }
 
 func NewImageExifWithReader(r io.Reader) (ie *ImageExif, err error) {
@@ -27,20 +33,28 @@ func NewImageExifWithReader(r io.Reader) (ie *ImageExif, err error) {
         }
     }()
 
-    ie = new(ImageExif)
-
     x, err := exif.Decode(r)
     log.PanicIf(err)
 
-    exifTimestamp, err := x.DateTime()
-    if err == nil {
-        ie.Timestamp = exifTimestamp
-    }
+    ie = newImageExifWithExif(x)
+    return ie, nil
+}
+
+// newImageExifWithExif Extract the information that we care about from
+// decoded EXIF. Missing tags leave the corresponding fields at zero.
+func newImageExifWithExif(x *exif.Exif) (ie *ImageExif) {
+    ie = new(ImageExif)
 
+    loadExifOffsetTimes(x)
+
+    // The coordinates are loaded first since they may be needed to resolve
+    // the zone of the timestamp.
     exifLat, exifLong, err := x.LatLong()
     if err == nil {
         ie.Latitude, ie.Longitude = exifLat, exifLong
     }
 
-    return ie, nil
+    resolveExifTimestamp(x, ie)
+
+    return ie
 }
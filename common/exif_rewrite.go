@@ -0,0 +1,550 @@
+package ricommon
+
+import (
+    "bufio"
+    "bytes"
+    "fmt"
+    "io"
+    "math"
+
+    "encoding/binary"
+
+    "github.com/gansidui/geohash"
+    "github.com/dsoprea/go-logging"
+)
+
+// EXIF tag IDs
+const (
+    ExifTagExifIfdPointer = 0x8769
+    ExifTagGpsIfdPointer = 0x8825
+    ExifTagInteropIfdPointer = 0xa005
+
+    ExifTagMakerNote = 0x927c
+    ExifTagCameraOwnerName = 0xa430
+    ExifTagBodySerialNumber = 0xa431
+    ExifTagLensSerialNumber = 0xa435
+    ExifTagCameraSerialNumber = 0xc62f
+
+    ExifTagGpsVersionId = 0x0000
+    ExifTagGpsLatitudeRef = 0x0001
+    ExifTagGpsLatitude = 0x0002
+    ExifTagGpsLongitudeRef = 0x0003
+    ExifTagGpsLongitude = 0x0004
+    ExifTagGpsMapDatum = 0x0012
+)
+
+// JPEG markers
+const (
+    jpegMarkerSoi = 0xd8
+    jpegMarkerEoi = 0xd9
+    jpegMarkerSos = 0xda
+    jpegMarkerApp1 = 0xe1
+    jpegMarkerTem = 0x01
+    jpegMarkerRst0 = 0xd0
+    jpegMarkerRst7 = 0xd7
+)
+
+// Other
+var (
+    jpegExifPrefix = []byte("Exif\x00\x00")
+    jpegXmpPrefix = []byte("http://ns.adobe.com/xap/1.0/\x00")
+    jpegExtendedXmpPrefix = []byte("http://ns.adobe.com/xmp/extension/\x00")
+
+    // The sizes of each TIFF value-type, indexed by type ID.
+    tiffTypeSizes = []int { 0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4 }
+
+    // Tags that identify the specific camera (and, indirectly, its owner).
+    exifSerialNumberTags = []uint16 {
+        ExifTagCameraOwnerName,
+        ExifTagBodySerialNumber,
+        ExifTagLensSerialNumber,
+        ExifTagCameraSerialNumber,
+    }
+
+    // The GPS tags that we retain when coarsening the coordinates.
+    exifCoarseGpsTags = map[uint16]bool {
+        ExifTagGpsVersionId: true,
+        ExifTagGpsLatitudeRef: true,
+        ExifTagGpsLatitude: true,
+        ExifTagGpsLongitudeRef: true,
+        ExifTagGpsLongitude: true,
+        ExifTagGpsMapDatum: true,
+    }
+)
+
+// ExifRewriteOptions Describes which EXIF information to remove or replace
+// when rewriting an image.
+type ExifRewriteOptions struct {
+    // StripGps Remove the GPS IFD entirely.
+    StripGps bool
+
+    // GpsPrecision If nonzero (and StripGps is false), replace the coordinates
+    // with the center of the geohash cell of this precision and remove the
+    // remaining GPS tags (altitude, timestamps, bearings, etc..).
+    GpsPrecision int
+
+    // StripSerialNumbers Remove the body/lens serial-numbers and owner name.
+    StripSerialNumbers bool
+
+    // StripMakerNotes Remove the manufacturer-specific maker-notes, which
+    // frequently also embed serial-numbers.
+    StripMakerNotes bool
+
+    // StripXmp Drop XMP packets, which can duplicate any of the above.
+    StripXmp bool
+
+    // StripTags Additional tag IDs to remove from whichever IFD they are found
+    // in.
+    StripTags []uint16
+}
+
+// Mirrors what we want for any image that we serve publicly.
+var (
+    ExifRewritePrivacyOptions = ExifRewriteOptions{
+        StripGps: true,
+        StripSerialNumbers: true,
+        StripMakerNotes: true,
+        StripXmp: true,
+    }
+)
+
+// RewriteImageExifWithReader Copy the JPEG stream from `r` to `w` with the EXIF
+// tags selected by `options` removed or replaced. Tags are edited in place so
+// that the remaining offsets (and the maker-notes, if kept) stay valid and the
+// image data is copied without being re-encoded. Images without EXIF are
+// copied unchanged.
+func RewriteImageExifWithReader(r io.Reader, w io.Writer, options ExifRewriteOptions) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    br := bufio.NewReader(r)
+
+    soi := make([]byte, 2)
+    _, err = io.ReadFull(br, soi)
+    log.PanicIf(err)
+
+    if soi[0] != 0xff || soi[1] != jpegMarkerSoi {
+        log.Panic(fmt.Errorf("stream is not a JPEG"))
+    }
+
+    writeJpegBytes(w, soi)
+
+    for {
+        marker := readJpegMarker(br)
+
+        if marker == jpegMarkerEoi {
+            writeJpegBytes(w, []byte { 0xff, marker })
+
+            _, err := io.Copy(w, br)
+            log.PanicIf(err)
+
+            return nil
+        } else if marker == jpegMarkerTem || marker >= jpegMarkerRst0 && marker <= jpegMarkerRst7 {
+            // Standalone markers have no length or payload.
+            writeJpegBytes(w, []byte { 0xff, marker })
+            continue
+        }
+
+        header := make([]byte, 2)
+        _, err := io.ReadFull(br, header)
+        log.PanicIf(err)
+
+        length := int(binary.BigEndian.Uint16(header))
+        if length < 2 {
+            log.Panic(fmt.Errorf("JPEG segment length is invalid: (%d)", length))
+        }
+
+        payload := make([]byte, length - 2)
+        _, err = io.ReadFull(br, payload)
+        log.PanicIf(err)
+
+        if marker == jpegMarkerApp1 {
+            if bytes.HasPrefix(payload, jpegExifPrefix) == true {
+                tiff := payload[len(jpegExifPrefix):]
+                rewriteExifTiff(tiff, options)
+            } else if options.StripXmp == true && (bytes.HasPrefix(payload, jpegXmpPrefix) == true || bytes.HasPrefix(payload, jpegExtendedXmpPrefix) == true) {
+                exifLog.Debugf(nil, "Dropping XMP segment: (%d) bytes", len(payload))
+                continue
+            }
+        }
+
+        writeJpegBytes(w, []byte { 0xff, marker })
+        writeJpegBytes(w, header)
+        writeJpegBytes(w, payload)
+
+        // Everything after the start-of-scan header is entropy-coded image
+        // data. Copy it verbatim.
+        if marker == jpegMarkerSos {
+            _, err := io.Copy(w, br)
+            log.PanicIf(err)
+
+            return nil
+        }
+    }
+}
+
+// readJpegMarker Read the next marker, skipping any fill bytes.
+func readJpegMarker(br *bufio.Reader) byte {
+    b, err := br.ReadByte()
+    log.PanicIf(err)
+
+    if b != 0xff {
+        log.Panic(fmt.Errorf("JPEG marker expected: (0x%02x)", b))
+    }
+
+    for b == 0xff {
+        b, err = br.ReadByte()
+        log.PanicIf(err)
+    }
+
+    return b
+}
+
+func writeJpegBytes(w io.Writer, data []byte) {
+    if _, err := w.Write(data); err != nil {
+        log.Panic(err)
+    }
+}
+
+// rewriteExifTiff Apply the options to the TIFF structure embedded in the EXIF
+// segment. The buffer is modified in place and never changes size.
+func rewriteExifTiff(data []byte, options ExifRewriteOptions) {
+    et := newExifTiff(data)
+
+    stripped := make(map[uint16]bool)
+    for _, tag := range options.StripTags {
+        stripped[tag] = true
+    }
+
+    if options.StripSerialNumbers == true {
+        for _, tag := range exifSerialNumberTags {
+            stripped[tag] = true
+        }
+    }
+
+    if options.StripMakerNotes == true {
+        stripped[ExifTagMakerNote] = true
+    }
+
+    ifd0Offset := et.uint32At(4)
+    ifd0Entries, ifd1Offset := et.readIfd(ifd0Offset)
+
+    var exifOffset, gpsOffset uint32
+    for _, entry := range ifd0Entries {
+        if entry.tag == ExifTagExifIfdPointer {
+            exifOffset = et.uint32At(entry.position + 8)
+        } else if entry.tag == ExifTagGpsIfdPointer {
+            gpsOffset = et.uint32At(entry.position + 8)
+        }
+    }
+
+    stripGps := options.StripGps
+    if gpsOffset != 0 && stripGps == false && options.GpsPrecision > 0 {
+        if et.coarsenGps(gpsOffset, options.GpsPrecision) == false {
+            exifLog.Warningf(nil, "GPS coordinates could not be coarsened. Stripping them.")
+            stripGps = true
+        }
+    }
+
+    if gpsOffset != 0 && stripGps == true {
+        et.eraseIfd(gpsOffset)
+    }
+
+    et.filterIfd(ifd0Offset, func(tag uint16) bool {
+        return stripped[tag] == true || stripGps == true && tag == ExifTagGpsIfdPointer
+    })
+
+    if exifOffset != 0 {
+        exifEntries, _ := et.readIfd(exifOffset)
+        et.filterIfd(exifOffset, func(tag uint16) bool {
+            return stripped[tag] == true
+        })
+
+        for _, entry := range exifEntries {
+            if entry.tag == ExifTagInteropIfdPointer {
+                // The entry may have moved during filtering, so read the
+                // pointer from the copy.
+                interopOffset := et.order.Uint32(entry.raw[8:])
+                if interopOffset != 0 {
+                    et.filterIfd(interopOffset, func(tag uint16) bool {
+                        return stripped[tag] == true
+                    })
+                }
+            }
+        }
+    }
+
+    if ifd1Offset != 0 {
+        et.filterIfd(ifd1Offset, func(tag uint16) bool {
+            return stripped[tag] == true
+        })
+    }
+}
+
+// exifIfdEntry Describes one twelve-byte entry in an IFD.
+type exifIfdEntry struct {
+    tag uint16
+    typeId uint16
+    count uint32
+    position int
+    raw []byte
+}
+
+// exifTiff Provides in-place access to a TIFF structure.
+type exifTiff struct {
+    data []byte
+    order binary.ByteOrder
+}
+
+func newExifTiff(data []byte) *exifTiff {
+    if len(data) < 8 {
+        log.Panic(fmt.Errorf("EXIF data is too short: (%d)", len(data)))
+    }
+
+    var order binary.ByteOrder
+    if data[0] == 'I' && data[1] == 'I' {
+        order = binary.LittleEndian
+    } else if data[0] == 'M' && data[1] == 'M' {
+        order = binary.BigEndian
+    } else {
+        log.Panic(fmt.Errorf("EXIF byte-order not valid: [%s]", data[:2]))
+    }
+
+    return &exifTiff{
+        data: data,
+        order: order,
+    }
+}
+
+func (et *exifTiff) checkBounds(offset, size int) {
+    if offset < 0 || size < 0 || offset + size > len(et.data) {
+        log.Panic(fmt.Errorf("EXIF offset out of range: (%d) + (%d) > (%d)", offset, size, len(et.data)))
+    }
+}
+
+func (et *exifTiff) uint16At(offset int) uint16 {
+    et.checkBounds(offset, 2)
+    return et.order.Uint16(et.data[offset:])
+}
+
+func (et *exifTiff) uint32At(offset int) uint32 {
+    et.checkBounds(offset, 4)
+    return et.order.Uint32(et.data[offset:])
+}
+
+// readIfd Return the entries of the IFD at the given offset and the offset of
+// the IFD that follows it.
+func (et *exifTiff) readIfd(offset uint32) (entries []exifIfdEntry, nextOffset uint32) {
+    count := int(et.uint16At(int(offset)))
+    et.checkBounds(int(offset) + 2, count * 12 + 4)
+
+    entries = make([]exifIfdEntry, count)
+    for i := 0; i < count; i++ {
+        position := int(offset) + 2 + i * 12
+
+        raw := make([]byte, 12)
+        copy(raw, et.data[position:position + 12])
+
+        entries[i] = exifIfdEntry{
+            tag: et.order.Uint16(raw[0:]),
+            typeId: et.order.Uint16(raw[2:]),
+            count: et.order.Uint32(raw[4:]),
+            position: position,
+            raw: raw,
+        }
+    }
+
+    nextOffset = et.uint32At(int(offset) + 2 + count * 12)
+    return entries, nextOffset
+}
+
+// valueRange Return where the value of the entry lives and how large it is.
+func (et *exifTiff) valueRange(entry exifIfdEntry) (offset, size int) {
+    if int(entry.typeId) >= len(tiffTypeSizes) || tiffTypeSizes[entry.typeId] == 0 {
+        log.Panic(fmt.Errorf("EXIF tag (0x%04x) has invalid type: (%d)", entry.tag, entry.typeId))
+    }
+
+    size = tiffTypeSizes[entry.typeId] * int(entry.count)
+    if size <= 4 {
+        return entry.position + 8, size
+    }
+
+    offset = int(et.uint32At(entry.position + 8))
+    et.checkBounds(offset, size)
+
+    return offset, size
+}
+
+// zeroValue Erase the value of the entry if it's stored outside of the IFD.
+// Inline values are erased along with the entry.
+func (et *exifTiff) zeroValue(entry exifIfdEntry) {
+    offset, size := et.valueRange(entry)
+    if size <= 4 {
+        return
+    }
+
+    for i := offset; i < offset + size; i++ {
+        et.data[i] = 0
+    }
+}
+
+// filterIfd Remove the entries whose tags are matched by `isRemoved`, erasing
+// their values and compacting the IFD in place.
+func (et *exifTiff) filterIfd(offset uint32, isRemoved func(tag uint16) bool) {
+    entries, nextOffset := et.readIfd(offset)
+
+    kept := make([]exifIfdEntry, 0, len(entries))
+    for _, entry := range entries {
+        if isRemoved(entry.tag) == true {
+            exifLog.Debugf(nil, "Removing EXIF tag: (0x%04x)", entry.tag)
+            et.zeroValue(entry)
+        } else {
+            kept = append(kept, entry)
+        }
+    }
+
+    if len(kept) == len(entries) {
+        return
+    }
+
+    position := int(offset)
+    et.order.PutUint16(et.data[position:], uint16(len(kept)))
+    position += 2
+
+    for _, entry := range kept {
+        copy(et.data[position:], entry.raw)
+        position += 12
+    }
+
+    et.order.PutUint32(et.data[position:], nextOffset)
+    position += 4
+
+    // Erase the slack left behind by the removed entries.
+    end := int(offset) + 2 + len(entries) * 12 + 4
+    for i := position; i < end; i++ {
+        et.data[i] = 0
+    }
+}
+
+// eraseIfd Zero the IFD and all of its values.
+func (et *exifTiff) eraseIfd(offset uint32) {
+    entries, _ := et.readIfd(offset)
+
+    for _, entry := range entries {
+        et.zeroValue(entry)
+    }
+
+    end := int(offset) + 2 + len(entries) * 12 + 4
+    for i := int(offset); i < end; i++ {
+        et.data[i] = 0
+    }
+}
+
+// coarsenGps Replace the coordinates in the GPS IFD with the center of their
+// geohash cell at the given precision. Returns false if the existing
+// coordinates can not be used.
+func (et *exifTiff) coarsenGps(offset uint32, precision int) (ok bool) {
+    entries, _ := et.readIfd(offset)
+
+    var latitudeRef, latitude, longitudeRef, longitude *exifIfdEntry
+    for i, entry := range entries {
+        switch entry.tag {
+        case ExifTagGpsLatitudeRef:
+            latitudeRef = &entries[i]
+        case ExifTagGpsLatitude:
+            latitude = &entries[i]
+        case ExifTagGpsLongitudeRef:
+            longitudeRef = &entries[i]
+        case ExifTagGpsLongitude:
+            longitude = &entries[i]
+        }
+    }
+
+    if latitudeRef == nil || latitude == nil || longitudeRef == nil || longitude == nil {
+        return false
+    }
+
+    lat, ok := et.readGpsCoordinate(*latitudeRef, *latitude, 'S')
+    if ok == false {
+        return false
+    }
+
+    lng, ok := et.readGpsCoordinate(*longitudeRef, *longitude, 'W')
+    if ok == false {
+        return false
+    }
+
+    _, box := geohash.Encode(lat, lng, precision)
+
+    coarseLat := (box.MinLat + box.MaxLat) / 2
+    coarseLng := (box.MinLng + box.MaxLng) / 2
+
+    exifLog.Debugf(nil, "Coarsening GPS: (%f, %f) => (%f, %f)", lat, lng, coarseLat, coarseLng)
+
+    et.writeGpsCoordinate(*latitudeRef, *latitude, coarseLat, 'N', 'S')
+    et.writeGpsCoordinate(*longitudeRef, *longitude, coarseLng, 'E', 'W')
+
+    et.filterIfd(offset, func(tag uint16) bool {
+        return exifCoarseGpsTags[tag] == false
+    })
+
+    return true
+}
+
+// readGpsCoordinate Return the signed, decimal value of a degrees/minutes/
+// seconds coordinate.
+func (et *exifTiff) readGpsCoordinate(ref, value exifIfdEntry, negativeRef byte) (coordinate float64, ok bool) {
+    if ref.typeId != 2 || value.typeId != 5 || value.count != 3 {
+        return 0, false
+    }
+
+    offset, _ := et.valueRange(value)
+
+    parts := make([]float64, 3)
+    for i := range parts {
+        numerator := et.uint32At(offset + i * 8)
+        denominator := et.uint32At(offset + i * 8 + 4)
+        if denominator == 0 {
+            return 0, false
+        }
+
+        parts[i] = float64(numerator) / float64(denominator)
+    }
+
+    coordinate = parts[0] + parts[1] / 60 + parts[2] / 3600
+    if ref.raw[8] == negativeRef {
+        coordinate = -coordinate
+    }
+
+    return coordinate, true
+}
+
+// writeGpsCoordinate Overwrite an existing coordinate and its reference in
+// place.
+func (et *exifTiff) writeGpsCoordinate(ref, value exifIfdEntry, coordinate float64, positiveRef, negativeRef byte) {
+    if coordinate < 0 {
+        et.data[ref.position + 8] = negativeRef
+        coordinate = -coordinate
+    } else {
+        et.data[ref.position + 8] = positiveRef
+    }
+
+    degrees := math.Floor(coordinate)
+    minutes := math.Floor((coordinate - degrees) * 60)
+    seconds := ((coordinate - degrees) * 60 - minutes) * 60
+
+    offset, _ := et.valueRange(value)
+
+    rationals := []uint32 {
+        uint32(degrees), 1,
+        uint32(minutes), 1,
+        uint32(math.Round(seconds * 1000)), 1000,
+    }
+
+    for i, n := range rationals {
+        et.order.PutUint32(et.data[offset + i * 4:], n)
+    }
+}
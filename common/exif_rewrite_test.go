@@ -0,0 +1,203 @@
+package ricommon
+
+import (
+    "bytes"
+    "math"
+    "testing"
+
+    "image/jpeg"
+    "io/ioutil"
+    "path/filepath"
+
+    "github.com/gansidui/geohash"
+    "github.com/rwcarlsen/goexif/exif"
+)
+
+// The coordinates of the EXIF in the fixtures (47 36' 22.5" N, 122 19' 48" W).
+const (
+    testExifLatitude = 47.60625
+    testExifLongitude = -122.33
+)
+
+func getTestImage(t *testing.T, filename string) []byte {
+    raw, err := ioutil.ReadFile(filepath.Join("testdata", filename))
+    if err != nil {
+        t.Fatalf("Fixture not read: %s", err)
+    }
+
+    return raw
+}
+
+func isCoordinateClose(a, b float64) bool {
+    return math.Abs(a - b) < 0.000001
+}
+
+// rewriteTestImage Rewrite the JPEG fixture and check that the image data
+// survived.
+func rewriteTestImage(t *testing.T, options ExifRewriteOptions) (original, rewritten []byte) {
+    original = getTestImage(t, "exif.jpg")
+
+    b := new(bytes.Buffer)
+    if err := RewriteImageExifWithReader(bytes.NewReader(original), b, options); err != nil {
+        t.Fatalf("Image not rewritten: %s", err)
+    }
+
+    rewritten = b.Bytes()
+
+    if _, err := jpeg.Decode(bytes.NewReader(rewritten)); err != nil {
+        t.Fatalf("Rewritten image not valid: %s", err)
+    }
+
+    // Everything from the start-of-scan on is copied verbatim.
+    sos := []byte { 0xff, jpegMarkerSos }
+    if bytes.Equal(original[bytes.Index(original, sos):], rewritten[bytes.Index(rewritten, sos):]) != true {
+        t.Fatalf("Image data not preserved.")
+    }
+
+    return original, rewritten
+}
+
+// getTestExifTags Return the tags in IFD0 and the EXIF and GPS IFDs of the
+// JPEG, and the TIFF itself.
+func getTestExifTags(t *testing.T, image []byte) (tags map[uint16]exifIfdEntry, et *exifTiff) {
+    start := bytes.Index(image, jpegExifPrefix)
+    if start < 0 {
+        t.Fatalf("EXIF not found.")
+    }
+
+    et = newExifTiff(image[start + len(jpegExifPrefix):])
+    tags = make(map[uint16]exifIfdEntry)
+
+    ifd0Entries, _ := et.readIfd(et.uint32At(4))
+    for _, entry := range ifd0Entries {
+        tags[entry.tag] = entry
+
+        if entry.tag == ExifTagExifIfdPointer || entry.tag == ExifTagGpsIfdPointer {
+            entries, _ := et.readIfd(et.uint32At(entry.position + 8))
+            for _, subEntry := range entries {
+                tags[subEntry.tag] = subEntry
+            }
+        }
+    }
+
+    return tags, et
+}
+
+func TestRewriteImageExifWithReader_NoOptions(t *testing.T) {
+    original, rewritten := rewriteTestImage(t, ExifRewriteOptions{})
+
+    if bytes.Equal(original, rewritten) != true {
+        t.Fatalf("Image should not have changed.")
+    }
+}
+
+func TestRewriteImageExifWithReader_Privacy(t *testing.T) {
+    original, rewritten := rewriteTestImage(t, ExifRewritePrivacyOptions)
+
+    // Only the XMP segment is dropped. Everything else is edited in place.
+    xmpSegmentSize := len(original) - len(rewritten)
+    if xmpSegmentSize <= 0 || bytes.Contains(rewritten, jpegXmpPrefix) == true {
+        t.Fatalf("XMP not dropped.")
+    }
+
+    tags, _ := getTestExifTags(t, rewritten)
+
+    removed := []uint16 {
+        ExifTagGpsIfdPointer,
+        ExifTagGpsLatitude,
+        ExifTagMakerNote,
+        ExifTagBodySerialNumber,
+        ExifTagLensSerialNumber,
+    }
+
+    for _, tag := range removed {
+        if _, found := tags[tag]; found == true {
+            t.Fatalf("Tag (0x%04x) not removed.", tag)
+        }
+    }
+
+    // The erased values don't linger in the file.
+    for _, value := range []string { "MAKERNOT", "SN123456", "2021:06:01\x00" } {
+        if bytes.Contains(rewritten, []byte(value)) == true {
+            t.Fatalf("Value [%s] not erased.", value)
+        }
+    }
+
+    x, err := exif.Decode(bytes.NewReader(rewritten))
+    if err != nil {
+        t.Fatalf("EXIF not read: %s", err)
+    } else if _, _, err := x.LatLong(); err == nil {
+        t.Fatalf("Coordinates not removed.")
+    } else if _, err := x.DateTime(); err != nil {
+        t.Fatalf("Timestamp not preserved: %s", err)
+    }
+}
+
+func TestRewriteImageExifWithReader_CoarsenGps(t *testing.T) {
+    precision := 5
+
+    _, rewritten := rewriteTestImage(t, ExifRewriteOptions{ GpsPrecision: precision })
+
+    tags, _ := getTestExifTags(t, rewritten)
+
+    // Altitude and the GPS date-stamp.
+    for _, tag := range []uint16 { 0x0006, 0x001d } {
+        if _, found := tags[tag]; found == true {
+            t.Fatalf("GPS tag (0x%04x) not removed.", tag)
+        }
+    }
+
+    for _, tag := range []uint16 { ExifTagBodySerialNumber, ExifTagMakerNote } {
+        if _, found := tags[tag]; found == false {
+            t.Fatalf("Tag (0x%04x) should have been kept.", tag)
+        }
+    }
+
+    x, err := exif.Decode(bytes.NewReader(rewritten))
+    if err != nil {
+        t.Fatalf("EXIF not read: %s", err)
+    }
+
+    actualLatitude, actualLongitude, err := x.LatLong()
+    if err != nil {
+        t.Fatalf("Coordinates not read: %s", err)
+    }
+
+    _, box := geohash.Encode(testExifLatitude, testExifLongitude, precision)
+
+    // The center of the cell, to the precision of the rationals.
+    latitude := (box.MinLat + box.MaxLat) / 2
+    longitude := (box.MinLng + box.MaxLng) / 2
+
+    if isCoordinateClose(actualLatitude, latitude) != true || isCoordinateClose(actualLongitude, longitude) != true {
+        t.Fatalf("Coordinates not coarsened: (%f, %f) != (%f, %f)", actualLatitude, actualLongitude, latitude, longitude)
+    }
+}
+
+func TestRewriteImageExifWithReader_StripTags(t *testing.T) {
+    // Make.
+    _, rewritten := rewriteTestImage(t, ExifRewriteOptions{ StripTags: []uint16 { 0x010f } })
+
+    tags, et := getTestExifTags(t, rewritten)
+
+    if _, found := tags[0x010f]; found == true {
+        t.Fatalf("Tag not removed.")
+    } else if _, found := tags[ExifTagGpsLatitude]; found == false {
+        t.Fatalf("GPS should have been kept.")
+    }
+
+    // The thumbnail IFD is still linked.
+    _, ifd1Offset := et.readIfd(et.uint32At(4))
+    if ifd1Offset == 0 {
+        t.Fatalf("IFD1 not linked.")
+    }
+}
+
+func TestRewriteImageExifWithReader_NotJpeg(t *testing.T) {
+    b := new(bytes.Buffer)
+
+    err := RewriteImageExifWithReader(bytes.NewReader([]byte("GIF89a\x01\x00\x01\x00")), b, ExifRewritePrivacyOptions)
+    if err == nil {
+        t.Fatalf("Expected error for non-JPEG.")
+    }
+}
@@ -0,0 +1,180 @@
+package ricommon
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "time"
+
+    // Bundle the zone database so that zones resolved from coordinates can
+    // always be loaded.
+    _ "time/tzdata"
+
+    "github.com/bradfitz/latlong"
+    "github.com/rwcarlsen/goexif/exif"
+    "github.com/rwcarlsen/goexif/tiff"
+    "github.com/dsoprea/go-logging"
+)
+
+// Timestamp resolution methods
+const (
+    // The timestamp carried its own UTC offset (OffsetTime* tags or an XMP
+    // date with a zone).
+    TimestampMethodExplicitOffset = "explicit-offset"
+
+    // The zone was looked-up from the embedded GPS coordinates.
+    TimestampMethodGpsTimezone = "gps-timezone"
+
+    // There was no way to determine the zone and the wall-clock time was
+    // taken as local time (as goexif does).
+    TimestampMethodUnresolved = "unresolved"
+)
+
+// EXIF field names
+const (
+    ExifFieldOffsetTime exif.FieldName = "OffsetTime"
+    ExifFieldOffsetTimeOriginal exif.FieldName = "OffsetTimeOriginal"
+    ExifFieldOffsetTimeDigitized exif.FieldName = "OffsetTimeDigitized"
+)
+
+// Other
+var (
+    // goexif doesn't know about the EXIF 2.31 offset tags.
+    exifOffsetTimeFields = map[uint16]exif.FieldName {
+        0x9010: ExifFieldOffsetTime,
+        0x9011: ExifFieldOffsetTimeOriginal,
+        0x9012: ExifFieldOffsetTimeDigitized,
+    }
+)
+
+// loadExifOffsetTimes Load the offset tags from the EXIF sub-IFD into the
+// decoded EXIF. This isn't registered as a goexif parser since that would
+// change what exif.Decode returns for every caller in the process.
+func loadExifOffsetTimes(x *exif.Exif) {
+    tag, err := x.Get(exif.ExifIFDPointer)
+    if err != nil {
+        return
+    }
+
+    offset, err := tag.Int64(0)
+    if err != nil {
+        return
+    }
+
+    r := bytes.NewReader(x.Raw)
+    if _, err := r.Seek(offset, 0); err != nil {
+        return
+    }
+
+    // goexif has already reported any problems with this IFD, and everything
+    // else that it decoded is still good.
+    subDir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+    if err != nil {
+        exifLog.Warningf(nil, "Could not decode EXIF sub-IFD for offsets: [%s]", err)
+        return
+    }
+
+    x.LoadTags(subDir, exifOffsetTimeFields, false)
+}
+
+// LookupTimezoneForCoordinates Return the zone that the given coordinates fall
+// within. Uses bundled boundary data and never touches the network.
+func LookupTimezoneForCoordinates(latitude, longitude float64) (location *time.Location, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    name := latlong.LookupZoneName(latitude, longitude)
+    if name == "" {
+        log.Panic(ErrNotFound)
+    }
+
+    location, err = time.LoadLocation(name)
+    log.PanicIf(err)
+
+    return location, nil
+}
+
+// resolveExifTimestamp Set the timestamp on `ie` from DateTimeOriginal (or
+// DateTime), using the matching offset tag, if present (see
+// loadExifOffsetTimes), or the coordinates already on `ie`. Leaves the timestamp zero if there was none.
+func resolveExifTimestamp(x *exif.Exif, ie *ImageExif) {
+    timestampField, offsetField := exif.DateTimeOriginal, ExifFieldOffsetTimeOriginal
+
+    tag, err := x.Get(timestampField)
+    if err != nil {
+        timestampField, offsetField = exif.DateTime, ExifFieldOffsetTime
+
+        if tag, err = x.Get(timestampField); err != nil {
+            return
+        }
+    }
+
+    raw, err := tag.StringVal()
+    if err != nil {
+        return
+    }
+
+    wallClock, err := time.Parse("2006:01:02 15:04:05", strings.TrimRight(raw, "\x00 "))
+    if err != nil {
+        exifLog.Warningf(nil, "EXIF timestamp not valid: [%s]", raw)
+        return
+    }
+
+    if tag, err := x.Get(offsetField); err == nil {
+        if raw, err := tag.StringVal(); err == nil {
+            if location, err := parseExifOffset(raw); err == nil {
+                ie.Timestamp = inLocation(wallClock, location)
+                ie.TimestampMethod = TimestampMethodExplicitOffset
+
+                return
+            }
+        }
+    }
+
+    resolveWallClockTimestamp(wallClock, ie)
+}
+
+// resolveWallClockTimestamp Set the timestamp on `ie` from a zoneless wall-
+// clock time, inferring the zone from the coordinates already on `ie`. Zero
+// coordinates are taken to mean that there weren't any.
+func resolveWallClockTimestamp(wallClock time.Time, ie *ImageExif) {
+    if ie.Latitude != 0 || ie.Longitude != 0 {
+        location, err := LookupTimezoneForCoordinates(ie.Latitude, ie.Longitude)
+        if err == nil {
+            ie.Timestamp = inLocation(wallClock, location)
+            ie.TimestampMethod = TimestampMethodGpsTimezone
+
+            return
+        }
+
+        exifLog.Warningf(nil, "Could not find zone for (%f, %f): [%s]", ie.Latitude, ie.Longitude, err)
+    }
+
+    ie.Timestamp = inLocation(wallClock, time.Local)
+    ie.TimestampMethod = TimestampMethodUnresolved
+}
+
+// parseExifOffset Parse an offset tag (e.g. "+09:00").
+func parseExifOffset(raw string) (location *time.Location, err error) {
+    raw = strings.TrimRight(raw, "\x00 ")
+
+    offset, err := time.Parse("-07:00", raw)
+    if err != nil {
+        return nil, fmt.Errorf("EXIF offset not valid: [%s]", raw)
+    }
+
+    _, seconds := offset.Zone()
+    return time.FixedZone(raw, seconds), nil
+}
+
+// inLocation Return the instant at which the wall-clock reads the same time in
+// the given location.
+func inLocation(wallClock time.Time, location *time.Location) time.Time {
+    return time.Date(
+            wallClock.Year(), wallClock.Month(), wallClock.Day(),
+            wallClock.Hour(), wallClock.Minute(), wallClock.Second(), wallClock.Nanosecond(),
+            location)
+}
@@ -0,0 +1,119 @@
+package ricommon
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "github.com/rwcarlsen/goexif/exif"
+    "github.com/dsoprea/go-logging"
+)
+
+func TestLookupTimezoneForCoordinates(t *testing.T) {
+    location, err := LookupTimezoneForCoordinates(testExifLatitude, testExifLongitude)
+    if err != nil {
+        t.Fatalf("Zone not found: %s", err)
+    } else if location.String() != "America/Los_Angeles" {
+        t.Fatalf("Zone not correct: [%s]", location)
+    }
+
+    location, err = LookupTimezoneForCoordinates(59.325, 18.07)
+    if err != nil {
+        t.Fatalf("Zone not found: %s", err)
+    } else if location.String() != "Europe/Stockholm" {
+        t.Fatalf("Zone not correct: [%s]", location)
+    }
+
+    // The middle of the Atlantic.
+    if _, err := LookupTimezoneForCoordinates(0, -30); log.Is(err, ErrNotFound) != true {
+        t.Fatalf("Expected not-found error: [%v]", err)
+    }
+}
+
+func TestResolveWallClockTimestamp_GpsTimezone(t *testing.T) {
+    wallClock := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+    ie := &ImageExif{
+        Latitude: testExifLatitude,
+        Longitude: testExifLongitude,
+    }
+
+    resolveWallClockTimestamp(wallClock, ie)
+
+    // Daylight-saving time (-07:00) applies in June.
+    timestamp := time.Date(2021, 6, 1, 19, 0, 0, 0, time.UTC)
+
+    if ie.Timestamp.Equal(timestamp) != true {
+        t.Fatalf("Timestamp not correct: [%s]", ie.Timestamp)
+    } else if ie.Timestamp.Location().String() != "America/Los_Angeles" {
+        t.Fatalf("Zone not correct: [%s]", ie.Timestamp.Location())
+    } else if ie.TimestampMethod != TimestampMethodGpsTimezone {
+        t.Fatalf("Method not correct: [%s]", ie.TimestampMethod)
+    }
+}
+
+func TestResolveWallClockTimestamp_Unresolved(t *testing.T) {
+    wallClock := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+    // No coordinates, and coordinates without a zone.
+    for _, ie := range []*ImageExif { &ImageExif{}, &ImageExif{ Latitude: 0, Longitude: -30 } } {
+        resolveWallClockTimestamp(wallClock, ie)
+
+        if ie.Timestamp.Location() != time.Local {
+            t.Fatalf("Unresolved timestamp should be in local time: [%s]", ie.Timestamp.Location())
+        } else if ie.Timestamp.Equal(time.Date(2021, 6, 1, 12, 0, 0, 0, time.Local)) != true {
+            t.Fatalf("Timestamp not correct: [%s]", ie.Timestamp)
+        } else if ie.TimestampMethod != TimestampMethodUnresolved {
+            t.Fatalf("Method not correct: [%s]", ie.TimestampMethod)
+        }
+    }
+}
+
+func TestNewImageExifWithXmp_GpsTimezone(t *testing.T) {
+    packet := bytes.Replace(getTestImage(t, "metadata.xmp"), []byte("05:06:07+01:00"), []byte("05:06:07"), 1)
+
+    ie, err := newImageExifWithXmp(packet)
+    if err != nil {
+        t.Fatalf("XMP not read: %s", err)
+    }
+
+    // Stockholm is at +01:00 in March.
+    timestamp := time.Date(2022, 3, 4, 4, 6, 7, 0, time.UTC)
+
+    if ie.Timestamp.Equal(timestamp) != true {
+        t.Fatalf("Timestamp not correct: [%s]", ie.Timestamp)
+    } else if ie.TimestampMethod != TimestampMethodGpsTimezone {
+        t.Fatalf("Method not correct: [%s]", ie.TimestampMethod)
+    }
+}
+
+func TestLoadExifOffsetTimes(t *testing.T) {
+    x, err := exif.Decode(bytes.NewReader(getTestImage(t, "exif.jpg")))
+    if err != nil {
+        t.Fatalf("EXIF not read: %s", err)
+    }
+
+    // Decoding by itself is left as goexif does it.
+    if _, err := x.Get(ExifFieldOffsetTimeOriginal); err == nil {
+        t.Fatalf("Offset should not have been loaded by the decoder.")
+    }
+
+    loadExifOffsetTimes(x)
+
+    tag, err := x.Get(ExifFieldOffsetTimeOriginal)
+    if err != nil {
+        t.Fatalf("Offset not loaded: %s", err)
+    }
+
+    raw, err := tag.StringVal()
+    if err != nil {
+        t.Fatalf("Offset not read: %s", err)
+    }
+
+    location, err := parseExifOffset(raw)
+    if err != nil {
+        t.Fatalf("Offset not valid: %s", err)
+    } else if _, seconds := time.Date(2021, 1, 1, 0, 0, 0, 0, location).Zone(); seconds != 2 * 60 * 60 {
+        t.Fatalf("Offset not correct: (%d)", seconds)
+    }
+}
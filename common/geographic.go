@@ -2,6 +2,8 @@ package ricommon
 
 import (
     "fmt"
+    "math"
+    "strings"
 
     "google.golang.org/appengine"
 
@@ -26,6 +28,11 @@ const (
     // The precision to use when checking for redundancy/duplicates.
 // TODO(dustin): !! Make sure that this is sufficient for two similar locations near the equator.
     GeohashIdenticalMatchPrecision = 8
+
+    // The mean radius of the Earth, in meters.
+    EarthRadiusMeters = 6371008.8
+
+    geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
 )
 
 // GetBoundingGeohashPrefixForBox Return a Geohash at the right precision to 
@@ -84,3 +91,65 @@ func EncodeCoordinatesToGeohash(latitude, longitude float64, precision int) (has
 
     return hash, nil
 }
+
+// DecodeGeohash Return the box that the geohash describes.
+func DecodeGeohash(hash string) (box *geohash.Box, err error) {
+    if hash == "" || len(hash) > GeohashMaxPrecision {
+        return nil, fmt.Errorf("geohash not valid: [%s]", hash)
+    }
+
+    box = &geohash.Box{
+        MinLat: -90,
+        MaxLat: 90,
+        MinLng: -180,
+        MaxLng: 180,
+    }
+
+    // Bits alternate between longitude and latitude, starting with longitude.
+    isLongitude := true
+
+    for _, char := range strings.ToLower(hash) {
+        value := strings.IndexRune(geohashAlphabet, char)
+        if value == -1 {
+            return nil, fmt.Errorf("geohash not valid: [%s]", hash)
+        }
+
+        for bit := 4; bit >= 0; bit-- {
+            isSet := value & (1 << uint(bit)) != 0
+
+            if isLongitude == true {
+                middle := (box.MinLng + box.MaxLng) / 2
+                if isSet == true {
+                    box.MinLng = middle
+                } else {
+                    box.MaxLng = middle
+                }
+            } else {
+                middle := (box.MinLat + box.MaxLat) / 2
+                if isSet == true {
+                    box.MinLat = middle
+                } else {
+                    box.MaxLat = middle
+                }
+            }
+
+            isLongitude = !isLongitude
+        }
+    }
+
+    return box, nil
+}
+
+// DistanceBetweenCoordinates Return the great-circle distance, in meters,
+// between the two coordinates.
+func DistanceBetweenCoordinates(latitude1, longitude1, latitude2, longitude2 float64) float64 {
+    toRadians := math.Pi / 180
+
+    dLat := (latitude2 - latitude1) * toRadians
+    dLng := (longitude2 - longitude1) * toRadians
+
+    a := math.Sin(dLat / 2) * math.Sin(dLat / 2) +
+         math.Cos(latitude1 * toRadians) * math.Cos(latitude2 * toRadians) * math.Sin(dLng / 2) * math.Sin(dLng / 2)
+
+    return EarthRadiusMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1 - a))
+}
@@ -0,0 +1,275 @@
+package ricommon
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "time"
+
+    "encoding/xml"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Constants
+const (
+    // The accuracy, in meters, that we assume for a recorded GPS fix. GPX
+    // doesn't reliably carry this.
+    GeotagDefaultPointAccuracy = 10.0
+)
+
+// Other
+var (
+    geotagLog = log.NewLogger("ri.common.geotag")
+)
+
+// GpxTrackPoint Describes one timestamped fix from a GPX track.
+type GpxTrackPoint struct {
+    Timestamp time.Time
+    Latitude float64
+    Longitude float64
+    Elevation float64
+
+    // Segment The index of the track-segment this point came from. We don't
+    // interpolate between segments.
+    Segment int
+}
+
+// GpxTrack Describes all of the timestamped points in a GPX file, in
+// chronological order.
+type GpxTrack struct {
+    Points []GpxTrackPoint
+}
+
+type gpxPoint struct {
+    Latitude float64 `xml:"lat,attr"`
+    Longitude float64 `xml:"lon,attr"`
+    Elevation float64 `xml:"ele"`
+    Time string `xml:"time"`
+}
+
+type gpxSegment struct {
+    Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxTrack struct {
+    Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxDocument struct {
+    Tracks []gpxTrack `xml:"trk"`
+}
+
+// NewGpxTrackWithReader Parse a GPX document. Points without a timestamp are
+// ignored since they can't be matched to photos.
+func NewGpxTrackWithReader(r io.Reader) (gt *GpxTrack, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    doc := new(gpxDocument)
+
+    d := xml.NewDecoder(r)
+    err = d.Decode(doc)
+    log.PanicIf(err)
+
+    gt = &GpxTrack{
+        Points: make([]GpxTrackPoint, 0),
+    }
+
+    segment := 0
+    for _, track := range doc.Tracks {
+        for _, s := range track.Segments {
+            for _, p := range s.Points {
+                if p.Time == "" {
+                    continue
+                }
+
+                timestamp, err := time.Parse(time.RFC3339Nano, p.Time)
+                if err != nil {
+                    log.Panic(fmt.Errorf("GPX timestamp not valid: [%s]", p.Time))
+                }
+
+                gtp := GpxTrackPoint{
+                    Timestamp: timestamp,
+                    Latitude: p.Latitude,
+                    Longitude: p.Longitude,
+                    Elevation: p.Elevation,
+                    Segment: segment,
+                }
+
+                gt.Points = append(gt.Points, gtp)
+            }
+
+            segment++
+        }
+    }
+
+    sort.SliceStable(gt.Points, func(i, j int) bool {
+        return gt.Points[i].Timestamp.Before(gt.Points[j].Timestamp)
+    })
+
+    return gt, nil
+}
+
+// NewGpxTrackWithFile Parse the GPX file at the given path.
+func NewGpxTrackWithFile(filepath string) (gt *GpxTrack, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    f, err := os.Open(filepath)
+    log.PanicIf(err)
+
+    defer f.Close()
+
+    gt, err = NewGpxTrackWithReader(f)
+    log.PanicIf(err)
+
+    return gt, nil
+}
+
+// GeotagResult Describes the position that was determined for a photo.
+type GeotagResult struct {
+    Latitude float64
+    Longitude float64
+
+    // Accuracy The estimated radius of error, in meters.
+    Accuracy float64
+}
+
+// Geotagger Locates timestamps within a GPS track.
+type Geotagger struct {
+    track *GpxTrack
+    clockOffset time.Duration
+    maxGap time.Duration
+}
+
+// NewGeotagger Return a geotagger for the given track. `clockOffset` is added
+// to every photo timestamp to correct the camera clock to the time of the
+// track. A photo is only tagged if the fixes on either side of it are no more
+// than `maxGap` apart (zero means no limit).
+func NewGeotagger(track *GpxTrack, clockOffset, maxGap time.Duration) *Geotagger {
+    return &Geotagger{
+        track: track,
+        clockOffset: clockOffset,
+        maxGap: maxGap,
+    }
+}
+
+// Locate Interpolate the position at the given camera timestamp. Returns
+// ErrNotFound if the timestamp falls outside of the track or within a gap.
+func (g *Geotagger) Locate(timestamp time.Time) (result *GeotagResult, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    points := g.track.Points
+    actual := timestamp.Add(g.clockOffset)
+
+    // Find the first point at or after the timestamp.
+    n := len(points)
+    j := sort.Search(n, func(i int) bool {
+        return points[i].Timestamp.Before(actual) == false
+    })
+
+    if j == n {
+        log.Panic(ErrNotFound)
+    }
+
+    after := points[j]
+    if after.Timestamp.Equal(actual) == true {
+        result = &GeotagResult{
+            Latitude: after.Latitude,
+            Longitude: after.Longitude,
+            Accuracy: GeotagDefaultPointAccuracy,
+        }
+
+        return result, nil
+    } else if j == 0 {
+        log.Panic(ErrNotFound)
+    }
+
+    before := points[j - 1]
+
+    gap := after.Timestamp.Sub(before.Timestamp)
+    if before.Segment != after.Segment || g.maxGap != 0 && gap > g.maxGap {
+        geotagLog.Debugf(nil, "Timestamp falls in a gap: [%s] (%s)", actual, gap)
+        log.Panic(ErrNotFound)
+    }
+
+    fraction := float64(actual.Sub(before.Timestamp)) / float64(gap)
+
+    // Interpolate across the antimeridian the short way.
+    afterLongitude := after.Longitude
+    if afterLongitude - before.Longitude > 180 {
+        afterLongitude -= 360
+    } else if before.Longitude - afterLongitude > 180 {
+        afterLongitude += 360
+    }
+
+    latitude := before.Latitude + (after.Latitude - before.Latitude) * fraction
+    longitude := before.Longitude + (afterLongitude - before.Longitude) * fraction
+
+    if longitude > 180 {
+        longitude -= 360
+    } else if longitude < -180 {
+        longitude += 360
+    }
+
+    // We don't know the actual path taken between the fixes, so our
+    // confidence drops with our distance from the nearest one.
+    distanceBefore := DistanceBetweenCoordinates(before.Latitude, before.Longitude, latitude, longitude)
+    distanceAfter := DistanceBetweenCoordinates(after.Latitude, after.Longitude, latitude, longitude)
+
+    nearest := distanceBefore
+    if distanceAfter < nearest {
+        nearest = distanceAfter
+    }
+
+    result = &GeotagResult{
+        Latitude: latitude,
+        Longitude: longitude,
+        Accuracy: GeotagDefaultPointAccuracy + nearest,
+    }
+
+    return result, nil
+}
+
+// Tag Locate each of the photos. The results correspond to the given images
+// and will be nil for any that couldn't be located.
+func (g *Geotagger) Tag(images []*ImageExif) (results []*GeotagResult, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    results = make([]*GeotagResult, len(images))
+
+    for i, ie := range images {
+        if ie == nil || ie.Timestamp.IsZero() == true {
+            continue
+        }
+
+        result, err := g.Locate(ie.Timestamp)
+        if err != nil {
+            if log.Is(err, ErrNotFound) == true {
+                continue
+            }
+
+            log.Panic(err)
+        }
+
+        results[i] = result
+    }
+
+    return results, nil
+}
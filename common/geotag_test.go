@@ -0,0 +1,184 @@
+package ricommon
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Two segments: a walk north along the prime meridian, and a crossing of the
+// antimeridian and back. The points are out of order and one has no
+// timestamp.
+const testGpx = `<?xml version="1.0"?>
+<gpx version="1.1">
+ <trk>
+  <trkseg>
+   <trkpt lat="10" lon="0"><ele>100</ele><time>2021-06-01T12:00:00Z</time></trkpt>
+   <trkpt lat="12" lon="0"><time>2021-06-01T12:20:00Z</time></trkpt>
+   <trkpt lat="11" lon="0"><time>2021-06-01T12:10:00Z</time></trkpt>
+   <trkpt lat="50" lon="50"></trkpt>
+  </trkseg>
+  <trkseg>
+   <trkpt lat="0" lon="179"><time>2021-06-01T14:00:00Z</time></trkpt>
+   <trkpt lat="0" lon="-179"><time>2021-06-01T14:10:00Z</time></trkpt>
+   <trkpt lat="0" lon="179.5"><time>2021-06-01T14:20:00Z</time></trkpt>
+  </trkseg>
+ </trk>
+</gpx>`
+
+func newTestGeotagger(t *testing.T, clockOffset, maxGap time.Duration) *Geotagger {
+    gt, err := NewGpxTrackWithReader(strings.NewReader(testGpx))
+    if err != nil {
+        t.Fatalf("Track not read: %s", err)
+    }
+
+    return NewGeotagger(gt, clockOffset, maxGap)
+}
+
+func getTestGeotagTime(minutes int) time.Time {
+    return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC).Add(time.Minute * time.Duration(minutes))
+}
+
+func TestNewGpxTrackWithReader(t *testing.T) {
+    gt, err := NewGpxTrackWithReader(strings.NewReader(testGpx))
+    if err != nil {
+        t.Fatalf("Track not read: %s", err)
+    } else if len(gt.Points) != 6 {
+        t.Fatalf("Point count not correct: (%d)", len(gt.Points))
+    }
+
+    if gt.Points[1].Latitude != 11 || gt.Points[2].Latitude != 12 {
+        t.Fatalf("Points not sorted: %v", gt.Points)
+    } else if gt.Points[0].Elevation != 100 || gt.Points[0].Segment != 0 || gt.Points[3].Segment != 1 {
+        t.Fatalf("Point not correct: %v", gt.Points[0])
+    }
+
+    if _, err := NewGpxTrackWithReader(strings.NewReader(`<gpx><trk><trkseg><trkpt lat="0" lon="0"><time>noon</time></trkpt></trkseg></trk></gpx>`)); err == nil {
+        t.Fatalf("Expected error for a timestamp that's not valid.")
+    }
+}
+
+func TestGeotagger_Locate(t *testing.T) {
+    g := newTestGeotagger(t, 0, 0)
+
+    // On a fix.
+    result, err := g.Locate(getTestGeotagTime(10))
+    if err != nil {
+        t.Fatalf("Not located: %s", err)
+    } else if result.Latitude != 11 || result.Longitude != 0 || result.Accuracy != GeotagDefaultPointAccuracy {
+        t.Fatalf("Result not correct: %v", result)
+    }
+
+    // A quarter of the way between two fixes.
+    result, err = g.Locate(getTestGeotagTime(12).Add(time.Second * 30))
+    if err != nil {
+        t.Fatalf("Not located: %s", err)
+    } else if isCoordinateClose(result.Latitude, 11.25) != true || result.Longitude != 0 {
+        t.Fatalf("Result not correct: %v", result)
+    }
+
+    // A quarter of a degree of latitude from the nearest fix.
+    expectedAccuracy := GeotagDefaultPointAccuracy + DistanceBetweenCoordinates(11, 0, 11.25, 0)
+    if result.Accuracy < expectedAccuracy - 1 || result.Accuracy > expectedAccuracy + 1 {
+        t.Fatalf("Accuracy not correct: (%f) != (%f)", result.Accuracy, expectedAccuracy)
+    }
+}
+
+func TestGeotagger_Locate_ClockOffset(t *testing.T) {
+    // The camera is five minutes slow.
+    g := newTestGeotagger(t, time.Minute * 5, 0)
+
+    result, err := g.Locate(getTestGeotagTime(5))
+    if err != nil {
+        t.Fatalf("Not located: %s", err)
+    } else if result.Latitude != 11 {
+        t.Fatalf("Result not correct: %v", result)
+    }
+}
+
+func TestGeotagger_Locate_NotFound(t *testing.T) {
+    g := newTestGeotagger(t, 0, 0)
+
+    timestamps := []time.Time {
+        // Before and after the track.
+        getTestGeotagTime(-1),
+        getTestGeotagTime(141),
+
+        // Between the segments.
+        getTestGeotagTime(60),
+    }
+
+    for _, timestamp := range timestamps {
+        if _, err := g.Locate(timestamp); log.Is(err, ErrNotFound) != true {
+            t.Fatalf("Expected not-found error for [%s]: [%v]", timestamp, err)
+        }
+    }
+
+    // The fixes are further apart than allowed.
+    g = newTestGeotagger(t, 0, time.Minute * 5)
+
+    if _, err := g.Locate(getTestGeotagTime(5)); log.Is(err, ErrNotFound) != true {
+        t.Fatalf("Expected not-found error for a gap: [%v]", err)
+    } else if _, err := g.Locate(getTestGeotagTime(10)); err != nil {
+        t.Fatalf("A fix should still be located: %s", err)
+    }
+}
+
+func TestGeotagger_Locate_Antimeridian(t *testing.T) {
+    g := newTestGeotagger(t, 0, 0)
+
+    // From 179 to -179 is two degrees east, not 358 degrees west.
+    cases := map[int]float64 {
+        // Halfway: on the antimeridian.
+        125: 180,
+
+        // Past it.
+        127: -179.6,
+
+        // Before it.
+        122: 179.4,
+
+        // Back west from -179 to 179.5, halfway.
+        135: -179.75,
+    }
+
+    for minutes, longitude := range cases {
+        result, err := g.Locate(getTestGeotagTime(minutes))
+        if err != nil {
+            t.Fatalf("Not located at (%d): %s", minutes, err)
+        } else if isCoordinateClose(result.Longitude, longitude) != true && isCoordinateClose(result.Longitude, longitude - 360) != true {
+            t.Fatalf("Longitude at (%d) not correct: (%f)", minutes, result.Longitude)
+        } else if result.Longitude < -180 || result.Longitude > 180 {
+            t.Fatalf("Longitude at (%d) out of range: (%f)", minutes, result.Longitude)
+        }
+
+        // Never more than a degree from the fixes.
+        if result.Accuracy > GeotagDefaultPointAccuracy + 112000 {
+            t.Fatalf("Accuracy at (%d) not correct: (%f)", minutes, result.Accuracy)
+        }
+    }
+}
+
+func TestGeotagger_Tag(t *testing.T) {
+    g := newTestGeotagger(t, 0, 0)
+
+    images := []*ImageExif {
+        &ImageExif{ Timestamp: getTestGeotagTime(20) },
+        nil,
+        &ImageExif{},
+        &ImageExif{ Timestamp: getTestGeotagTime(60) },
+    }
+
+    results, err := g.Tag(images)
+    if err != nil {
+        t.Fatalf("Not tagged: %s", err)
+    } else if len(results) != 4 {
+        t.Fatalf("Result count not correct: (%d)", len(results))
+    } else if results[0] == nil || results[0].Latitude != 12 {
+        t.Fatalf("Result not correct: %v", results[0])
+    } else if results[1] != nil || results[2] != nil || results[3] != nil {
+        t.Fatalf("Images without a location should not have a result.")
+    }
+}
@@ -2,17 +2,296 @@ package ricommon
 
 import (
     "fmt"
+    "hash"
     "io"
+    "strings"
+    "sync"
 
     "encoding/gob"
     "encoding/binary"
+    "encoding/hex"
     "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "crypto/subtle"
+    "hash/fnv"
 
+    "golang.org/x/crypto/blake2b"
+    "github.com/zeebo/blake3"
+    "github.com/cespare/xxhash/v2"
     "github.com/dsoprea/go-logging"
 )
 
+// Hash algorithms
+const (
+    // Legacy. Digests without a prefix are taken to be SHA-1.
+    HashAlgorithmSha1 = "sha1"
+
+    HashAlgorithmSha256 = "sha256"
+    HashAlgorithmSha512_256 = "sha512_256"
+    HashAlgorithmBlake2b256 = "blake2b256"
+    HashAlgorithmBlake3 = "blake3"
+
+    // Not cryptographic. Only for change-detection and bucketing.
+    HashAlgorithmXxh64 = "xxh64"
+    HashAlgorithmFnv1a64 = "fnv1a64"
+)
+
+// Constants
+const (
+    // Separates the algorithm from the hex-encoded sum in a digest string
+    // (e.g. "sha256:9f86...").
+    DigestSeparator = ":"
+)
+
+// Other
+var (
+    // The algorithm used for new digests when the caller doesn't care.
+    DefaultHashAlgorithm = HashAlgorithmSha256
+
+    hashAlgorithms = make(map[string]hashAlgorithm)
+    hashAlgorithmsLocker sync.RWMutex
+)
+
+// HashFactory Return a new hash instance.
+type HashFactory func() hash.Hash
+
+type hashAlgorithm struct {
+    factory HashFactory
+    cryptographic bool
+}
+
+func init() {
+    RegisterHashAlgorithm(HashAlgorithmSha1, sha1.New, true)
+    RegisterHashAlgorithm(HashAlgorithmSha256, sha256.New, true)
+    RegisterHashAlgorithm(HashAlgorithmSha512_256, sha512.New512_256, true)
+
+    RegisterHashAlgorithm(HashAlgorithmBlake2b256, func() hash.Hash {
+        // Only fails for an oversized key.
+        h, err := blake2b.New256(nil)
+        log.PanicIf(err)
+
+        return h
+    }, true)
+
+    RegisterHashAlgorithm(HashAlgorithmBlake3, func() hash.Hash {
+        return blake3.New()
+    }, true)
+
+    RegisterHashAlgorithm(HashAlgorithmXxh64, func() hash.Hash {
+        return xxhash.New()
+    }, false)
+
+    RegisterHashAlgorithm(HashAlgorithmFnv1a64, func() hash.Hash {
+        return fnv.New64a()
+    }, false)
+}
+
+// RegisterHashAlgorithm Make an algorithm available by name. The name is
+// stored in every digest, so it must never be reused for a different
+// algorithm. Registering a name twice panics.
+func RegisterHashAlgorithm(algorithm string, factory HashFactory, cryptographic bool) {
+    if algorithm == "" || strings.Contains(algorithm, DigestSeparator) == true {
+        log.Panic(fmt.Errorf("hash algorithm name not valid: [%s]", algorithm))
+    }
+
+    hashAlgorithmsLocker.Lock()
+    defer hashAlgorithmsLocker.Unlock()
+
+    if _, found := hashAlgorithms[algorithm]; found == true {
+        log.Panic(fmt.Errorf("hash algorithm already registered: [%s]", algorithm))
+    }
+
+    hashAlgorithms[algorithm] = hashAlgorithm{
+        factory: factory,
+        cryptographic: cryptographic,
+    }
+}
+
+// NewHasher Return a new hash instance for the algorithm.
+func NewHasher(algorithm string) (h hash.Hash, err error) {
+    hashAlgorithmsLocker.RLock()
+    ha, found := hashAlgorithms[algorithm]
+    hashAlgorithmsLocker.RUnlock()
+
+    if found == false {
+        return nil, fmt.Errorf("hash algorithm not registered: [%s]", algorithm)
+    }
+
+    return ha.factory(), nil
+}
+
+// IsCryptographicHashAlgorithm Return whether the algorithm is suitable for
+// content that might be adversarial.
+func IsCryptographicHashAlgorithm(algorithm string) bool {
+    hashAlgorithmsLocker.RLock()
+    defer hashAlgorithmsLocker.RUnlock()
+
+    return hashAlgorithms[algorithm].cryptographic
+}
+
+// FormatDigest Return the self-describing digest for the sum.
+func FormatDigest(algorithm string, sum []byte) string {
+    return algorithm + DigestSeparator + hex.EncodeToString(sum)
+}
+
+// ParseDigest Split a digest into its algorithm and sum. Digests without a
+// prefix are legacy SHA-1 digests.
+func ParseDigest(digest string) (algorithm string, sum []byte, err error) {
+    encoded := digest
+    algorithm = HashAlgorithmSha1
+
+    if i := strings.Index(digest, DigestSeparator); i != -1 {
+        algorithm = digest[:i]
+        encoded = digest[i + len(DigestSeparator):]
+    } else if len(digest) != sha1.Size * 2 {
+        return "", nil, fmt.Errorf("digest not valid: [%s]", digest)
+    }
+
+    sum, err = hex.DecodeString(encoded)
+    if err != nil {
+        return "", nil, fmt.Errorf("digest not valid: [%s]", digest)
+    }
+
+    return algorithm, sum, nil
+}
+
+// verifyDigest Recompute the digest with the same algorithm as the stored one
+// and compare them. Since the digest names its own algorithm, it's an error if
+// the algorithm isn't one of `algorithms` or, if that's nil, isn't
+// cryptographic. Otherwise, anyone could substitute a digest that's easy to
+// forge.
+func verifyDigest(digest string, algorithms []string, write func(h hash.Hash)) (ok bool, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    algorithm, expected, err := ParseDigest(digest)
+    log.PanicIf(err)
+
+    if algorithms == nil {
+        if IsCryptographicHashAlgorithm(algorithm) == false {
+            log.Panic(fmt.Errorf("digest algorithm not cryptographic: [%s]", algorithm))
+        }
+    } else {
+        allowed := false
+        for _, candidate := range algorithms {
+            if candidate == algorithm {
+                allowed = true
+                break
+            }
+        }
+
+        if allowed == false {
+            log.Panic(fmt.Errorf("digest algorithm not allowed: [%s]", algorithm))
+        }
+    }
+
+    h, err := NewHasher(algorithm)
+    log.PanicIf(err)
+
+    write(h)
+
+    return subtle.ConstantTimeCompare(h.Sum(nil), expected) == 1, nil
+}
+
+func newHasherOrPanic(algorithm string) hash.Hash {
+    h, err := NewHasher(algorithm)
+    log.PanicIf(err)
+
+    return h
+}
+
+// EncodeToDigestString Return the self-describing digest of the
+// gob-encoded parts. The gob encoding is only stable within a process; see
+// EncodeToCanonicalDigestString.
+func EncodeToDigestString(algorithm string, parts []interface{}) (digest string) {
+    h := newHasherOrPanic(algorithm)
+    writeGobParts(h, parts)
+
+    return FormatDigest(algorithm, h.Sum(nil))
+}
+
+// EncodeStringsToDigestString Return the self-describing digest of the
+// NUL-separated strings.
+func EncodeStringsToDigestString(algorithm string, parts []string) (digest string) {
+    h := newHasherOrPanic(algorithm)
+    writeStringParts(h, parts)
+
+    return FormatDigest(algorithm, h.Sum(nil))
+}
+
+// EncodeInt64ToDigestString Return the self-describing digest of the
+// little-endian integer.
+func EncodeInt64ToDigestString(algorithm string, n int64) (digest string) {
+    h := newHasherOrPanic(algorithm)
+    binary.Write(h, binary.LittleEndian, n)
+
+    return FormatDigest(algorithm, h.Sum(nil))
+}
+
+// VerifyDigestString Return whether the digest (self-describing or legacy
+// SHA-1) matches the gob-encoded parts. Digests that use non-cryptographic
+// algorithms are rejected.
+func VerifyDigestString(digest string, parts []interface{}) (ok bool, err error) {
+    return VerifyDigestStringWithAlgorithms(digest, parts, nil)
+}
+
+// VerifyDigestStringWithAlgorithms Like VerifyDigestString, but the digest may
+// only use one of the given algorithms (which may be non-cryptographic).
+func VerifyDigestStringWithAlgorithms(digest string, parts []interface{}, algorithms []string) (ok bool, err error) {
+    return verifyDigest(digest, algorithms, func(h hash.Hash) {
+        writeGobParts(h, parts)
+    })
+}
+
+// VerifyStringsDigestString Return whether the digest (self-describing or
+// legacy SHA-1) matches the strings. Digests that use non-cryptographic
+// algorithms are rejected.
+func VerifyStringsDigestString(digest string, parts []string) (ok bool, err error) {
+    return VerifyStringsDigestStringWithAlgorithms(digest, parts, nil)
+}
+
+// VerifyStringsDigestStringWithAlgorithms Like VerifyStringsDigestString, but
+// the digest may only use one of the given algorithms (which may be
+// non-cryptographic).
+func VerifyStringsDigestStringWithAlgorithms(digest string, parts []string, algorithms []string) (ok bool, err error) {
+    return verifyDigest(digest, algorithms, func(h hash.Hash) {
+        writeStringParts(h, parts)
+    })
+}
+
+// VerifyInt64DigestString Return whether the digest (self-describing or
+// legacy SHA-1) matches the integer. Digests that use non-cryptographic
+// algorithms are rejected.
+func VerifyInt64DigestString(digest string, n int64) (ok bool, err error) {
+    return VerifyInt64DigestStringWithAlgorithms(digest, n, nil)
+}
+
+// VerifyInt64DigestStringWithAlgorithms Like VerifyInt64DigestString, but the
+// digest may only use one of the given algorithms (which may be
+// non-cryptographic).
+func VerifyInt64DigestStringWithAlgorithms(digest string, n int64, algorithms []string) (ok bool, err error) {
+    return verifyDigest(digest, algorithms, func(h hash.Hash) {
+        binary.Write(h, binary.LittleEndian, n)
+    })
+}
+
+// EncodeToSha1DigestString Return the SHA-1 digest of the gob-encoded parts,
+// without an algorithm prefix.
+//
+// Deprecated: Use EncodeToDigestString.
 func EncodeToSha1DigestString(parts []interface{}) (digest string) {
     h := sha1.New()
+    writeGobParts(h, parts)
+
+    digest = fmt.Sprintf("%x", h.Sum(nil))
+    return digest
+}
+
+func writeGobParts(h hash.Hash, parts []interface{}) {
     g := gob.NewEncoder(h)
 
     for _, x := range parts {
@@ -20,14 +299,21 @@ func EncodeToSha1DigestString(parts []interface{}) (digest string) {
             log.Panic(fmt.Errorf("error encoding [%v]: %s", x, err))
         }
     }
-
-    digest = fmt.Sprintf("%x", h.Sum(nil))
-    return digest
 }
 
+// EncodeStringsToSha1DigestString Return the SHA-1 digest of the NUL-separated
+// strings, without an algorithm prefix.
+//
+// Deprecated: Use EncodeStringsToDigestString.
 func EncodeStringsToSha1DigestString(parts []string) (digest string) {
     h := sha1.New()
+    writeStringParts(h, parts)
+
+    digest = fmt.Sprintf("%x", h.Sum(nil))
+    return digest
+}
 
+func writeStringParts(h hash.Hash, parts []string) {
     for i, s := range parts {
         if c, err := io.WriteString(h, s); err != nil {
             log.Panic(fmt.Errorf("error writing [%s]: %s", s, err))
@@ -45,11 +331,12 @@ func EncodeStringsToSha1DigestString(parts []string) (digest string) {
             }
         }
     }
-
-    digest = fmt.Sprintf("%x", h.Sum(nil))
-    return digest
 }
 
+// EncodeInt64ToSha1DigestString Return the SHA-1 digest of the little-endian
+// integer, without an algorithm prefix.
+//
+// Deprecated: Use EncodeInt64ToDigestString.
 func EncodeInt64ToSha1DigestString(n int64) (digest string) {
     h := sha1.New()
     binary.Write(h, binary.LittleEndian, n)
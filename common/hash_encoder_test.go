@@ -0,0 +1,132 @@
+package ricommon
+
+import (
+    "testing"
+)
+
+func TestEncodeStringsToDigestString(t *testing.T) {
+    digests := map[string]string {
+        HashAlgorithmSha1: "sha1:a9993e364706816aba3e25717850c26c9cd0d89d",
+        HashAlgorithmSha256: "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+        HashAlgorithmXxh64: "xxh64:44bc2cf5ad770999",
+        HashAlgorithmFnv1a64: "fnv1a64:e71fa2190541574b",
+    }
+
+    for algorithm, expected := range digests {
+        if digest := EncodeStringsToDigestString(algorithm, []string { "abc" }); digest != expected {
+            t.Fatalf("Digest for [%s] not correct: [%s]", algorithm, digest)
+        }
+    }
+}
+
+func TestVerifyDigestString(t *testing.T) {
+    parts := []interface{} { "abc", 123 }
+
+    algorithms := []string {
+        HashAlgorithmSha1,
+        HashAlgorithmSha256,
+        HashAlgorithmSha512_256,
+        HashAlgorithmBlake2b256,
+        HashAlgorithmBlake3,
+    }
+
+    for _, algorithm := range algorithms {
+        digest := EncodeToDigestString(algorithm, parts)
+
+        if ok, err := VerifyDigestString(digest, parts); err != nil || ok != true {
+            t.Fatalf("Digest for [%s] did not verify: %v", algorithm, err)
+        } else if ok, err := VerifyDigestString(digest, []interface{} { "abd", 123 }); err != nil || ok != false {
+            t.Fatalf("Digest for [%s] should not verify other parts: %v", algorithm, err)
+        }
+    }
+}
+
+func TestVerifyDigestString_LegacySha1(t *testing.T) {
+    parts := []interface{} { "abc", 123 }
+
+    legacy := EncodeToSha1DigestString(parts)
+    if legacy != EncodeToDigestString(HashAlgorithmSha1, parts)[len("sha1:"):] {
+        t.Fatalf("Legacy digest not correct: [%s]", legacy)
+    } else if ok, err := VerifyDigestString(legacy, parts); err != nil || ok != true {
+        t.Fatalf("Legacy digest did not verify: %v", err)
+    }
+
+    legacy = EncodeStringsToSha1DigestString([]string { "abc" })
+    if legacy != "a9993e364706816aba3e25717850c26c9cd0d89d" {
+        t.Fatalf("Legacy strings digest not correct: [%s]", legacy)
+    } else if ok, err := VerifyStringsDigestString(legacy, []string { "abc" }); err != nil || ok != true {
+        t.Fatalf("Legacy strings digest did not verify: %v", err)
+    }
+
+    legacy = EncodeInt64ToSha1DigestString(42)
+    if ok, err := VerifyInt64DigestString(legacy, 42); err != nil || ok != true {
+        t.Fatalf("Legacy integer digest did not verify: %v", err)
+    } else if ok, err := VerifyInt64DigestString(legacy, 43); err != nil || ok != false {
+        t.Fatalf("Legacy integer digest should not verify another integer: %v", err)
+    }
+}
+
+func TestVerifyStringsDigestString_Algorithms(t *testing.T) {
+    parts := []string { "abc" }
+
+    // Non-cryptographic digests are easy to forge, so they have to be asked
+    // for.
+    digest := EncodeStringsToDigestString(HashAlgorithmXxh64, parts)
+
+    if _, err := VerifyStringsDigestString(digest, parts); err == nil {
+        t.Fatalf("Expected error for a non-cryptographic digest.")
+    } else if ok, err := VerifyStringsDigestStringWithAlgorithms(digest, parts, []string { HashAlgorithmXxh64 }); err != nil || ok != true {
+        t.Fatalf("Allowed digest did not verify: %v", err)
+    }
+
+    // Only the given algorithms are allowed.
+    digest = EncodeStringsToDigestString(HashAlgorithmSha1, parts)
+
+    if _, err := VerifyStringsDigestStringWithAlgorithms(digest, parts, []string { HashAlgorithmSha256 }); err == nil {
+        t.Fatalf("Expected error for an algorithm that isn't allowed.")
+    }
+
+    if _, err := VerifyInt64DigestStringWithAlgorithms(EncodeInt64ToDigestString(HashAlgorithmFnv1a64, 1), 1, []string { HashAlgorithmSha256 }); err == nil {
+        t.Fatalf("Expected error for an integer digest with an algorithm that isn't allowed.")
+    }
+}
+
+func TestParseDigest_NotValid(t *testing.T) {
+    digests := []string {
+        "",
+        "abc",
+        "sha256:xyz",
+        "md4:00",
+    }
+
+    for _, digest := range digests {
+        if _, err := VerifyStringsDigestString(digest, []string { "abc" }); err == nil {
+            t.Fatalf("Expected error for [%s].", digest)
+        }
+    }
+}
+
+func TestRegisterHashAlgorithm_Duplicate(t *testing.T) {
+    names := []string {
+        HashAlgorithmSha256,
+        "",
+        "a:b",
+    }
+
+    for _, name := range names {
+        func() {
+            defer func() {
+                if state := recover(); state == nil {
+                    t.Fatalf("Registering [%s] should have panicked.", name)
+                }
+            }()
+
+            RegisterHashAlgorithm(name, nil, true)
+        }()
+    }
+
+    h, err := NewHasher(HashAlgorithmSha256)
+    if err != nil || h == nil {
+        t.Fatalf("Existing algorithm should not have been replaced: %v", err)
+    }
+}
@@ -0,0 +1,201 @@
+package ricommon
+
+import (
+    "fmt"
+    "hash"
+    "strings"
+    "sync"
+
+    "crypto/hmac"
+    "encoding/hex"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Constants
+const (
+    // Prefixes the hash algorithm in keyed digests (e.g.
+    // "hmac-sha256:2024a:9f86...").
+    KeyedDigestPrefix = "hmac-"
+)
+
+// DigestKeyring Produces and verifies HMAC digests. Every digest embeds the ID
+// of the key that produced it so that keys can be rotated: new digests use the
+// current key and older digests verify for as long as their key is kept in
+// the ring.
+type DigestKeyring struct {
+    algorithm string
+    keys map[string][]byte
+    currentKeyId string
+
+    locker sync.RWMutex
+}
+
+// NewDigestKeyring Create an empty keyring whose digests use the given
+// (cryptographic) algorithm.
+func NewDigestKeyring(algorithm string) (dk *DigestKeyring, err error) {
+    if IsCryptographicHashAlgorithm(algorithm) == false {
+        return nil, fmt.Errorf("keyed digests require a cryptographic hash algorithm: [%s]", algorithm)
+    }
+
+    dk = &DigestKeyring{
+        algorithm: algorithm,
+        keys: make(map[string][]byte),
+    }
+
+    return dk, nil
+}
+
+// AddKey Add a key. If `makeCurrent` is true, new digests will use it.
+func (dk *DigestKeyring) AddKey(keyId string, key []byte, makeCurrent bool) (err error) {
+    if keyId == "" || strings.Contains(keyId, DigestSeparator) == true {
+        return fmt.Errorf("key ID not valid: [%s]", keyId)
+    } else if len(key) == 0 {
+        return fmt.Errorf("key is empty: [%s]", keyId)
+    }
+
+    dk.locker.Lock()
+    defer dk.locker.Unlock()
+
+    if _, found := dk.keys[keyId]; found == true {
+        return ErrAlreadyExists
+    }
+
+    copied := make([]byte, len(key))
+    copy(copied, key)
+
+    dk.keys[keyId] = copied
+
+    if makeCurrent == true {
+        dk.currentKeyId = keyId
+    }
+
+    return nil
+}
+
+// RemoveKey Retire a key. Digests produced with it will no longer verify.
+func (dk *DigestKeyring) RemoveKey(keyId string) (err error) {
+    dk.locker.Lock()
+    defer dk.locker.Unlock()
+
+    if _, found := dk.keys[keyId]; found == false {
+        return ErrNotFound
+    } else if keyId == dk.currentKeyId {
+        return fmt.Errorf("current key can not be removed: [%s]", keyId)
+    }
+
+    delete(dk.keys, keyId)
+    return nil
+}
+
+// EncodeStringsToKeyedDigestString Return the keyed digest of the
+// NUL-separated strings.
+func (dk *DigestKeyring) EncodeStringsToKeyedDigestString(parts []string) (digest string, err error) {
+    return dk.encode(func(h hash.Hash) {
+        writeStringParts(h, parts)
+    })
+}
+
+// EncodeToKeyedDigestString Return the keyed digest of the canonical encoding
+// of the parts.
+func (dk *DigestKeyring) EncodeToKeyedDigestString(parts []interface{}) (digest string, err error) {
+    return dk.encode(func(h hash.Hash) {
+        err := WriteCanonical(h, parts)
+        log.PanicIf(err)
+    })
+}
+
+// VerifyStringsKeyedDigestString Return whether the keyed digest matches the
+// strings.
+func (dk *DigestKeyring) VerifyStringsKeyedDigestString(digest string, parts []string) (ok bool, err error) {
+    return dk.verify(digest, func(h hash.Hash) {
+        writeStringParts(h, parts)
+    })
+}
+
+// VerifyKeyedDigestString Return whether the keyed digest matches the
+// canonical encoding of the parts.
+func (dk *DigestKeyring) VerifyKeyedDigestString(digest string, parts []interface{}) (ok bool, err error) {
+    return dk.verify(digest, func(h hash.Hash) {
+        err := WriteCanonical(h, parts)
+        log.PanicIf(err)
+    })
+}
+
+func (dk *DigestKeyring) encode(write func(h hash.Hash)) (digest string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    dk.locker.RLock()
+    keyId := dk.currentKeyId
+    key := dk.keys[keyId]
+    dk.locker.RUnlock()
+
+    if keyId == "" {
+        log.Panic(fmt.Errorf("keyring has no current key"))
+    }
+
+    h := dk.newHmac(dk.algorithm, key)
+    write(h)
+
+    digest = KeyedDigestPrefix + dk.algorithm + DigestSeparator + keyId + DigestSeparator + hex.EncodeToString(h.Sum(nil))
+    return digest, nil
+}
+
+func (dk *DigestKeyring) verify(digest string, write func(h hash.Hash)) (ok bool, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    algorithm, keyId, expected, err := ParseKeyedDigest(digest)
+    log.PanicIf(err)
+
+    if algorithm != dk.algorithm {
+        log.Panic(fmt.Errorf("keyed digest algorithm not valid for keyring: [%s]", algorithm))
+    }
+
+    dk.locker.RLock()
+    key, found := dk.keys[keyId]
+    dk.locker.RUnlock()
+
+    if found == false {
+        // Either retired or forged.
+        return false, nil
+    }
+
+    h := dk.newHmac(algorithm, key)
+    write(h)
+
+    return hmac.Equal(h.Sum(nil), expected), nil
+}
+
+func (dk *DigestKeyring) newHmac(algorithm string, key []byte) hash.Hash {
+    // Make sure that the factory works before we hand it to HMAC, which can't
+    // return an error.
+    newHasherOrPanic(algorithm)
+
+    return hmac.New(func() hash.Hash {
+        return newHasherOrPanic(algorithm)
+    }, key)
+}
+
+// ParseKeyedDigest Split a keyed digest into its algorithm, key ID, and sum.
+func ParseKeyedDigest(digest string) (algorithm string, keyId string, sum []byte, err error) {
+    parts := strings.Split(digest, DigestSeparator)
+    if len(parts) != 3 || strings.HasPrefix(parts[0], KeyedDigestPrefix) == false {
+        return "", "", nil, fmt.Errorf("keyed digest not valid: [%s]", digest)
+    }
+
+    sum, err = hex.DecodeString(parts[2])
+    if err != nil {
+        return "", "", nil, fmt.Errorf("keyed digest not valid: [%s]", digest)
+    }
+
+    algorithm = parts[0][len(KeyedDigestPrefix):]
+    return algorithm, parts[1], sum, nil
+}
@@ -0,0 +1,160 @@
+package ricommon
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func newTestDigestKeyring(t *testing.T, keyId string, key []byte) *DigestKeyring {
+    dk, err := NewDigestKeyring(HashAlgorithmSha256)
+    if err != nil {
+        t.Fatalf("Keyring not created: %s", err)
+    } else if err := dk.AddKey(keyId, key, true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    }
+
+    return dk
+}
+
+func TestDigestKeyring_KnownAnswer(t *testing.T) {
+    // RFC 4231, test case 2.
+    key := []byte("Jefe")
+    dk := newTestDigestKeyring(t, "2024a", key)
+
+    digest, err := dk.EncodeStringsToKeyedDigestString([]string { "what do ya want for nothing?" })
+    if err != nil {
+        t.Fatalf("Digest not produced: %s", err)
+    }
+
+    expected := "hmac-sha256:2024a:5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+    if digest != expected {
+        t.Fatalf("Digest not correct: [%s]", digest)
+    }
+
+    // Modifying the caller's key doesn't change the keyring.
+    key[0] = 'j'
+
+    if ok, err := dk.VerifyStringsKeyedDigestString(expected, []string { "what do ya want for nothing?" }); err != nil || ok != true {
+        t.Fatalf("Digest not verified: %v", err)
+    }
+
+    // Parts are separated by NULs.
+    digest, err = dk.EncodeStringsToKeyedDigestString([]string { "what do ya want", "for nothing?" })
+    if err != nil {
+        t.Fatalf("Digest not produced: %s", err)
+    } else if digest == expected {
+        t.Fatalf("Parts should not be concatenated.")
+    } else if ok, err := dk.VerifyStringsKeyedDigestString(digest, []string { "what do ya want\x00for nothing?" }); err != nil || ok != true {
+        t.Fatalf("Parts not NUL-separated: %v", err)
+    }
+}
+
+func TestParseKeyedDigest(t *testing.T) {
+    algorithm, keyId, sum, err := ParseKeyedDigest("hmac-sha256:2024a:00ff")
+    if err != nil {
+        t.Fatalf("Digest not parsed: %s", err)
+    } else if algorithm != HashAlgorithmSha256 || keyId != "2024a" || len(sum) != 2 || sum[1] != 0xff {
+        t.Fatalf("Digest not parsed correctly: [%s] [%s] %x", algorithm, keyId, sum)
+    }
+
+    for _, digest := range []string { "sha256:00ff", "hmac-sha256:00ff", "sha256:2024a:00ff", "hmac-sha256:2024a:0g" } {
+        if _, _, _, err := ParseKeyedDigest(digest); err == nil {
+            t.Fatalf("Expected error for [%s].", digest)
+        }
+    }
+}
+
+func TestDigestKeyring_Rotation(t *testing.T) {
+    dk := newTestDigestKeyring(t, "k1", []byte("first key"))
+    parts := []interface{} { "user", int64(5) }
+
+    old, err := dk.EncodeToKeyedDigestString(parts)
+    if err != nil {
+        t.Fatalf("Digest not produced: %s", err)
+    }
+
+    if err := dk.AddKey("k2", []byte("second key"), true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    }
+
+    current, err := dk.EncodeToKeyedDigestString(parts)
+    if err != nil {
+        t.Fatalf("Digest not produced: %s", err)
+    } else if strings.HasPrefix(current, "hmac-sha256:k2:") != true {
+        t.Fatalf("Digest not produced with the current key: [%s]", current)
+    }
+
+    // Both verify while the old key is kept.
+    for _, digest := range []string { old, current } {
+        if ok, err := dk.VerifyKeyedDigestString(digest, parts); err != nil || ok != true {
+            t.Fatalf("Digest [%s] not verified: %v", digest, err)
+        }
+    }
+
+    if ok, err := dk.VerifyKeyedDigestString(current, []interface{} { "user", int64(6) }); err != nil || ok != false {
+        t.Fatalf("Digest of other parts should not verify: %v", err)
+    }
+
+    if err := dk.RemoveKey("k2"); err == nil {
+        t.Fatalf("Expected error for removing the current key.")
+    } else if err := dk.RemoveKey("k3"); log.Is(err, ErrNotFound) != true {
+        t.Fatalf("Expected not-found error: [%v]", err)
+    } else if err := dk.RemoveKey("k1"); err != nil {
+        t.Fatalf("Key not removed: %s", err)
+    }
+
+    // A retired key isn't an error; the digest just doesn't verify.
+    if ok, err := dk.VerifyKeyedDigestString(old, parts); err != nil || ok != false {
+        t.Fatalf("Digest of a retired key should not verify: %v", err)
+    } else if ok, err := dk.VerifyKeyedDigestString(current, parts); err != nil || ok != true {
+        t.Fatalf("Digest not verified: %v", err)
+    }
+
+    // A key that isn't made current doesn't change what's produced.
+    if err := dk.AddKey("k3", []byte("third key"), false); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    } else if digest, err := dk.EncodeToKeyedDigestString(parts); err != nil || digest != current {
+        t.Fatalf("Digest should still use the current key: [%s]", digest)
+    }
+}
+
+func TestDigestKeyring_NotValid(t *testing.T) {
+    if _, err := NewDigestKeyring(HashAlgorithmFnv1a64); err == nil {
+        t.Fatalf("Expected error for a non-cryptographic algorithm.")
+    }
+
+    dk, err := NewDigestKeyring(HashAlgorithmSha256)
+    if err != nil {
+        t.Fatalf("Keyring not created: %s", err)
+    }
+
+    if _, err := dk.EncodeStringsToKeyedDigestString([]string { "a" }); err == nil {
+        t.Fatalf("Expected error for a keyring without a current key.")
+    }
+
+    if err := dk.AddKey("", []byte("key"), true); err == nil {
+        t.Fatalf("Expected error for an empty key ID.")
+    } else if err := dk.AddKey("a:b", []byte("key"), true); err == nil {
+        t.Fatalf("Expected error for a key ID with a separator.")
+    } else if err := dk.AddKey("k1", nil, true); err == nil {
+        t.Fatalf("Expected error for an empty key.")
+    } else if err := dk.AddKey("k1", []byte("key"), true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    } else if err := dk.AddKey("k1", []byte("other"), true); log.Is(err, ErrAlreadyExists) != true {
+        t.Fatalf("Expected already-exists error: [%v]", err)
+    }
+
+    digest, err := dk.EncodeStringsToKeyedDigestString([]string { "a" })
+    if err != nil {
+        t.Fatalf("Digest not produced: %s", err)
+    }
+
+    // Another algorithm, or not a keyed digest at all.
+    for _, other := range []string { strings.Replace(digest, "sha256", "sha512_256", 1), strings.TrimPrefix(digest, KeyedDigestPrefix) } {
+        if _, err := dk.VerifyStringsKeyedDigestString(other, []string { "a" }); err == nil {
+            t.Fatalf("Expected error for [%s].", other)
+        }
+    }
+}
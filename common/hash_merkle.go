@@ -0,0 +1,291 @@
+package ricommon
+
+// Merkle digests
+//
+// The stream is split into chunks and each chunk is hashed as-is, so chunk
+// digests can be used as content addresses for deduplication. The root is
+// computed over the chunk sums with domain separation (as in RFC 6962):
+//
+//     leaf = H(0x00 || chunk sum)
+//     node = H(0x01 || left || right)
+//
+// Levels with an odd number of nodes promote the last one unchanged. An empty
+// stream is a single empty chunk.
+//
+// Content-defined chunking uses a gear hash (h = (h << 1) + gear[b]) and cuts
+// after a byte when the chunk is at least the minimum size and the high bits of
+// h (log2 of the average size) are zero, or when the chunk reaches the maximum
+// size. As in FastCDC, the high bits are used since each bit of h only depends
+// on as many of the latest bytes as its position. The gear table is 256 values
+// from SplitMix64 seeded with zero, so chunk boundaries are the same in every
+// process.
+
+import (
+    "bytes"
+    "fmt"
+    "math/bits"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Merkle defaults
+const (
+    MerkleDefaultChunkSize = 1024 * 1024
+)
+
+// Other
+var (
+    merkleGearTable [256]uint64
+)
+
+func init() {
+    state := uint64(0)
+    for i := range merkleGearTable {
+        state += 0x9e3779b97f4a7c15
+
+        z := state
+        z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+        z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+        merkleGearTable[i] = z ^ (z >> 31)
+    }
+}
+
+// MerkleOptions How to split the stream.
+type MerkleOptions struct {
+    Algorithm string
+
+    // If false, every chunk but the last is ChunkSize bytes. If true, chunk
+    // boundaries depend on the content so that an insertion only changes the
+    // chunks around it; ChunkSize is then the average and must be a power of
+    // two.
+    ContentDefined bool
+
+    ChunkSize int
+
+    // Only used for content-defined chunks. If zero, a quarter and four times
+    // the average.
+    MinChunkSize int
+    MaxChunkSize int
+}
+
+// MerkleChunk Describes one chunk of the stream.
+type MerkleChunk struct {
+    Offset int64
+    Size int
+    Digest string
+}
+
+// MerkleResult The root digest and the chunks that it covers.
+type MerkleResult struct {
+    Root string
+    Chunks []MerkleChunk
+}
+
+// MerkleWriter Splits everything written to it into chunks and hashes them.
+// Call Sum() after the last write.
+type MerkleWriter struct {
+    options MerkleOptions
+    mask uint64
+
+    buffer []byte
+    scanned int
+    gear uint64
+
+    offset int64
+    chunks []MerkleChunk
+    sums [][]byte
+}
+
+// NewMerkleWriter Create a writer. Zero sizes in the options are replaced by
+// the defaults.
+func NewMerkleWriter(options MerkleOptions) (mw *MerkleWriter, err error) {
+    if _, err := NewHasher(options.Algorithm); err != nil {
+        return nil, err
+    }
+
+    if options.ChunkSize == 0 {
+        options.ChunkSize = MerkleDefaultChunkSize
+    }
+
+    mw = &MerkleWriter{
+        options: options,
+        chunks: make([]MerkleChunk, 0),
+        sums: make([][]byte, 0),
+    }
+
+    if options.ContentDefined == true {
+        if options.MinChunkSize == 0 {
+            options.MinChunkSize = options.ChunkSize / 4
+        }
+
+        if options.MaxChunkSize == 0 {
+            options.MaxChunkSize = options.ChunkSize * 4
+        }
+
+        if options.ChunkSize < 0 || bits.OnesCount(uint(options.ChunkSize)) != 1 {
+            return nil, fmt.Errorf("average chunk size must be a power of two: (%d)", options.ChunkSize)
+        } else if options.MinChunkSize > options.ChunkSize || options.ChunkSize > options.MaxChunkSize {
+            return nil, fmt.Errorf("chunk sizes must be ordered min <= average <= max: (%d) (%d) (%d)", options.MinChunkSize, options.ChunkSize, options.MaxChunkSize)
+        }
+
+        // The top log2(average) bits.
+        maskBits := bits.TrailingZeros(uint(options.ChunkSize))
+
+        mw.options = options
+        mw.mask = ^uint64(0) << (64 - maskBits)
+    } else if options.ChunkSize < 0 {
+        return nil, fmt.Errorf("chunk size not valid: (%d)", options.ChunkSize)
+    }
+
+    return mw, nil
+}
+
+func (mw *MerkleWriter) Write(p []byte) (n int, err error) {
+    mw.buffer = append(mw.buffer, p...)
+
+    if mw.options.ContentDefined == false {
+        for len(mw.buffer) >= mw.options.ChunkSize {
+            mw.cut(mw.options.ChunkSize)
+        }
+
+        return len(p), nil
+    }
+
+    for mw.scanned < len(mw.buffer) {
+        mw.gear = (mw.gear << 1) + merkleGearTable[mw.buffer[mw.scanned]]
+        mw.scanned++
+
+        if mw.scanned >= mw.options.MaxChunkSize || mw.scanned >= mw.options.MinChunkSize && mw.gear & mw.mask == 0 {
+            mw.cut(mw.scanned)
+        }
+    }
+
+    return len(p), nil
+}
+
+// cut Hash the first `size` bytes of the buffer as a chunk.
+func (mw *MerkleWriter) cut(size int) {
+    h := newHasherOrPanic(mw.options.Algorithm)
+    h.Write(mw.buffer[:size])
+
+    sum := h.Sum(nil)
+
+    mw.chunks = append(mw.chunks, MerkleChunk{
+        Offset: mw.offset,
+        Size: size,
+        Digest: FormatDigest(mw.options.Algorithm, sum),
+    })
+
+    mw.sums = append(mw.sums, sum)
+
+    mw.offset += int64(size)
+    mw.buffer = mw.buffer[size:]
+    mw.scanned = 0
+    mw.gear = 0
+}
+
+// Sum Hash whatever remains as the last chunk and return the result. Nothing
+// may be written afterward.
+func (mw *MerkleWriter) Sum() (result *MerkleResult) {
+    if len(mw.buffer) > 0 || len(mw.chunks) == 0 {
+        mw.cut(len(mw.buffer))
+    }
+
+    result = &MerkleResult{
+        Root: FormatDigest(mw.options.Algorithm, merkleRoot(mw.options.Algorithm, mw.sums)),
+        Chunks: mw.chunks,
+    }
+
+    return result
+}
+
+// MerkleRootOfChunks Recompute the root from the chunk digests (e.g. to check a
+// chunk list received from elsewhere against a trusted root).
+func MerkleRootOfChunks(chunks []MerkleChunk) (root string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    if len(chunks) == 0 {
+        log.Panic(fmt.Errorf("no chunks"))
+    }
+
+    algorithm := ""
+    sums := make([][]byte, len(chunks))
+
+    for i, chunk := range chunks {
+        chunkAlgorithm, sum, err := ParseDigest(chunk.Digest)
+        log.PanicIf(err)
+
+        if algorithm == "" {
+            algorithm = chunkAlgorithm
+        } else if chunkAlgorithm != algorithm {
+            log.Panic(fmt.Errorf("chunks use different algorithms"))
+        }
+
+        sums[i] = sum
+    }
+
+    return FormatDigest(algorithm, merkleRoot(algorithm, sums)), nil
+}
+
+// VerifyMerkleChunk Return whether the data matches the chunk. Together with
+// MerkleRootOfChunks, this allows part of a large file to be verified without
+// reading the rest of it.
+func VerifyMerkleChunk(chunk MerkleChunk, data []byte) (ok bool, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    if len(data) != chunk.Size {
+        return false, nil
+    }
+
+    algorithm, expected, err := ParseDigest(chunk.Digest)
+    log.PanicIf(err)
+
+    h, err := NewHasher(algorithm)
+    log.PanicIf(err)
+
+    h.Write(data)
+
+    return bytes.Equal(h.Sum(nil), expected), nil
+}
+
+func merkleRoot(algorithm string, sums [][]byte) []byte {
+    level := make([][]byte, len(sums))
+    for i, sum := range sums {
+        h := newHasherOrPanic(algorithm)
+        h.Write([]byte { 0x00 })
+        h.Write(sum)
+
+        level[i] = h.Sum(nil)
+    }
+
+    for len(level) > 1 {
+        next := make([][]byte, 0, (len(level) + 1) / 2)
+
+        for i := 0; i < len(level); i += 2 {
+            if i + 1 == len(level) {
+                next = append(next, level[i])
+                continue
+            }
+
+            h := newHasherOrPanic(algorithm)
+            h.Write([]byte { 0x01 })
+            h.Write(level[i])
+            h.Write(level[i + 1])
+
+            next = append(next, h.Sum(nil))
+        }
+
+        level = next
+    }
+
+    return level[0]
+}
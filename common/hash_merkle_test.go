@@ -0,0 +1,180 @@
+package ricommon
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+// merkleTestData Return `size` bytes from a fixed xorshift sequence so that
+// the chunk boundaries are reproducible.
+func merkleTestData(size int) []byte {
+    data := make([]byte, size)
+
+    state := uint32(2463534242)
+    for i := range data {
+        state ^= state << 13
+        state ^= state >> 17
+        state ^= state << 5
+
+        data[i] = byte(state)
+    }
+
+    return data
+}
+
+// getMerkleTestResult Write the data in uneven pieces and return the result.
+func getMerkleTestResult(t *testing.T, options MerkleOptions, data []byte) *MerkleResult {
+    mw, err := NewMerkleWriter(options)
+    if err != nil {
+        t.Fatalf("Writer not created: %s", err)
+    }
+
+    for len(data) > 0 {
+        n := 1000
+        if n > len(data) {
+            n = len(data)
+        }
+
+        mw.Write(data[:n])
+        data = data[n:]
+    }
+
+    return mw.Sum()
+}
+
+func getMerkleTestSizes(result *MerkleResult) []int {
+    sizes := make([]int, len(result.Chunks))
+    for i, chunk := range result.Chunks {
+        sizes[i] = chunk.Size
+    }
+
+    return sizes
+}
+
+func TestMerkleWriter_Golden(t *testing.T) {
+    cases := []struct {
+        description string
+        options MerkleOptions
+        data []byte
+        sizes []int
+        root string
+    } {
+        {
+            description: "empty",
+            options: MerkleOptions{ Algorithm: HashAlgorithmSha256 },
+            data: nil,
+            sizes: []int { 0 },
+            root: "sha256:4e59bf27372b1304bc0b137d1be9d566ad58b154b6a6b5778af7f414b1d4b84c",
+        },
+        {
+            description: "fixed",
+            options: MerkleOptions{ Algorithm: HashAlgorithmSha256, ChunkSize: 4096 },
+            data: merkleTestData(10000),
+            sizes: []int { 4096, 4096, 1808 },
+            root: "sha256:1ba976edc733d34419e89624255f29c60dd67885eaecb52dc12335bdbf66169e",
+        },
+        {
+            description: "content-defined",
+            options: MerkleOptions{ Algorithm: HashAlgorithmSha256, ContentDefined: true, ChunkSize: 1024 },
+            data: merkleTestData(16384),
+            sizes: []int { 1698, 3524, 1401, 1033, 681, 1952, 2003, 1184, 1647, 1261 },
+            root: "sha256:d41cfdac4cdd12593c976f7dbc159e83324cdab7d3ebf6e15d7f8b64f95d4919",
+        },
+    }
+
+    for _, c := range cases {
+        result := getMerkleTestResult(t, c.options, c.data)
+
+        if sizes := getMerkleTestSizes(result); reflect.DeepEqual(sizes, c.sizes) != true {
+            t.Fatalf("Chunk sizes of [%s] not correct: %v", c.description, sizes)
+        } else if result.Root != c.root {
+            t.Fatalf("Root of [%s] not correct: [%s]", c.description, result.Root)
+        }
+
+        root, err := MerkleRootOfChunks(result.Chunks)
+        if err != nil {
+            t.Fatalf("Root of [%s] not recomputed: %s", c.description, err)
+        } else if root != c.root {
+            t.Fatalf("Recomputed root of [%s] not correct: [%s]", c.description, root)
+        }
+    }
+}
+
+func TestMerkleWriter_ContentDefinedInsertion(t *testing.T) {
+    options := MerkleOptions{
+        Algorithm: HashAlgorithmSha256,
+        ContentDefined: true,
+        ChunkSize: 1024,
+    }
+
+    data := merkleTestData(16384)
+
+    // Insert a few bytes in the middle of the third chunk.
+    changed := make([]byte, 0, len(data) + 3)
+    changed = append(changed, data[:6000]...)
+    changed = append(changed, "abc"...)
+    changed = append(changed, data[6000:]...)
+
+    original := getMerkleTestResult(t, options, data)
+    result := getMerkleTestResult(t, options, changed)
+
+    digests := make(map[string]bool)
+    for _, chunk := range original.Chunks {
+        digests[chunk.Digest] = true
+    }
+
+    different := 0
+    for _, chunk := range result.Chunks {
+        if digests[chunk.Digest] == false {
+            different++
+        }
+    }
+
+    if different != 1 {
+        t.Fatalf("Only the chunk with the insertion should have changed: (%d)", different)
+    } else if result.Root == original.Root {
+        t.Fatalf("Root should have changed.")
+    }
+}
+
+func TestMerkleWriter_DefaultSizes(t *testing.T) {
+    // The minimum and maximum follow the average.
+    result := getMerkleTestResult(t, MerkleOptions{ Algorithm: HashAlgorithmSha256, ContentDefined: true, ChunkSize: 64 }, merkleTestData(16384))
+
+    for i, chunk := range result.Chunks {
+        if chunk.Size > 256 || chunk.Size < 16 && i < len(result.Chunks) - 1 {
+            t.Fatalf("Chunk size not within limits: (%d)", chunk.Size)
+        }
+    }
+
+    options := []MerkleOptions {
+        { Algorithm: HashAlgorithmSha256, ContentDefined: true, ChunkSize: 1000 },
+        { Algorithm: HashAlgorithmSha256, ContentDefined: true, ChunkSize: 1024, MinChunkSize: 2048 },
+        { Algorithm: HashAlgorithmSha256, ChunkSize: -1 },
+        { Algorithm: "md4" },
+    }
+
+    for _, o := range options {
+        if _, err := NewMerkleWriter(o); err == nil {
+            t.Fatalf("Expected error for options: %v", o)
+        }
+    }
+}
+
+func TestVerifyMerkleChunk(t *testing.T) {
+    data := merkleTestData(10000)
+    result := getMerkleTestResult(t, MerkleOptions{ Algorithm: HashAlgorithmSha256, ChunkSize: 4096 }, data)
+
+    chunk := result.Chunks[1]
+    part := data[chunk.Offset:chunk.Offset + int64(chunk.Size)]
+
+    if ok, err := VerifyMerkleChunk(chunk, part); err != nil || ok != true {
+        t.Fatalf("Chunk did not verify: %v", err)
+    }
+
+    damaged := bytes.Repeat([]byte { 0 }, chunk.Size)
+    if ok, err := VerifyMerkleChunk(chunk, damaged); err != nil || ok != false {
+        t.Fatalf("Damaged chunk should not verify: %v", err)
+    }
+}
@@ -0,0 +1,66 @@
+package ricommon
+
+import (
+    "hash"
+    "io"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// DigestWriter Computes a self-describing digest of everything written to it,
+// so that streams can be hashed without buffering them.
+type DigestWriter struct {
+    algorithm string
+    h hash.Hash
+    count int64
+}
+
+// NewDigestWriter Create a writer for the algorithm.
+func NewDigestWriter(algorithm string) (dw *DigestWriter, err error) {
+    h, err := NewHasher(algorithm)
+    if err != nil {
+        return nil, err
+    }
+
+    dw = &DigestWriter{
+        algorithm: algorithm,
+        h: h,
+    }
+
+    return dw, nil
+}
+
+func (dw *DigestWriter) Write(p []byte) (n int, err error) {
+    n, err = dw.h.Write(p)
+    dw.count += int64(n)
+
+    return n, err
+}
+
+// Count Return the number of bytes written.
+func (dw *DigestWriter) Count() int64 {
+    return dw.count
+}
+
+// Digest Return the digest of what has been written so far.
+func (dw *DigestWriter) Digest() string {
+    return FormatDigest(dw.algorithm, dw.h.Sum(nil))
+}
+
+// EncodeReaderToDigestString Return the self-describing digest of everything
+// in the reader and its size.
+func EncodeReaderToDigestString(algorithm string, r io.Reader) (digest string, size int64, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    dw, err := NewDigestWriter(algorithm)
+    log.PanicIf(err)
+
+    _, err = io.Copy(dw, r)
+    log.PanicIf(err)
+
+    return dw.Digest(), dw.Count(), nil
+}
@@ -0,0 +1,37 @@
+package ricommon
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestEncodeReaderToDigestString(t *testing.T) {
+    digest, size, err := EncodeReaderToDigestString(HashAlgorithmSha256, strings.NewReader("abc"))
+    if err != nil {
+        t.Fatalf("Reader not hashed: %s", err)
+    } else if digest != "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+        t.Fatalf("Digest not correct: [%s]", digest)
+    } else if size != 3 {
+        t.Fatalf("Size not correct: (%d)", size)
+    }
+}
+
+func TestDigestWriter(t *testing.T) {
+    dw, err := NewDigestWriter(HashAlgorithmSha1)
+    if err != nil {
+        t.Fatalf("Writer not created: %s", err)
+    }
+
+    dw.Write([]byte("a"))
+    dw.Write([]byte("bc"))
+
+    if digest := dw.Digest(); digest != "sha1:a9993e364706816aba3e25717850c26c9cd0d89d" {
+        t.Fatalf("Digest not correct: [%s]", digest)
+    } else if dw.Count() != 3 {
+        t.Fatalf("Count not correct: (%d)", dw.Count())
+    }
+
+    if _, err := NewDigestWriter("md4"); err == nil {
+        t.Fatalf("Expected error for unknown algorithm.")
+    }
+}
@@ -0,0 +1,740 @@
+package ricommon
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strconv"
+    "strings"
+    "time"
+
+    "compress/zlib"
+    "encoding/binary"
+    "encoding/xml"
+
+    "github.com/rwcarlsen/goexif/exif"
+    "github.com/dsoprea/go-logging"
+)
+
+// Image format names
+const (
+    ImageFormatJpeg = "jpeg"
+    ImageFormatTiff = "tiff"
+    ImageFormatPng = "png"
+    ImageFormatHeif = "heif"
+    ImageFormatWebp = "webp"
+    ImageFormatXmp = "xmp"
+)
+
+// Image metadata limits
+const (
+    // The most that NewImageMetadataWithReader will buffer.
+    ImageMetadataMaxSize = 64 * 1024 * 1024
+)
+
+// XMP namespaces
+const (
+    xmpNamespaceExif = "http://ns.adobe.com/exif/1.0/"
+    xmpNamespaceXmp = "http://ns.adobe.com/xap/1.0/"
+    xmpNamespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+)
+
+// Errors
+var (
+    ErrImageFormatNotSupported = errors.New("image format not supported")
+)
+
+// Other
+var (
+    pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+    // The ISOBMFF brands that identify a HEIF container.
+    heifBrands = map[string]bool {
+        "heic": true,
+        "heix": true,
+        "hevc": true,
+        "hevx": true,
+        "heim": true,
+        "heis": true,
+        "mif1": true,
+        "msf1": true,
+    }
+
+    // The PNG text keyword that XMP is stored under.
+    pngXmpKeyword = "XML:com.adobe.xmp"
+
+    // XMP dates may be truncated and the zone is optional.
+    xmpTimestampLayouts = []string {
+        time.RFC3339Nano,
+        "2006-01-02T15:04Z07:00",
+    }
+
+    xmpWallClockTimestampLayouts = []string {
+        "2006-01-02T15:04:05.999999999",
+        "2006-01-02T15:04",
+        "2006-01-02",
+    }
+)
+
+// ImageMetadata Describes the metadata found in an image, regardless of its
+// format.
+type ImageMetadata struct {
+    // Format One of the ImageFormat* names.
+    Format string
+
+    // Exif The information from the embedded EXIF or, if there was none, from
+    // the XMP. Nil if neither were present.
+    Exif *ImageExif
+
+    // Xmp The raw XMP packet, if one was present.
+    Xmp []byte
+
+    // Text The textual key-value pairs (only PNG has these).
+    Text map[string]string
+}
+
+// SniffImageFormat Identify the image format from the first few bytes of the
+// file. Returns an empty string if not recognized. At least sixteen bytes
+// should be given (more are required to recognize XMP reliably).
+func SniffImageFormat(header []byte) string {
+    if len(header) >= 3 && header[0] == 0xff && header[1] == jpegMarkerSoi && header[2] == 0xff {
+        return ImageFormatJpeg
+    } else if bytes.HasPrefix(header, []byte("II*\x00")) == true || bytes.HasPrefix(header, []byte("MM\x00*")) == true {
+        return ImageFormatTiff
+    } else if bytes.HasPrefix(header, pngSignature) == true {
+        return ImageFormatPng
+    } else if len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP" {
+        return ImageFormatWebp
+    } else if isHeifHeader(header) == true {
+        return ImageFormatHeif
+    }
+
+    trimmed := bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), " \t\r\n")
+    if bytes.HasPrefix(trimmed, []byte("<?xpacket")) == true || bytes.HasPrefix(trimmed, []byte("<x:xmpmeta")) == true {
+        return ImageFormatXmp
+    } else if bytes.HasPrefix(trimmed, []byte("<?xml")) == true && bytes.Contains(trimmed, []byte("xmpmeta")) == true {
+        return ImageFormatXmp
+    }
+
+    return ""
+}
+
+// isHeifHeader Return whether the leading "ftyp" box has a HEIF brand as either
+// its major or one of its compatible brands.
+func isHeifHeader(header []byte) bool {
+    if len(header) < 12 || string(header[4:8]) != "ftyp" {
+        return false
+    }
+
+    size := int(binary.BigEndian.Uint32(header))
+    if size > len(header) {
+        size = len(header)
+    }
+
+    if heifBrands[string(header[8:12])] == true {
+        return true
+    }
+
+    // Skip the major brand and the minor version.
+    for i := 16; i + 4 <= size; i += 4 {
+        if heifBrands[string(header[i:i + 4])] == true {
+            return true
+        }
+    }
+
+    return false
+}
+
+// NewImageMetadataWithReader Sniff the format of the image and extract its
+// EXIF, XMP, and text metadata. The image is buffered in memory, so images
+// larger than ImageMetadataMaxSize are rejected.
+func NewImageMetadataWithReader(r io.Reader) (im *ImageMetadata, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    raw, err := ioutil.ReadAll(io.LimitReader(r, ImageMetadataMaxSize + 1))
+    log.PanicIf(err)
+
+    if len(raw) > ImageMetadataMaxSize {
+        log.Panic(fmt.Errorf("image exceeds (%d) bytes", ImageMetadataMaxSize))
+    }
+
+    header := raw
+    if len(header) > 512 {
+        header = header[:512]
+    }
+
+    im = &ImageMetadata{
+        Format: SniffImageFormat(header),
+    }
+
+    var exifData []byte
+
+    switch im.Format {
+    case ImageFormatJpeg, ImageFormatTiff:
+        exifData = raw
+
+        if im.Format == ImageFormatJpeg {
+            im.Xmp = findJpegXmp(raw)
+        }
+    case ImageFormatPng:
+        exifData, im.Xmp, im.Text = readPngMetadata(raw)
+    case ImageFormatWebp:
+        exifData, im.Xmp = readWebpMetadata(raw)
+    case ImageFormatHeif:
+        exifData, im.Xmp = readHeifMetadata(raw)
+    case ImageFormatXmp:
+        im.Xmp = raw
+    default:
+        log.Panic(ErrImageFormatNotSupported)
+    }
+
+    if exifData != nil {
+        if x, err := exif.Decode(bytes.NewReader(exifData)); err == nil {
+            im.Exif = newImageExifWithExif(x)
+        } else {
+            exifLog.Warningf(nil, "Could not decode EXIF in [%s] image: [%s]", im.Format, err)
+        }
+    }
+
+    if im.Exif == nil && im.Xmp != nil {
+        im.Exif, err = newImageExifWithXmp(im.Xmp)
+        log.PanicIf(err)
+    }
+
+    return im, nil
+}
+
+// findJpegXmp Return the XMP packet from the APP1 segments, or nil.
+func findJpegXmp(raw []byte) []byte {
+    for i := 2; i + 4 <= len(raw); {
+        if raw[i] != 0xff {
+            return nil
+        }
+
+        marker := raw[i + 1]
+        if marker == jpegMarkerSos || marker == jpegMarkerEoi {
+            return nil
+        }
+
+        length := int(binary.BigEndian.Uint16(raw[i + 2:]))
+        end := i + 2 + length
+        if length < 2 || end > len(raw) {
+            return nil
+        }
+
+        payload := raw[i + 4:end]
+        if marker == jpegMarkerApp1 && bytes.HasPrefix(payload, jpegXmpPrefix) == true {
+            return payload[len(jpegXmpPrefix):]
+        }
+
+        i = end
+    }
+
+    return nil
+}
+
+// readPngMetadata Walk the PNG chunks, collecting the eXIf chunk, the XMP
+// packet, and the text chunks.
+func readPngMetadata(raw []byte) (exifData, xmp []byte, text map[string]string) {
+    text = make(map[string]string)
+
+    for i := len(pngSignature); i + 12 <= len(raw); {
+        length := int(binary.BigEndian.Uint32(raw[i:]))
+        chunkType := string(raw[i + 4:i + 8])
+
+        start := i + 8
+        end := start + length
+        if length < 0 || end + 4 > len(raw) {
+            log.Panic(fmt.Errorf("PNG chunk [%s] overruns the image", chunkType))
+        }
+
+        data := raw[start:end]
+        i = end + 4
+
+        switch chunkType {
+        case "eXIf":
+            exifData = data
+        case "tEXt":
+            if parts := bytes.SplitN(data, []byte { 0 }, 2); len(parts) == 2 {
+                text[string(parts[0])] = decodeLatin1(parts[1])
+            }
+        case "zTXt":
+            if parts := bytes.SplitN(data, []byte { 0 }, 2); len(parts) == 2 && len(parts[1]) > 0 {
+                if value, err := inflatePngText(parts[1][1:]); err == nil {
+                    text[string(parts[0])] = decodeLatin1(value)
+                } else {
+                    exifLog.Warningf(nil, "Could not decompress PNG text [%s]: [%s]", parts[0], err)
+                }
+            }
+        case "iTXt":
+            keyword, value, err := parsePngInternationalText(data)
+            if err != nil {
+                exifLog.Warningf(nil, "Could not parse PNG international text: [%s]", err)
+            } else if keyword == pngXmpKeyword {
+                xmp = value
+            } else {
+                text[keyword] = string(value)
+            }
+        case "IEND":
+            return exifData, xmp, text
+        }
+    }
+
+    return exifData, xmp, text
+}
+
+// parsePngInternationalText Parse an iTXt chunk, which is laid out as: keyword,
+// NUL, compression flag, compression method, language tag, NUL, translated
+// keyword, NUL, and (optionally compressed) UTF-8 text.
+func parsePngInternationalText(data []byte) (keyword string, value []byte, err error) {
+    parts := bytes.SplitN(data, []byte { 0 }, 2)
+    if len(parts) != 2 || len(parts[1]) < 2 {
+        return "", nil, fmt.Errorf("iTXt chunk is truncated")
+    }
+
+    keyword = string(parts[0])
+    isCompressed := parts[1][0] == 1
+
+    rest := bytes.SplitN(parts[1][2:], []byte { 0 }, 3)
+    if len(rest) != 3 {
+        return "", nil, fmt.Errorf("iTXt chunk is truncated: [%s]", keyword)
+    }
+
+    value = rest[2]
+    if isCompressed == true {
+        if value, err = inflatePngText(value); err != nil {
+            return "", nil, err
+        }
+    }
+
+    return keyword, value, nil
+}
+
+func inflatePngText(data []byte) (value []byte, err error) {
+    zr, err := zlib.NewReader(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+
+    defer zr.Close()
+
+    return ioutil.ReadAll(zr)
+}
+
+func decodeLatin1(data []byte) string {
+    runes := make([]rune, len(data))
+    for i, b := range data {
+        runes[i] = rune(b)
+    }
+
+    return string(runes)
+}
+
+// readWebpMetadata Walk the RIFF chunks, collecting the EXIF and XMP chunks.
+func readWebpMetadata(raw []byte) (exifData, xmp []byte) {
+    for i := 12; i + 8 <= len(raw); {
+        chunkType := string(raw[i:i + 4])
+        length := int(binary.LittleEndian.Uint32(raw[i + 4:]))
+
+        start := i + 8
+        end := start + length
+        if length < 0 || end > len(raw) {
+            log.Panic(fmt.Errorf("WebP chunk [%s] overruns the image", chunkType))
+        }
+
+        switch chunkType {
+        case "EXIF":
+            exifData = raw[start:end]
+        case "XMP ":
+            xmp = raw[start:end]
+        }
+
+        // Chunks are padded to an even size.
+        i = end + length % 2
+    }
+
+    return exifData, xmp
+}
+
+// isobmffBox Describes one box in an ISO base-media file.
+type isobmffBox struct {
+    boxType string
+    data []byte
+}
+
+// readIsobmffBoxes Split the given data into its boxes.
+func readIsobmffBoxes(data []byte) (boxes []isobmffBox) {
+    boxes = make([]isobmffBox, 0)
+
+    for i := 0; i + 8 <= len(data); {
+        size := uint64(binary.BigEndian.Uint32(data[i:]))
+        boxType := string(data[i + 4:i + 8])
+        headerSize := uint64(8)
+
+        if size == 1 {
+            if i + 16 > len(data) {
+                log.Panic(fmt.Errorf("box [%s] is truncated", boxType))
+            }
+
+            size = binary.BigEndian.Uint64(data[i + 8:])
+            headerSize = 16
+        } else if size == 0 {
+            size = uint64(len(data) - i)
+        }
+
+        if size < headerSize || uint64(i) + size > uint64(len(data)) {
+            log.Panic(fmt.Errorf("box [%s] overruns its container", boxType))
+        }
+
+        boxes = append(boxes, isobmffBox{
+            boxType: boxType,
+            data: data[uint64(i) + headerSize:uint64(i) + size],
+        })
+
+        i += int(size)
+    }
+
+    return boxes
+}
+
+// readHeifMetadata Find the "Exif" and XMP items through the item-info and
+// item-location boxes of the "meta" box.
+func readHeifMetadata(raw []byte) (exifData, xmp []byte) {
+    var meta []byte
+    for _, box := range readIsobmffBoxes(raw) {
+        if box.boxType == "meta" {
+            meta = box.data
+            break
+        }
+    }
+
+    if len(meta) < 4 {
+        return nil, nil
+    }
+
+    exifItemId := uint32(0)
+    xmpItemId := uint32(0)
+    var locations map[uint32][]byte
+
+    // "meta" is a full-box. Skip the version and flags.
+    for _, box := range readIsobmffBoxes(meta[4:]) {
+        switch box.boxType {
+        case "iinf":
+            exifItemId, xmpItemId = readHeifItemInfo(box.data)
+        case "iloc":
+            locations = readHeifItemLocations(box.data, raw)
+        }
+    }
+
+    if exifItemId != 0 {
+        if item := locations[exifItemId]; len(item) > 4 {
+            // The item starts with the offset of the TIFF header past any
+            // "Exif\0\0" prefix.
+            offset := 4 + uint64(binary.BigEndian.Uint32(item))
+            if offset < uint64(len(item)) {
+                exifData = item[offset:]
+            }
+        }
+    }
+
+    if xmpItemId != 0 {
+        xmp = locations[xmpItemId]
+    }
+
+    return exifData, xmp
+}
+
+// readHeifItemInfo Return the IDs of the EXIF and XMP items, if present.
+func readHeifItemInfo(data []byte) (exifItemId, xmpItemId uint32) {
+    if len(data) < 6 {
+        return 0, 0
+    }
+
+    entriesOffset := 6
+    if data[0] != 0 {
+        entriesOffset = 8
+    }
+
+    if entriesOffset > len(data) {
+        return 0, 0
+    }
+
+    for _, box := range readIsobmffBoxes(data[entriesOffset:]) {
+        if box.boxType != "infe" || len(box.data) < 4 || box.data[0] < 2 {
+            continue
+        }
+
+        var itemId uint32
+        var rest []byte
+
+        if box.data[0] == 2 && len(box.data) >= 12 {
+            itemId = uint32(binary.BigEndian.Uint16(box.data[4:]))
+            rest = box.data[8:]
+        } else if box.data[0] == 3 && len(box.data) >= 14 {
+            itemId = binary.BigEndian.Uint32(box.data[4:])
+            rest = box.data[10:]
+        } else {
+            continue
+        }
+
+        itemType := string(rest[:4])
+        if itemType == "Exif" {
+            exifItemId = itemId
+        } else if itemType == "mime" {
+            // Item name, then content-type.
+            fields := bytes.SplitN(rest[4:], []byte { 0 }, 3)
+            if len(fields) >= 2 && string(fields[1]) == "application/rdf+xml" {
+                xmpItemId = itemId
+            }
+        }
+    }
+
+    return exifItemId, xmpItemId
+}
+
+// readHeifItemLocations Return the data of each item that is stored by file
+// offset. Items stored any other way are omitted.
+func readHeifItemLocations(data []byte, raw []byte) (locations map[uint32][]byte) {
+    locations = make(map[uint32][]byte)
+
+    if len(data) < 8 {
+        return locations
+    }
+
+    version := data[0]
+    offsetSize := int(data[4] >> 4)
+    lengthSize := int(data[4] & 0xf)
+    baseOffsetSize := int(data[5] >> 4)
+    indexSize := 0
+    if version == 1 || version == 2 {
+        indexSize = int(data[5] & 0xf)
+    }
+
+    i := 6
+    readUint := func(size int) uint64 {
+        if i + size > len(data) {
+            log.Panic(fmt.Errorf("iloc box is truncated"))
+        }
+
+        n := uint64(0)
+        for _, b := range data[i:i + size] {
+            n = n << 8 | uint64(b)
+        }
+
+        i += size
+        return n
+    }
+
+    itemIdSize := 2
+    if version == 2 {
+        itemIdSize = 4
+    }
+
+    itemCount := int(readUint(itemIdSize))
+    for j := 0; j < itemCount; j++ {
+        itemId := uint32(readUint(itemIdSize))
+
+        constructionMethod := uint64(0)
+        if version == 1 || version == 2 {
+            constructionMethod = readUint(2) & 0xf
+        }
+
+        // Data-reference index.
+        readUint(2)
+
+        baseOffset := readUint(baseOffsetSize)
+        extentCount := int(readUint(2))
+
+        item := make([]byte, 0)
+        for k := 0; k < extentCount; k++ {
+            if indexSize > 0 {
+                readUint(indexSize)
+            }
+
+            extentOffset := baseOffset + readUint(offsetSize)
+            extentLength := readUint(lengthSize)
+
+            if constructionMethod != 0 {
+                continue
+            }
+
+            if extentOffset + extentLength > uint64(len(raw)) {
+                log.Panic(fmt.Errorf("HEIF item (%d) overruns the image", itemId))
+            }
+
+            item = append(item, raw[extentOffset:extentOffset + extentLength]...)
+        }
+
+        if constructionMethod == 0 {
+            locations[itemId] = item
+        }
+    }
+
+    return locations
+}
+
+// newImageExifWithXmp Extract the timestamp and coordinates from the EXIF
+// properties of an XMP packet. Returns nil if none were present.
+func newImageExifWithXmp(packet []byte) (ie *ImageExif, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = DistillError(state)
+        }
+    }()
+
+    properties := make(map[string]string)
+
+    d := xml.NewDecoder(bytes.NewReader(packet))
+    d.Strict = false
+
+    current := ""
+    value := ""
+
+    for {
+        token, err := d.Token()
+        if err == io.EOF {
+            break
+        }
+
+        log.PanicIf(err)
+
+        switch t := token.(type) {
+        case xml.StartElement:
+            // Properties may be given as attributes of the description...
+            for _, attr := range t.Attr {
+                if key := xmpPropertyKey(attr.Name); key != "" {
+                    properties[key] = attr.Value
+                }
+            }
+
+            // ...or as simple elements.
+            current = xmpPropertyKey(t.Name)
+            value = ""
+        case xml.CharData:
+            if current != "" {
+                value += string(t)
+            }
+        case xml.EndElement:
+            if current != "" && current == xmpPropertyKey(t.Name) {
+                properties[current] = strings.TrimSpace(value)
+            }
+
+            current = ""
+        }
+    }
+
+    ie = new(ImageExif)
+    found := false
+
+    latitudeRaw := properties["exif:GPSLatitude"]
+    longitudeRaw := properties["exif:GPSLongitude"]
+
+    if latitudeRaw != "" && longitudeRaw != "" {
+        latitude, latitudeErr := parseXmpCoordinate(latitudeRaw)
+        longitude, longitudeErr := parseXmpCoordinate(longitudeRaw)
+
+        if latitudeErr != nil || longitudeErr != nil {
+            exifLog.Warningf(nil, "Ignoring XMP coordinates that are not valid: [%s] [%s]", latitudeRaw, longitudeRaw)
+        } else {
+            ie.Latitude, ie.Longitude = latitude, longitude
+            found = true
+        }
+    }
+
+    for _, key := range []string { "exif:DateTimeOriginal", "photoshop:DateCreated", "xmp:CreateDate" } {
+        if raw := properties[key]; raw != "" {
+            if timestamp, hasZone, err := parseXmpTimestamp(raw); err == nil {
+                if hasZone == true {
+                    ie.Timestamp = timestamp
+                    ie.TimestampMethod = TimestampMethodExplicitOffset
+                } else {
+                    resolveWallClockTimestamp(timestamp, ie)
+                }
+
+                found = true
+                break
+            }
+        }
+    }
+
+    if found == false {
+        return nil, nil
+    }
+
+    return ie, nil
+}
+
+// xmpPropertyKey Return a prefixed key for the properties that we recognize,
+// or an empty string.
+func xmpPropertyKey(name xml.Name) string {
+    switch name.Space {
+    case xmpNamespaceExif:
+        return "exif:" + name.Local
+    case xmpNamespaceXmp:
+        return "xmp:" + name.Local
+    case xmpNamespacePhotoshop:
+        return "photoshop:" + name.Local
+    }
+
+    return ""
+}
+
+// parseXmpTimestamp Parse an XMP date. If it has no zone, the wall-clock time
+// is returned in UTC.
+func parseXmpTimestamp(raw string) (timestamp time.Time, hasZone bool, err error) {
+    for _, layout := range xmpTimestampLayouts {
+        if timestamp, err = time.Parse(layout, raw); err == nil {
+            return timestamp, true, nil
+        }
+    }
+
+    for _, layout := range xmpWallClockTimestampLayouts {
+        if timestamp, err = time.Parse(layout, raw); err == nil {
+            return timestamp, false, nil
+        }
+    }
+
+    return time.Time{}, false, fmt.Errorf("XMP timestamp not valid: [%s]", raw)
+}
+
+// parseXmpCoordinate Parse an XMP GPS coordinate, which has the form
+// "DDD,MM,SSk" or "DDD,MM.mmk" where "k" is one of N, S, E, or W.
+func parseXmpCoordinate(raw string) (coordinate float64, err error) {
+    raw = strings.TrimSpace(raw)
+    if len(raw) < 2 {
+        return 0, fmt.Errorf("XMP coordinate not valid: [%s]", raw)
+    }
+
+    direction := raw[len(raw) - 1]
+    parts := strings.Split(raw[:len(raw) - 1], ",")
+    if len(parts) < 2 || len(parts) > 3 {
+        return 0, fmt.Errorf("XMP coordinate not valid: [%s]", raw)
+    }
+
+    divisor := 1.0
+    for _, part := range parts {
+        n, err := strconv.ParseFloat(part, 64)
+        if err != nil {
+            return 0, fmt.Errorf("XMP coordinate not valid: [%s]", raw)
+        }
+
+        coordinate += n / divisor
+        divisor *= 60
+    }
+
+    switch direction {
+    case 'N', 'E':
+    case 'S', 'W':
+        coordinate = -coordinate
+    default:
+        return 0, fmt.Errorf("XMP coordinate direction not valid: [%s]", raw)
+    }
+
+    return coordinate, nil
+}
@@ -0,0 +1,110 @@
+package ricommon
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestNewImageMetadataWithReader_Exif(t *testing.T) {
+    filenames := map[string]string {
+        "exif.jpg": ImageFormatJpeg,
+        "exif.png": ImageFormatPng,
+        "exif.webp": ImageFormatWebp,
+        "exif.heic": ImageFormatHeif,
+    }
+
+    timestamp := time.Date(2021, 6, 1, 12, 0, 0, 0, time.FixedZone("", 2 * 60 * 60))
+
+    for filename, format := range filenames {
+        im, err := NewImageMetadataWithReader(bytes.NewReader(getTestImage(t, filename)))
+        if err != nil {
+            t.Fatalf("Metadata of [%s] not read: %s", filename, err)
+        } else if im.Format != format {
+            t.Fatalf("Format of [%s] not correct: [%s]", filename, im.Format)
+        } else if im.Exif == nil {
+            t.Fatalf("EXIF of [%s] not found.", filename)
+        } else if bytes.Contains(im.Xmp, []byte("<x:xmpmeta")) != true {
+            t.Fatalf("XMP of [%s] not found.", filename)
+        }
+
+        if isCoordinateClose(im.Exif.Latitude, testExifLatitude) != true || isCoordinateClose(im.Exif.Longitude, testExifLongitude) != true {
+            t.Fatalf("Coordinates of [%s] not correct: (%f, %f)", filename, im.Exif.Latitude, im.Exif.Longitude)
+        } else if im.Exif.Timestamp.Equal(timestamp) != true || im.Exif.TimestampMethod != TimestampMethodExplicitOffset {
+            t.Fatalf("Timestamp of [%s] not correct: [%s] [%s]", filename, im.Exif.Timestamp, im.Exif.TimestampMethod)
+        }
+    }
+}
+
+func TestNewImageMetadataWithReader_PngText(t *testing.T) {
+    im, err := NewImageMetadataWithReader(bytes.NewReader(getTestImage(t, "exif.png")))
+    if err != nil {
+        t.Fatalf("Metadata not read: %s", err)
+    }
+
+    // tEXt is Latin-1. The XMP is not repeated as text.
+    if len(im.Text) != 1 || im.Text["Comment"] != "café" {
+        t.Fatalf("Text not correct: %v", im.Text)
+    }
+}
+
+func TestNewImageMetadataWithReader_Xmp(t *testing.T) {
+    im, err := NewImageMetadataWithReader(bytes.NewReader(getTestImage(t, "metadata.xmp")))
+    if err != nil {
+        t.Fatalf("Metadata not read: %s", err)
+    } else if im.Format != ImageFormatXmp {
+        t.Fatalf("Format not correct: [%s]", im.Format)
+    } else if im.Exif == nil {
+        t.Fatalf("EXIF not found.")
+    }
+
+    timestamp := time.Date(2022, 3, 4, 5, 6, 7, 0, time.FixedZone("", 60 * 60))
+
+    if isCoordinateClose(im.Exif.Latitude, 59.325) != true || isCoordinateClose(im.Exif.Longitude, 18.07) != true {
+        t.Fatalf("Coordinates not correct: (%f, %f)", im.Exif.Latitude, im.Exif.Longitude)
+    } else if im.Exif.Timestamp.Equal(timestamp) != true {
+        t.Fatalf("Timestamp not correct: [%s]", im.Exif.Timestamp)
+    }
+}
+
+func TestNewImageExifWithXmp_CoordinateNotValid(t *testing.T) {
+    packet := bytes.Replace(getTestImage(t, "metadata.xmp"), []byte("59,19.5N"), []byte("59,19.5Q"), 1)
+
+    ie, err := newImageExifWithXmp(packet)
+    if err != nil {
+        t.Fatalf("Coordinate should have been skipped: %s", err)
+    } else if ie == nil {
+        t.Fatalf("Timestamp should still have been found.")
+    } else if ie.Latitude != 0 || ie.Longitude != 0 {
+        t.Fatalf("Coordinates should not be set: (%f, %f)", ie.Latitude, ie.Longitude)
+    } else if ie.Timestamp.IsZero() == true {
+        t.Fatalf("Timestamp not found.")
+    }
+}
+
+func TestNewImageMetadataWithReader_NotSupported(t *testing.T) {
+    _, err := NewImageMetadataWithReader(bytes.NewReader([]byte("GIF89a\x01\x00\x01\x00")))
+    if log.Is(err, ErrImageFormatNotSupported) != true {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+}
+
+func TestSniffImageFormat(t *testing.T) {
+    filenames := map[string]string {
+        "exif.jpg": ImageFormatJpeg,
+        "exif.png": ImageFormatPng,
+        "exif.webp": ImageFormatWebp,
+        "exif.heic": ImageFormatHeif,
+        "metadata.xmp": ImageFormatXmp,
+    }
+
+    for filename, format := range filenames {
+        header := getTestImage(t, filename)[:64]
+
+        if sniffed := SniffImageFormat(header); sniffed != format {
+            t.Fatalf("Format of [%s] not correct: [%s]", filename, sniffed)
+        }
+    }
+}
@@ -24,6 +24,8 @@ type RecordsetRecord interface {
     Id() string
     
     // Used to compare two separate versions of the data for the same entity.
+    // Digests that are stored or shared with other services for this should
+    // come from EncodeToCanonicalDigestString.
     IsUnchanged(olderRecord RecordsetRecord) bool
 
     String() string
@@ -0,0 +1,369 @@
+package ridata
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "encoding/binary"
+    "encoding/hex"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// ID constants
+const (
+    UlidLength = 26
+
+    SnowflakeNodeBits = 10
+    SnowflakeSequenceBits = 12
+
+    SnowflakeMaxNodeId = 1 << SnowflakeNodeBits - 1
+    snowflakeMaxSequence = 1 << SnowflakeSequenceBits - 1
+    snowflakeMaxMilliseconds = 1 << (64 - 1 - SnowflakeNodeBits - SnowflakeSequenceBits) - 1
+
+    uuidV7MaxCounter = 1 << 12 - 1
+)
+
+// Errors
+var (
+    ErrIdNotValid = errors.New("id not valid")
+)
+
+// Other
+var (
+    // The epoch used when a Snowflake generator isn't given one.
+    SnowflakeDefaultEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    defaultUlidGenerator = NewUlidGenerator()
+    defaultUuidV7Generator = NewUuidV7Generator()
+
+    crockfordDecoding [256]int8
+
+    // Replaced in tests.
+    idNow = time.Now
+)
+
+func init() {
+    for i := range crockfordDecoding {
+        crockfordDecoding[i] = -1
+    }
+
+    for i := 0; i < len(CrockfordAlphabet); i++ {
+        c := CrockfordAlphabet[i]
+
+        crockfordDecoding[c] = int8(i)
+        if c >= 'A' {
+            crockfordDecoding[c + ('a' - 'A')] = int8(i)
+        }
+    }
+
+    // Crockford's base32 reads the commonly-confused letters as digits.
+    for _, c := range "oO" {
+        crockfordDecoding[c] = 0
+    }
+
+    for _, c := range "iIlL" {
+        crockfordDecoding[c] = 1
+    }
+}
+
+// milliseconds Return the current Unix time in milliseconds.
+func milliseconds() int64 {
+    return idNow().UnixNano() / int64(time.Millisecond)
+}
+
+// UlidGenerator Produces ULIDs (48-bit millisecond timestamp and 80 random
+// bits in Crockford base32). IDs from the same generator always increase:
+// within a millisecond, or if the clock goes backward, the random part of the
+// previous ID is incremented. Safe for concurrent use.
+type UlidGenerator struct {
+    lastMilliseconds int64
+    lastRandom [10]byte
+
+    locker sync.Mutex
+}
+
+func NewUlidGenerator() *UlidGenerator {
+    return new(UlidGenerator)
+}
+
+// Next Return a new ULID.
+func (ug *UlidGenerator) Next() string {
+    ug.locker.Lock()
+    defer ug.locker.Unlock()
+
+    now := milliseconds()
+
+    if now > ug.lastMilliseconds {
+        ug.lastMilliseconds = now
+        copy(ug.lastRandom[:], RandomBytes(10))
+    } else if incrementBytes(ug.lastRandom[:]) == false {
+        // The random part overflowed. Borrow the next millisecond.
+        ug.lastMilliseconds++
+        copy(ug.lastRandom[:], RandomBytes(10))
+    }
+
+    raw := make([]byte, 16)
+    putUint48(raw, uint64(ug.lastMilliseconds))
+    copy(raw[6:], ug.lastRandom[:])
+
+    return encodeUlid(raw)
+}
+
+// NewUlid Return a ULID from the default generator.
+func NewUlid() string {
+    return defaultUlidGenerator.Next()
+}
+
+// ParseUlid Return the timestamp of the ULID.
+func ParseUlid(ulid string) (timestamp time.Time, err error) {
+    raw, err := decodeUlid(ulid)
+    if err != nil {
+        return time.Time{}, err
+    }
+
+    ms := int64(getUint48(raw))
+    return time.Unix(0, ms * int64(time.Millisecond)).UTC(), nil
+}
+
+// encodeUlid Encode 128 bits as 26 base32 characters (the first character
+// only carries three bits).
+func encodeUlid(raw []byte) string {
+    high := binary.BigEndian.Uint64(raw[:8])
+    low := binary.BigEndian.Uint64(raw[8:])
+
+    output := make([]byte, UlidLength)
+    for i := UlidLength - 1; i >= 0; i-- {
+        output[i] = CrockfordAlphabet[low & 0x1f]
+
+        low = low >> 5 | high << 59
+        high >>= 5
+    }
+
+    return string(output)
+}
+
+func decodeUlid(ulid string) (raw []byte, err error) {
+    if len(ulid) != UlidLength {
+        return nil, ErrIdNotValid
+    }
+
+    var high, low uint64
+    for i := 0; i < UlidLength; i++ {
+        value := crockfordDecoding[ulid[i]]
+        if value < 0 || i == 0 && value > 7 {
+            return nil, ErrIdNotValid
+        }
+
+        high = high << 5 | low >> 59
+        low = low << 5 | uint64(value)
+    }
+
+    raw = make([]byte, 16)
+    binary.BigEndian.PutUint64(raw[:8], high)
+    binary.BigEndian.PutUint64(raw[8:], low)
+
+    return raw, nil
+}
+
+// NewUuidV4 Return a random (version 4) UUID.
+func NewUuidV4() string {
+    raw := RandomBytes(16)
+
+    raw[6] = raw[6] & 0x0f | 0x40
+    raw[8] = raw[8] & 0x3f | 0x80
+
+    return formatUuid(raw)
+}
+
+// UuidV7Generator Produces time-ordered (version 7) UUIDs. The 12 bits after
+// the version hold a counter that is reset to a random value each millisecond
+// and incremented within it (RFC 9562, method 1), so IDs from the same
+// generator always increase. Safe for concurrent use.
+type UuidV7Generator struct {
+    lastMilliseconds int64
+    counter uint16
+
+    locker sync.Mutex
+}
+
+func NewUuidV7Generator() *UuidV7Generator {
+    return new(UuidV7Generator)
+}
+
+// Next Return a new UUID.
+func (uvg *UuidV7Generator) Next() string {
+    uvg.locker.Lock()
+    defer uvg.locker.Unlock()
+
+    now := milliseconds()
+    random := RandomBytes(10)
+
+    if now > uvg.lastMilliseconds {
+        uvg.lastMilliseconds = now
+
+        // Leave room to count up.
+        uvg.counter = binary.BigEndian.Uint16(random[:2]) & (uuidV7MaxCounter >> 1)
+    } else if uvg.counter == uuidV7MaxCounter {
+        uvg.lastMilliseconds++
+        uvg.counter = 0
+    } else {
+        uvg.counter++
+    }
+
+    raw := make([]byte, 16)
+    putUint48(raw, uint64(uvg.lastMilliseconds))
+    binary.BigEndian.PutUint16(raw[6:], 0x7000 | uvg.counter)
+    copy(raw[8:], random[2:])
+    raw[8] = raw[8] & 0x3f | 0x80
+
+    return formatUuid(raw)
+}
+
+// NewUuidV7 Return a UUID from the default generator.
+func NewUuidV7() string {
+    return defaultUuidV7Generator.Next()
+}
+
+// ParseUuid Return the bytes and version of the UUID.
+func ParseUuid(uuid string) (raw []byte, version int, err error) {
+    if len(uuid) != 36 || uuid[8] != '-' || uuid[13] != '-' || uuid[18] != '-' || uuid[23] != '-' {
+        return nil, 0, ErrIdNotValid
+    }
+
+    raw, err = hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+    if err != nil {
+        return nil, 0, ErrIdNotValid
+    }
+
+    return raw, int(raw[6] >> 4), nil
+}
+
+// ParseUuidV7 Return the timestamp of the version-7 UUID.
+func ParseUuidV7(uuid string) (timestamp time.Time, err error) {
+    raw, version, err := ParseUuid(uuid)
+    if err != nil {
+        return time.Time{}, err
+    } else if version != 7 {
+        return time.Time{}, fmt.Errorf("UUID is version (%d), not 7: [%s]", version, uuid)
+    }
+
+    ms := int64(getUint48(raw))
+    return time.Unix(0, ms * int64(time.Millisecond)).UTC(), nil
+}
+
+func formatUuid(raw []byte) string {
+    encoded := hex.EncodeToString(raw)
+    return encoded[:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:]
+}
+
+// SnowflakeGenerator Produces 64-bit IDs from a 41-bit millisecond timestamp
+// (relative to an epoch), a 10-bit node ID, and a 12-bit sequence. The high
+// bit is always zero so that the IDs also fit in a signed integer. Every node
+// must have its own ID. If the sequence is exhausted, Next() waits for the
+// next millisecond. Safe for concurrent use.
+type SnowflakeGenerator struct {
+    nodeId uint64
+    epochMilliseconds int64
+
+    lastMilliseconds int64
+    sequence uint64
+
+    locker sync.Mutex
+}
+
+// NewSnowflakeGenerator Create a generator. A zero epoch means
+// SnowflakeDefaultEpoch.
+func NewSnowflakeGenerator(nodeId int, epoch time.Time) (sg *SnowflakeGenerator, err error) {
+    if nodeId < 0 || nodeId > SnowflakeMaxNodeId {
+        return nil, fmt.Errorf("snowflake node ID must be between (0) and (%d): (%d)", SnowflakeMaxNodeId, nodeId)
+    }
+
+    if epoch.IsZero() == true {
+        epoch = SnowflakeDefaultEpoch
+    }
+
+    sg = &SnowflakeGenerator{
+        nodeId: uint64(nodeId),
+        epochMilliseconds: epoch.UnixNano() / int64(time.Millisecond),
+    }
+
+    return sg, nil
+}
+
+// Next Return a new ID.
+func (sg *SnowflakeGenerator) Next() uint64 {
+    sg.locker.Lock()
+    defer sg.locker.Unlock()
+
+    now := milliseconds() - sg.epochMilliseconds
+
+    if now > sg.lastMilliseconds {
+        sg.lastMilliseconds = now
+        sg.sequence = 0
+    } else if sg.sequence < snowflakeMaxSequence {
+        // Includes the clock going backward, in which case we keep counting
+        // from the last timestamp.
+        sg.sequence++
+    } else {
+        for now <= sg.lastMilliseconds {
+            time.Sleep(time.Duration(sg.lastMilliseconds - now + 1) * time.Millisecond)
+            now = milliseconds() - sg.epochMilliseconds
+        }
+
+        sg.lastMilliseconds = now
+        sg.sequence = 0
+    }
+
+    if sg.lastMilliseconds < 0 || sg.lastMilliseconds > snowflakeMaxMilliseconds {
+        log.Panic(fmt.Errorf("snowflake timestamp out of range for epoch: (%d)", sg.lastMilliseconds))
+    }
+
+    return uint64(sg.lastMilliseconds) << (SnowflakeNodeBits + SnowflakeSequenceBits) | sg.nodeId << SnowflakeSequenceBits | sg.sequence
+}
+
+// ParseSnowflake Return the parts of an ID from a generator with the given
+// epoch (zero for SnowflakeDefaultEpoch).
+func ParseSnowflake(id uint64, epoch time.Time) (timestamp time.Time, nodeId int, sequence int) {
+    if epoch.IsZero() == true {
+        epoch = SnowflakeDefaultEpoch
+    }
+
+    ms := int64(id >> (SnowflakeNodeBits + SnowflakeSequenceBits))
+    timestamp = epoch.Add(time.Duration(ms) * time.Millisecond).UTC()
+
+    nodeId = int(id >> SnowflakeSequenceBits & SnowflakeMaxNodeId)
+    sequence = int(id & snowflakeMaxSequence)
+
+    return timestamp, nodeId, sequence
+}
+
+// incrementBytes Add one to the big-endian number. Returns false on overflow.
+func incrementBytes(raw []byte) bool {
+    for i := len(raw) - 1; i >= 0; i-- {
+        raw[i]++
+        if raw[i] != 0 {
+            return true
+        }
+    }
+
+    return false
+}
+
+func putUint48(raw []byte, n uint64) {
+    for i := 5; i >= 0; i-- {
+        raw[i] = byte(n)
+        n >>= 8
+    }
+}
+
+func getUint48(raw []byte) (n uint64) {
+    for i := 0; i < 6; i++ {
+        n = n << 8 | uint64(raw[i])
+    }
+
+    return n
+}
@@ -0,0 +1,282 @@
+package ridata
+
+import (
+    "testing"
+    "time"
+)
+
+var (
+    idTestTime = time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+)
+
+// setIdNow Freeze the clock used by the generators.
+func setIdNow(t *testing.T, now func() time.Time) {
+    original := idNow
+    idNow = now
+
+    t.Cleanup(func() {
+        idNow = original
+    })
+}
+
+func TestUlidGenerator_Next_SameMillisecond(t *testing.T) {
+    setIdNow(t, func() time.Time {
+        return idTestTime
+    })
+
+    ug := NewUlidGenerator()
+
+    last := ug.Next()
+    for i := 0; i < 1000; i++ {
+        ulid := ug.Next()
+        if ulid <= last {
+            t.Fatalf("ULID did not increase: [%s] <= [%s]", ulid, last)
+        }
+
+        timestamp, err := ParseUlid(ulid)
+        if err != nil {
+            t.Fatalf("ULID not parsed: [%s]: %s", ulid, err)
+        } else if timestamp.Equal(idTestTime) != true {
+            t.Fatalf("ULID timestamp not correct: [%s]", timestamp)
+        }
+
+        last = ulid
+    }
+}
+
+func TestUlidGenerator_Next_ClockBackward(t *testing.T) {
+    now := idTestTime
+    setIdNow(t, func() time.Time {
+        return now
+    })
+
+    ug := NewUlidGenerator()
+    first := ug.Next()
+
+    now = now.Add(-time.Second)
+
+    if second := ug.Next(); second <= first {
+        t.Fatalf("ULID did not increase: [%s] <= [%s]", second, first)
+    }
+}
+
+func TestUlidGenerator_Next_RandomOverflow(t *testing.T) {
+    setIdNow(t, func() time.Time {
+        return idTestTime
+    })
+
+    ug := NewUlidGenerator()
+    first := ug.Next()
+
+    for i := range ug.lastRandom {
+        ug.lastRandom[i] = 0xff
+    }
+
+    second := ug.Next()
+    if second <= first {
+        t.Fatalf("ULID did not increase: [%s] <= [%s]", second, first)
+    }
+
+    timestamp, err := ParseUlid(second)
+    if err != nil {
+        t.Fatalf("ULID not parsed: %s", err)
+    } else if timestamp.Equal(idTestTime.Add(time.Millisecond)) != true {
+        t.Fatalf("ULID did not borrow the next millisecond: [%s]", timestamp)
+    }
+}
+
+func TestParseUlid(t *testing.T) {
+    raw := make([]byte, 16)
+    putUint48(raw, uint64(idTestTime.UnixNano() / int64(time.Millisecond)))
+
+    ulid := encodeUlid(raw)
+    if len(ulid) != UlidLength {
+        t.Fatalf("ULID length not correct: [%s]", ulid)
+    }
+
+    timestamp, err := ParseUlid(ulid)
+    if err != nil {
+        t.Fatalf("ULID not parsed: %s", err)
+    } else if timestamp.Equal(idTestTime) != true {
+        t.Fatalf("ULID timestamp not correct: [%s]", timestamp)
+    }
+
+    // Lowercase and the confusable letters decode the same.
+    decoded, err := decodeUlid("0123456789abcdefghjkmnpqrs")
+    if err != nil {
+        t.Fatalf("Lowercase ULID not decoded: %s", err)
+    } else if encodeUlid(decoded) != "0123456789ABCDEFGHJKMNPQRS" {
+        t.Fatalf("Lowercase ULID not correct: [%s]", encodeUlid(decoded))
+    }
+
+    if _, err := decodeUlid("OILOOOOOOOOOOOOOOOOOOOOOOO"); err != nil {
+        t.Fatalf("Confusable letters not decoded: %s", err)
+    }
+
+    invalid := []string {
+        "",
+        "0123456789ABCDEFGHJKMNPQR",
+        "8ZZZZZZZZZZZZZZZZZZZZZZZZZ",
+        "0123456789ABCDEFGHJKMNPQRU",
+    }
+
+    for _, ulid := range invalid {
+        if _, err := ParseUlid(ulid); err != ErrIdNotValid {
+            t.Fatalf("ULID should not be valid: [%s]", ulid)
+        }
+    }
+}
+
+func TestUuidV7Generator_Next_SameMillisecond(t *testing.T) {
+    setIdNow(t, func() time.Time {
+        return idTestTime
+    })
+
+    uvg := NewUuidV7Generator()
+
+    last := uvg.Next()
+    for i := 0; i < 1000; i++ {
+        uuid := uvg.Next()
+        if uuid <= last {
+            t.Fatalf("UUID did not increase: [%s] <= [%s]", uuid, last)
+        }
+
+        timestamp, err := ParseUuidV7(uuid)
+        if err != nil {
+            t.Fatalf("UUID not parsed: [%s]: %s", uuid, err)
+        } else if timestamp.Equal(idTestTime) != true {
+            t.Fatalf("UUID timestamp not correct: [%s]", timestamp)
+        }
+
+        last = uuid
+    }
+}
+
+func TestUuidV7Generator_Next_CounterOverflow(t *testing.T) {
+    setIdNow(t, func() time.Time {
+        return idTestTime
+    })
+
+    uvg := NewUuidV7Generator()
+    first := uvg.Next()
+
+    uvg.counter = uuidV7MaxCounter
+
+    second := uvg.Next()
+    if second <= first {
+        t.Fatalf("UUID did not increase: [%s] <= [%s]", second, first)
+    }
+
+    timestamp, err := ParseUuidV7(second)
+    if err != nil {
+        t.Fatalf("UUID not parsed: %s", err)
+    } else if timestamp.Equal(idTestTime.Add(time.Millisecond)) != true {
+        t.Fatalf("UUID did not borrow the next millisecond: [%s]", timestamp)
+    }
+}
+
+func TestParseUuid(t *testing.T) {
+    raw, version, err := ParseUuid(NewUuidV4())
+    if err != nil {
+        t.Fatalf("UUID not parsed: %s", err)
+    } else if version != 4 {
+        t.Fatalf("UUID version not correct: (%d)", version)
+    } else if raw[8] & 0xc0 != 0x80 {
+        t.Fatalf("UUID variant not correct: (%02x)", raw[8])
+    }
+
+    uuid := NewUuidV7()
+    if raw, _, _ := ParseUuid(uuid); formatUuid(raw) != uuid {
+        t.Fatalf("UUID did not round-trip: [%s]", uuid)
+    }
+
+    if _, err := ParseUuidV7(NewUuidV4()); err == nil {
+        t.Fatalf("Version-4 UUID should not parse as version 7.")
+    }
+
+    invalid := []string {
+        "",
+        "01234567-89ab-cdef-0123-456789abcde",
+        "01234567089ab-cdef-0123-456789abcdef",
+        "0123456z-89ab-cdef-0123-456789abcdef",
+    }
+
+    for _, uuid := range invalid {
+        if _, _, err := ParseUuid(uuid); err != ErrIdNotValid {
+            t.Fatalf("UUID should not be valid: [%s]", uuid)
+        }
+    }
+}
+
+func TestSnowflakeGenerator_Next_SequenceRollover(t *testing.T) {
+    // The clock only advances once the sequence is exhausted and the
+    // generator has to wait.
+    calls := 0
+    setIdNow(t, func() time.Time {
+        calls++
+        if calls <= snowflakeMaxSequence + 2 {
+            return idTestTime
+        }
+
+        return idTestTime.Add(time.Millisecond)
+    })
+
+    sg, err := NewSnowflakeGenerator(5, time.Time{})
+    if err != nil {
+        t.Fatalf("Generator not created: %s", err)
+    }
+
+    last := uint64(0)
+    for i := 0; i <= snowflakeMaxSequence + 1; i++ {
+        id := sg.Next()
+        if id <= last {
+            t.Fatalf("ID did not increase: (%d) <= (%d)", id, last)
+        }
+
+        timestamp, nodeId, sequence := ParseSnowflake(id, time.Time{})
+        if nodeId != 5 {
+            t.Fatalf("Node ID not correct: (%d)", nodeId)
+        }
+
+        if i <= snowflakeMaxSequence {
+            if timestamp.Equal(idTestTime) != true || sequence != i {
+                t.Fatalf("ID (%d) not correct: [%s] (%d)", i, timestamp, sequence)
+            }
+        } else if timestamp.Equal(idTestTime.Add(time.Millisecond)) != true || sequence != 0 {
+            t.Fatalf("ID did not roll over: [%s] (%d)", timestamp, sequence)
+        }
+
+        last = id
+    }
+}
+
+func TestParseSnowflake(t *testing.T) {
+    setIdNow(t, func() time.Time {
+        return idTestTime
+    })
+
+    epoch := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    sg, err := NewSnowflakeGenerator(SnowflakeMaxNodeId, epoch)
+    if err != nil {
+        t.Fatalf("Generator not created: %s", err)
+    }
+
+    id := sg.Next()
+    if int64(id) < 0 {
+        t.Fatalf("ID does not fit a signed integer: (%d)", id)
+    }
+
+    timestamp, nodeId, sequence := ParseSnowflake(id, epoch)
+    if timestamp.Equal(idTestTime) != true {
+        t.Fatalf("Timestamp not correct: [%s]", timestamp)
+    } else if nodeId != SnowflakeMaxNodeId {
+        t.Fatalf("Node ID not correct: (%d)", nodeId)
+    } else if sequence != 0 {
+        t.Fatalf("Sequence not correct: (%d)", sequence)
+    }
+
+    if _, err := NewSnowflakeGenerator(SnowflakeMaxNodeId + 1, epoch); err == nil {
+        t.Fatalf("Node ID should not be valid.")
+    }
+}
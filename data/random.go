@@ -1,12 +1,11 @@
 package ridata
 
 import (
-    "fmt"
-    "strings"
-    "time"
+    "io"
+    "sync"
 
-    "crypto/sha256"
-    "math/rand"
+    "crypto/rand"
+    "encoding/binary"
 
     "github.com/dsoprea/go-logging"
 )
@@ -17,39 +16,57 @@ const (
     MediumUuidLength = 20
 )
 
-func RandomHexString(length int) string {
-    list := make([]string, 0)
-    n := 0
+// Other
+var (
+    randomSource io.Reader = rand.Reader
+    randomSourceLocker sync.RWMutex
+)
 
-    for n < length {
-        buffer := make([]byte, 10)
-        _, err := rand.Read(buffer)
-        log.PanicIf(err)
+// replaceRandomSource Replace the source of randomness for everything in this
+// package and return the previous one. This is only a hook for the tests in
+// this package; the source is always crypto/rand otherwise.
+func replaceRandomSource(r io.Reader) (previous io.Reader) {
+    randomSourceLocker.Lock()
+    defer randomSourceLocker.Unlock()
 
-        s := fmt.Sprintf("%x", sha256.Sum256(buffer))
-        list = append(list, s)
-        n += len(s)
-    }
+    previous = randomSource
+    randomSource = r
 
-    return strings.Join(list, "")[:length]
+    return previous
+}
+
+// RandomBytes Return `n` random bytes.
+func RandomBytes(n int) []byte {
+    randomSourceLocker.RLock()
+    r := randomSource
+    randomSourceLocker.RUnlock()
+
+    buffer := make([]byte, n)
+
+    _, err := io.ReadFull(r, buffer)
+    log.PanicIf(err)
+
+    return buffer
+}
+
+// RandomHexString Return `length` random hex characters (four bits of entropy
+// each).
+func RandomHexString(length int) string {
+    return RandomStringWithLength(RandomEncodingHex, length)
 }
 
 // GetLargeRandomHexString returns a very unique, long string that is good as a
-// one-time password or sharing key.
+// one-time password or sharing key (160 bits).
 func LargeRandomHexString() string {
     return RandomHexString(LargeUuidLength)
 }
 
 // GetMediumRandomHexString returns a random-string that can usually be used as
-// a reusable identifier.
+// a reusable identifier (80 bits).
 func MediumRandomHexString() string {
     return RandomHexString(MediumUuidLength)
 }
 
 func RandomUint64() uint64 {
-    return rand.Uint64()
-}
-
-func init() {
-    rand.Seed(time.Now().UTC().UnixNano())
+    return binary.BigEndian.Uint64(RandomBytes(8))
 }
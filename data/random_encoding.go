@@ -0,0 +1,116 @@
+package ridata
+
+import (
+    "fmt"
+    "math"
+    "sort"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Random-string encodings
+const (
+    RandomEncodingHex = "hex"
+
+    // Crockford's base32: no I, L, O, or U, so it's hard to misread.
+    RandomEncodingCrockford = "crockford"
+
+    // Bitcoin's base58: no 0, O, I, or l.
+    RandomEncodingBase58 = "base58"
+
+    // URL-safe base64 without padding.
+    RandomEncodingBase64Url = "base64url"
+)
+
+// Alphabets
+const (
+    HexAlphabet = "0123456789abcdef"
+    CrockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+    Base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+    Base64UrlAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+)
+
+// Other
+var (
+    randomEncodingAlphabets = map[string]string {
+        RandomEncodingHex: HexAlphabet,
+        RandomEncodingCrockford: CrockfordAlphabet,
+        RandomEncodingBase58: Base58Alphabet,
+        RandomEncodingBase64Url: Base64UrlAlphabet,
+    }
+)
+
+// RandomString Return a random string in the encoding with at least
+// `entropyBits` bits of entropy. Every character is drawn uniformly from the
+// encoding's alphabet, so the length is ceil(bits / log2(alphabet size)).
+func RandomString(encoding string, entropyBits int) string {
+    return RandomStringWithLength(encoding, RandomStringLength(encoding, entropyBits))
+}
+
+// RandomStringLength Return the number of characters needed for the entropy.
+func RandomStringLength(encoding string, entropyBits int) int {
+    alphabet := getRandomEncodingAlphabet(encoding)
+    return int(math.Ceil(float64(entropyBits) / math.Log2(float64(len(alphabet)))))
+}
+
+// RandomStringEntropy Return the bits of entropy in a random string of the
+// given length.
+func RandomStringEntropy(encoding string, length int) float64 {
+    alphabet := getRandomEncodingAlphabet(encoding)
+    return float64(length) * math.Log2(float64(len(alphabet)))
+}
+
+// RandomStringWithLength Return `length` random characters of the encoding.
+func RandomStringWithLength(encoding string, length int) string {
+    return randomStringWithAlphabet(getRandomEncodingAlphabet(encoding), length)
+}
+
+// randomStringWithAlphabet Draw characters uniformly. Bytes that would bias
+// the result toward the start of the alphabet are discarded.
+func randomStringWithAlphabet(alphabet string, length int) string {
+    size := len(alphabet)
+    limit := 256 - (256 % size)
+
+    output := make([]byte, 0, length)
+    for len(output) < length {
+        for _, b := range RandomBytes(length - len(output) + 8) {
+            if int(b) >= limit {
+                continue
+            }
+
+            output = append(output, alphabet[int(b) % size])
+            if len(output) == length {
+                break
+            }
+        }
+    }
+
+    return string(output)
+}
+
+// RandomEncodingAlphabet Return the alphabet of the encoding.
+func RandomEncodingAlphabet(encoding string) (alphabet string, found bool) {
+    alphabet, found = randomEncodingAlphabets[encoding]
+    return alphabet, found
+}
+
+// RandomEncodings Return the names of the encodings, sorted.
+func RandomEncodings() []string {
+    encodings := make([]string, 0, len(randomEncodingAlphabets))
+    for encoding := range randomEncodingAlphabets {
+        encodings = append(encodings, encoding)
+    }
+
+    sort.Strings(encodings)
+
+    return encodings
+}
+
+func getRandomEncodingAlphabet(encoding string) string {
+    alphabet, found := randomEncodingAlphabets[encoding]
+    if found == false {
+        log.Panic(fmt.Errorf("random encoding not valid: [%s]", encoding))
+    }
+
+    return alphabet
+}
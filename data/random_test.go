@@ -0,0 +1,120 @@
+package ridata
+
+import (
+    "io"
+    "strings"
+    "sync"
+    "testing"
+
+    "math/rand"
+)
+
+// seededRandomSource A reproducible stream for tests. Not secure.
+type seededRandomSource struct {
+    r *rand.Rand
+    locker sync.Mutex
+}
+
+// newSeededRandomSource Return a deterministic source. The same seed always
+// produces the same identifiers.
+func newSeededRandomSource(seed int64) io.Reader {
+    return &seededRandomSource{
+        r: rand.New(rand.NewSource(seed)),
+    }
+}
+
+func (srs *seededRandomSource) Read(p []byte) (n int, err error) {
+    srs.locker.Lock()
+    defer srs.locker.Unlock()
+
+    return srs.r.Read(p)
+}
+
+// setRandomSource Replace the source of randomness for the test.
+func setRandomSource(t *testing.T, r io.Reader) {
+    previous := replaceRandomSource(r)
+
+    t.Cleanup(func() {
+        replaceRandomSource(previous)
+    })
+}
+
+func TestRandomSource_Reproducible(t *testing.T) {
+    generate := func(seed int64) []string {
+        setRandomSource(t, newSeededRandomSource(seed))
+
+        return []string {
+            RandomHexString(32),
+            RandomString(RandomEncodingCrockford, 128),
+            RandomString(RandomEncodingBase58, 128),
+            NewUuidV4(),
+        }
+    }
+
+    first := generate(42)
+    second := generate(42)
+    other := generate(43)
+
+    for i := range first {
+        if first[i] != second[i] {
+            t.Fatalf("Seeded output not reproducible: [%s] != [%s]", first[i], second[i])
+        } else if first[i] == other[i] {
+            t.Fatalf("Different seeds produced the same output: [%s]", first[i])
+        }
+    }
+}
+
+func TestRandomString_Alphabets(t *testing.T) {
+    setRandomSource(t, newSeededRandomSource(1))
+
+    encodings := RandomEncodings()
+    if len(encodings) != 4 {
+        t.Fatalf("Encodings not correct: %v", encodings)
+    }
+
+    for _, encoding := range encodings {
+        alphabet, found := RandomEncodingAlphabet(encoding)
+        if found != true {
+            t.Fatalf("Alphabet not found: [%s]", encoding)
+        }
+
+        // Long enough that every character is almost certainly drawn.
+        s := RandomStringWithLength(encoding, 4096)
+        if len(s) != 4096 {
+            t.Fatalf("Length not correct for [%s]: (%d)", encoding, len(s))
+        }
+
+        for _, c := range s {
+            if strings.ContainsRune(alphabet, c) != true {
+                t.Fatalf("Character [%c] not in the [%s] alphabet.", c, encoding)
+            }
+        }
+
+        for _, c := range alphabet {
+            if strings.ContainsRune(s, c) != true {
+                t.Fatalf("Character [%c] of the [%s] alphabet never drawn.", c, encoding)
+            }
+        }
+    }
+
+    if _, found := RandomEncodingAlphabet("base2"); found != false {
+        t.Fatalf("Unknown encoding should not be found.")
+    }
+}
+
+func TestRandomStringLength(t *testing.T) {
+    lengths := map[string]int {
+        RandomEncodingHex: 32,
+        RandomEncodingCrockford: 26,
+        RandomEncodingBase58: 22,
+        RandomEncodingBase64Url: 22,
+    }
+
+    for encoding, expected := range lengths {
+        if length := RandomStringLength(encoding, 128); length != expected {
+            t.Fatalf("Length for [%s] not correct: (%d)", encoding, length)
+        } else if RandomStringEntropy(encoding, length) < 128 {
+            t.Fatalf("Entropy for [%s] not correct.", encoding)
+        }
+    }
+}
@@ -0,0 +1,334 @@
+package ridata
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strings"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// Human-friendly token constants
+const (
+    // Separates groups of characters or words.
+    TokenGroupSeparator = "-"
+
+    // The check characters can also be these, so there are 37 symbols (a
+    // prime).
+    CrockfordCheckAlphabet = CrockfordAlphabet + "*~$=U"
+
+    // Two check characters can locate errors in up to this many characters.
+    crockfordTokenMaxLength = len(CrockfordCheckAlphabet) - 1
+)
+
+// Errors
+var (
+    ErrTokenNotValid = errors.New("token not valid")
+)
+
+// Other
+var (
+    // Short, distinct words: no two are a single insertion, deletion, or
+    // substitution apart, so a single mistyped letter can be corrected. Eight
+    // bits each.
+    DefaultTokenWords = []string {
+    "able", "acid", "aged", "also", "area", "army", "away", "baby", "back",
+    "ball", "band", "base", "bath", "bear", "belt", "bird", "blow", "blue",
+    "boat", "body", "bone", "book", "born", "boss", "bowl", "bulk", "bush",
+    "cake", "calm", "camp", "card", "cash", "cell", "chef", "chip", "city",
+    "clay", "club", "coal", "code", "cold", "comb", "copy", "cost", "crew",
+    "crop", "cube", "curl", "dark", "data", "dawn", "deal", "debt", "deck",
+    "deep", "diet", "dish", "door", "dose", "draw", "drum", "dust", "duty",
+    "each", "earn", "east", "echo", "edge", "else", "envy", "epic", "even",
+    "exam", "exit", "face", "fair", "farm", "feed", "fern", "film", "find",
+    "fire", "flag", "foam", "folk", "food", "fort", "four", "frog", "fuel",
+    "gain", "game", "gift", "girl", "glad", "golf", "gown", "grab", "grid",
+    "grow", "half", "harp", "hawk", "head", "herb", "hill", "hint", "hive",
+    "home", "huge", "idea", "inch", "iron", "item", "jazz", "join", "joke",
+    "jump", "jury", "keen", "kept", "kick", "king", "kite", "knee", "knot",
+    "lamb", "lane", "lava", "leaf", "left", "lens", "life", "link", "lion",
+    "list", "lock", "logo", "long", "loop", "lord", "made", "mail", "mango",
+    "maple", "march", "mask", "meat", "menu", "mild", "moon", "moth", "much",
+    "mule", "navy", "nest", "news", "nice", "note", "oboe", "ocean", "olive",
+    "onion", "opal", "open", "page", "paint", "panda", "pearl", "pepper",
+    "piano", "pilot", "pine", "pizza", "plan", "plum", "poem", "polar", "pond",
+    "pool", "puma", "pupil", "quiz", "rabbit", "radio", "raven", "reef",
+    "ridge", "river", "road", "robin", "roof", "rope", "ruby", "rug", "safe",
+    "salad", "salt", "scarf", "shelf", "shoe", "silk", "silver", "sister",
+    "skate", "sky", "sled", "smile", "snake", "snow", "soap", "sofa", "spark",
+    "spice", "spoon", "squid", "star", "steam", "stone", "storm", "sugar",
+    "sun", "swan", "taco", "tank", "taxi", "tea", "tent", "tiger", "toast",
+    "today", "token", "tomato", "tooth", "torch", "tower", "toy", "track",
+    "train", "tree", "trout", "tulip", "tuna", "turtle", "twig", "uncle",
+    "unit", "urban", "velvet", "video", "violet", "visa", "vivid", "voice",
+    "volcano", "wagon",
+    }
+)
+
+// NewCrockfordToken Return a token with at least `entropyBits` bits of
+// entropy in Crockford base32 followed by two check characters, split into
+// groups of `groupSize` characters (zero for none). Up to 170 bits are
+// supported.
+//
+// Crockford's single mod-37 check character detects a wrong character but
+// can't say which one it is. The second one locates it so that it can be
+// corrected (see ValidateCrockfordToken).
+func NewCrockfordToken(entropyBits int, groupSize int) string {
+    payload := RandomString(RandomEncodingCrockford, entropyBits)
+    if len(payload) + 2 > crockfordTokenMaxLength {
+        log.Panic(fmt.Errorf("too many bits for a token: (%d)", entropyBits))
+    }
+
+    values := make([]int, len(payload) + 2)
+    for i := 0; i < len(payload); i++ {
+        values[i] = int(crockfordDecoding[payload[i]])
+    }
+
+    setCrockfordTokenCheck(values)
+
+    return formatCrockfordToken(values, groupSize)
+}
+
+// setCrockfordTokenCheck Fill in the last two (check) values. They make both
+// sum(v) and sum((i + 1) * v) zero (mod 37). A single error then leaves
+// sum(v) as the size of the error and sum((i + 1) * v) as that times its
+// position.
+func setCrockfordTokenCheck(values []int) {
+    n := len(values)
+
+    values[n - 2] = 0
+    values[n - 1] = 0
+
+    s1, s2 := crockfordTokenSyndromes(values)
+
+    b := mod37(-s2 + (n - 1) * s1)
+    a := mod37(-s1 - b)
+
+    values[n - 2] = a
+    values[n - 1] = b
+}
+
+// ValidateCrockfordToken Normalize the token (case, separators, and
+// easily-confused letters) and check it. A single wrong character is
+// corrected, in which case `corrected` is true. The token is returned grouped
+// by `groupSize`. Returns ErrTokenNotValid if the token can't be corrected.
+func ValidateCrockfordToken(token string, groupSize int) (normalized string, corrected bool, err error) {
+    token = strings.Replace(token, TokenGroupSeparator, "", -1)
+    token = strings.Replace(token, " ", "", -1)
+
+    if len(token) < 3 || len(token) > crockfordTokenMaxLength {
+        return "", false, ErrTokenNotValid
+    }
+
+    values := make([]int, len(token))
+    for i := 0; i < len(token); i++ {
+        c := token[i]
+
+        isCheck := i >= len(token) - 2
+        if isCheck == true {
+            if j := strings.IndexByte(CrockfordCheckAlphabet, c); j >= 32 {
+                values[i] = j
+                continue
+            } else if c == 'u' {
+                values[i] = strings.IndexByte(CrockfordCheckAlphabet, 'U')
+                continue
+            }
+        }
+
+        value := crockfordDecoding[c]
+        if value < 0 {
+            return "", false, ErrTokenNotValid
+        }
+
+        values[i] = int(value)
+    }
+
+    s1, s2 := crockfordTokenSyndromes(values)
+    if s1 == 0 && s2 == 0 {
+        return formatCrockfordToken(values, groupSize), false, nil
+    } else if s1 == 0 || s2 == 0 {
+        // More than one error (e.g. a transposition).
+        return "", false, ErrTokenNotValid
+    }
+
+    // Position (one-based) is s2 / s1.
+    position := mod37(s2 * inverse37(s1)) - 1
+    if position < 0 || position >= len(values) {
+        return "", false, ErrTokenNotValid
+    }
+
+    fixed := mod37(values[position] - s1)
+    if position < len(values) - 2 && fixed >= len(CrockfordAlphabet) {
+        return "", false, ErrTokenNotValid
+    }
+
+    values[position] = fixed
+    return formatCrockfordToken(values, groupSize), true, nil
+}
+
+func crockfordTokenSyndromes(values []int) (s1, s2 int) {
+    for i, value := range values {
+        s1 += value
+        s2 += (i + 1) * value
+    }
+
+    return mod37(s1), mod37(s2)
+}
+
+func formatCrockfordToken(values []int, groupSize int) string {
+    parts := make([]string, 0)
+    current := make([]byte, 0, len(values))
+
+    for _, value := range values {
+        current = append(current, CrockfordCheckAlphabet[value])
+
+        if groupSize > 0 && len(current) == groupSize {
+            parts = append(parts, string(current))
+            current = current[:0]
+        }
+    }
+
+    if len(current) > 0 {
+        parts = append(parts, string(current))
+    }
+
+    return strings.Join(parts, TokenGroupSeparator)
+}
+
+func mod37(n int) int {
+    n %= 37
+    if n < 0 {
+        n += 37
+    }
+
+    return n
+}
+
+func inverse37(n int) int {
+    // Fermat: n^35 is the inverse of n modulo the prime 37.
+    result := 1
+    for i := 0; i < 35; i++ {
+        result = mod37(result * n)
+    }
+
+    return result
+}
+
+// NewWordToken Return a token of random words (DefaultTokenWords if `words`
+// is nil) with at least `entropyBits` bits of entropy. There must be at least
+// two words.
+func NewWordToken(words []string, entropyBits int) string {
+    if words == nil {
+        words = DefaultTokenWords
+    }
+
+    // One word carries no entropy.
+    if len(words) < 2 {
+        log.Panic(fmt.Errorf("too few words for a token: (%d)", len(words)))
+    }
+
+    count := int(math.Ceil(float64(entropyBits) / math.Log2(float64(len(words)))))
+    chosen := make([]string, count)
+
+    for i := range chosen {
+        chosen[i] = words[randomIndex(len(words))]
+    }
+
+    return strings.Join(chosen, TokenGroupSeparator)
+}
+
+// ValidateWordToken Normalize the token (case and separators) and check that
+// every word is in the list (DefaultTokenWords if `words` is nil). A word with
+// a single wrong, missing, or extra letter is corrected when exactly one word
+// in the list matches, in which case `corrected` is true.
+func ValidateWordToken(token string, words []string) (normalized string, corrected bool, err error) {
+    if words == nil {
+        words = DefaultTokenWords
+    }
+
+    fields := strings.FieldsFunc(strings.ToLower(token), func(r rune) bool {
+        return r == ' ' || r == '-' || r == '.' || r == '_'
+    })
+
+    if len(fields) == 0 {
+        return "", false, ErrTokenNotValid
+    }
+
+    known := make(map[string]bool, len(words))
+    for _, word := range words {
+        known[word] = true
+    }
+
+    for i, field := range fields {
+        if known[field] == true {
+            continue
+        }
+
+        match := ""
+        for _, word := range words {
+            if isSingleEdit(field, word) == false {
+                continue
+            } else if match != "" {
+                // Ambiguous.
+                return "", false, ErrTokenNotValid
+            }
+
+            match = word
+        }
+
+        if match == "" {
+            return "", false, ErrTokenNotValid
+        }
+
+        fields[i] = match
+        corrected = true
+    }
+
+    return strings.Join(fields, TokenGroupSeparator), corrected, nil
+}
+
+// isSingleEdit Return whether the strings differ by exactly one substitution,
+// insertion, or deletion.
+func isSingleEdit(a, b string) bool {
+    if len(a) == len(b) {
+        differences := 0
+        for i := 0; i < len(a); i++ {
+            if a[i] != b[i] {
+                differences++
+            }
+        }
+
+        return differences == 1
+    }
+
+    if len(a) > len(b) {
+        a, b = b, a
+    }
+
+    if len(b) - len(a) != 1 {
+        return false
+    }
+
+    for i := 0; i < len(b); i++ {
+        if b[:i] + b[i + 1:] == a {
+            return true
+        }
+    }
+
+    return false
+}
+
+// randomIndex Return a uniformly-random index below `n`.
+func randomIndex(n int) int {
+    limit := math.MaxUint32 - (math.MaxUint32 % uint32(n))
+
+    for {
+        raw := RandomBytes(4)
+        value := uint32(raw[0]) << 24 | uint32(raw[1]) << 16 | uint32(raw[2]) << 8 | uint32(raw[3])
+
+        if value < limit {
+            return int(value % uint32(n))
+        }
+    }
+}
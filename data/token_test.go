@@ -0,0 +1,200 @@
+package ridata
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestSetCrockfordTokenCheck(t *testing.T) {
+    tokens := map[string]string {
+        "0000000001": "0000000001=1",
+        "ABCD": "ABCDXU",
+        "7ZZZ": "7ZZZKX",
+        "T0KEN": "T0KENHE",
+    }
+
+    for payload, expected := range tokens {
+        values := make([]int, len(payload) + 2)
+        for i := 0; i < len(payload); i++ {
+            values[i] = int(crockfordDecoding[payload[i]])
+        }
+
+        setCrockfordTokenCheck(values)
+
+        if token := formatCrockfordToken(values, 0); token != expected {
+            t.Fatalf("Check characters of [%s] not correct: [%s] != [%s]", payload, token, expected)
+        }
+    }
+}
+
+func TestValidateCrockfordToken(t *testing.T) {
+    cases := []struct {
+        token string
+        normalized string
+        corrected bool
+    } {
+        // Valid.
+        {"0000000001=1", "0000000001=1", false},
+        {"abcd-xu", "ABCDXU", false},
+        {"abcdxu", "ABCDXU", false},
+
+        // Confusable letters.
+        {"T0KENHE", "T0KENHE", false},
+        {"tokenhe", "T0KENHE", false},
+
+        // One substitution, in the payload or a check character.
+        {"0000000002=1", "0000000001=1", true},
+        {"ABCEXU", "ABCDXU", true},
+        {"ABCDX*", "ABCDXU", true},
+        {"7ZZZK0", "7ZZZKX", true},
+    }
+
+    for _, c := range cases {
+        normalized, corrected, err := ValidateCrockfordToken(c.token, 0)
+        if err != nil {
+            t.Fatalf("Token [%s] not valid: %s", c.token, err)
+        } else if normalized != c.normalized || corrected != c.corrected {
+            t.Fatalf("Token [%s] not correct: [%s] (%v)", c.token, normalized, corrected)
+        }
+    }
+}
+
+func TestValidateCrockfordToken_NotValid(t *testing.T) {
+    tokens := []string {
+        // Two substitutions.
+        "1100000001=1",
+        "ABCDYV",
+
+        // Transposition.
+        "BACDXU",
+
+        // A check symbol in the payload.
+        "AB*DXU",
+
+        "",
+        "AB",
+        "ABCD!U",
+        strings.Repeat("0", crockfordTokenMaxLength + 1),
+    }
+
+    for _, token := range tokens {
+        if _, _, err := ValidateCrockfordToken(token, 0); err != ErrTokenNotValid {
+            t.Fatalf("Token should not be valid: [%s]", token)
+        }
+    }
+}
+
+func TestNewCrockfordToken_MaximumLength(t *testing.T) {
+    setRandomSource(t, newSeededRandomSource(1))
+
+    token := NewCrockfordToken(170, 4)
+    if length := len(strings.Replace(token, TokenGroupSeparator, "", -1)); length != crockfordTokenMaxLength {
+        t.Fatalf("Token length not correct: (%d)", length)
+    }
+
+    normalized, corrected, err := ValidateCrockfordToken(strings.ToLower(token), 4)
+    if err != nil {
+        t.Fatalf("Token not valid: [%s]: %s", token, err)
+    } else if normalized != token || corrected != false {
+        t.Fatalf("Token did not round-trip: [%s] != [%s]", normalized, token)
+    }
+
+    // Every position can be corrected.
+    raw := []byte(strings.Replace(token, TokenGroupSeparator, "", -1))
+    for i := range raw {
+        damaged := make([]byte, len(raw))
+        copy(damaged, raw)
+
+        if damaged[i] == '0' {
+            damaged[i] = '1'
+        } else {
+            damaged[i] = '0'
+        }
+
+        normalized, corrected, err := ValidateCrockfordToken(string(damaged), 4)
+        if err != nil {
+            t.Fatalf("Token [%s] not corrected: %s", damaged, err)
+        } else if normalized != token || corrected != true {
+            t.Fatalf("Token [%s] not corrected: [%s]", damaged, normalized)
+        }
+    }
+
+    func() {
+        defer func() {
+            if state := recover(); state == nil {
+                t.Fatalf("Too many bits should have panicked.")
+            }
+        }()
+
+        NewCrockfordToken(171, 4)
+    }()
+}
+
+func TestValidateWordToken(t *testing.T) {
+    cases := []struct {
+        token string
+        normalized string
+        corrected bool
+    } {
+        {"tiger-toast-onion", "tiger-toast-onion", false},
+        {"Tiger Toast.ONION", "tiger-toast-onion", false},
+
+        // Substitution, deletion, and insertion.
+        {"tiger-toast-xnion", "tiger-toast-onion", true},
+        {"tigr-toast-onion", "tiger-toast-onion", true},
+        {"tigers-toast-onion", "tiger-toast-onion", true},
+    }
+
+    for _, c := range cases {
+        normalized, corrected, err := ValidateWordToken(c.token, nil)
+        if err != nil {
+            t.Fatalf("Token [%s] not valid: %s", c.token, err)
+        } else if normalized != c.normalized || corrected != c.corrected {
+            t.Fatalf("Token [%s] not correct: [%s] (%v)", c.token, normalized, corrected)
+        }
+    }
+
+    tokens := []string {
+        // Two errors in one word.
+        "tiger-toast-oxxon",
+        "tiger-taost-onion",
+
+        // Ambiguous ("pool" and "pond" are both one edit from "pood").
+        "pood",
+
+        "",
+    }
+
+    for _, token := range tokens {
+        if _, _, err := ValidateWordToken(token, nil); err != ErrTokenNotValid {
+            t.Fatalf("Token should not be valid: [%s]", token)
+        }
+    }
+}
+
+func TestNewWordToken(t *testing.T) {
+    setRandomSource(t, newSeededRandomSource(1))
+
+    token := NewWordToken(nil, 64)
+    if words := strings.Split(token, TokenGroupSeparator); len(words) != 8 {
+        t.Fatalf("Word count not correct: [%s]", token)
+    }
+
+    if normalized, corrected, err := ValidateWordToken(token, nil); err != nil || normalized != token || corrected != false {
+        t.Fatalf("Token did not round-trip: [%s]", token)
+    }
+}
+
+func TestNewWordToken_TooFewWords(t *testing.T) {
+    for _, words := range [][]string { []string {}, []string { "apple" } } {
+        func() {
+            defer func() {
+                if state := recover(); state == nil {
+                    t.Fatalf("Too few words should have panicked: %v", words)
+                }
+            }()
+
+            NewWordToken(words, 64)
+        }()
+    }
+}
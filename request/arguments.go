@@ -8,11 +8,31 @@ import (
     "github.com/gorilla/mux"
     "github.com/dsoprea/go-logging"
     "github.com/dsoprea/go-multiparse"
+
+    "github.com/randomingenuity/go-ri/common"
 )
 
 // Other
 var (
     argumentLogger = log.NewLogger("ri.request.arguments")
+
+    // The kinds that GetRequestPathParameter can parse (reflect.Kind names).
+    pathParameterKinds = map[string]bool {
+        "bool": true,
+        "int": true,
+        "int8": true,
+        "int16": true,
+        "int32": true,
+        "int64": true,
+        "uint": true,
+        "uint8": true,
+        "uint16": true,
+        "uint32": true,
+        "uint64": true,
+        "float32": true,
+        "float64": true,
+        "string": true,
+    }
 )
 
 func GetStringRequestPathParameter(r *http.Request, name string) string {
@@ -20,13 +40,29 @@ func GetStringRequestPathParameter(r *http.Request, name string) string {
     value := vars[name]
 
     if value == "" {
-        log.Panic(fmt.Errorf("[%s] path parameter empty in request", name))
+        log.Panic(fmt.Errorf("%w: [%s] path parameter empty in request", ricommon.ErrArgumentError, name))
     }
 
     return value
 }
 
+// GetRequestPathParameter Parse the path parameter as the given kind. Values
+// that don't parse are argument errors, but a kind that isn't supported is a
+// mistake in the handler and isn't.
 func GetRequestPathParameter(r *http.Request, name, kindName string) interface{} {
+    if pathParameterKinds[kindName] == false {
+        log.Panic(fmt.Errorf("path parameter kind not valid: [%s]", kindName))
+    }
+
     valueRaw := GetStringRequestPathParameter(r, name)
+
+    // A value that doesn't parse is the client's mistake.
+    defer func() {
+        if state := recover(); state != nil {
+            err := ricommon.DistillError(state)
+            log.Panic(fmt.Errorf("%w: [%s] path parameter not valid: %s", ricommon.ErrArgumentError, name, err))
+        }
+    }()
+
     return parse.Parse(valueRaw, kindName)
 }
@@ -0,0 +1,433 @@
+package rirequest
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "encoding/json"
+    "net/http"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Request-binding struct tags. The value is the parameter name optionally
+// followed by options: `query:"limit,default=20"`, `header:"X-Tenant,required"`,
+// `query:"tag,csv"` (also split values on commas). "default=" must be the last
+// option since the rest of the tag is its value, so the default for a slice
+// can be a list (`query:"tags,default=a,b"`). A field tagged `body:"json"`
+// receives the decoded request body.
+const (
+    BindTagPath = ParameterSourcePath
+    BindTagQuery = ParameterSourceQuery
+    BindTagHeader = ParameterSourceHeader
+    BindTagForm = ParameterSourceForm
+    BindTagBody = "body"
+)
+
+// Constants
+const (
+    // The largest request body that will be decoded.
+    BindMaxBodySize = 1024 * 1024
+)
+
+// Other
+var (
+    bindSources = []string {
+        BindTagPath,
+        BindTagQuery,
+        BindTagHeader,
+        BindTagForm,
+    }
+
+    bindDurationType = reflect.TypeOf(time.Duration(0))
+    bindTimeType = reflect.TypeOf(time.Time{})
+)
+
+// boundField Where a field's value came from, for reporting validation
+// problems.
+type boundField struct {
+    source string
+    name string
+}
+
+// BindRequest Fill the struct pointed to by `output` from the request using
+// its field tags. Untagged struct fields are descended into. Path parameters
+// are always required. Fields are then checked against their "validate" and
+// "pattern" rules (see ricommon.ValidateConfig). All problems are returned
+// together as a *ParameterError.
+func BindRequest(r *http.Request, output interface{}) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = ricommon.DistillError(state)
+        }
+    }()
+
+    v := reflect.ValueOf(output)
+    if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+        log.Panic(fmt.Errorf("request output must be a pointer to a struct: [%s]", v.Type()))
+    }
+
+    pe := NewParameterExtractor(r)
+    bindRequestStruct(r, pe, v.Elem())
+
+    return pe.Err()
+}
+
+func bindRequestStruct(r *http.Request, pe *ParameterExtractor, v reflect.Value) {
+    t := v.Type()
+    bound := make(map[int]boundField)
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        fv := v.Field(i)
+
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        if sf.Tag.Get(BindTagBody) != "" {
+            if bindRequestBody(r, pe, sf, fv) == true {
+                bound[i] = boundField{ source: ParameterSourceBody, name: sf.Name }
+            }
+
+            continue
+        }
+
+        source, tag := "", ""
+        for _, candidate := range bindSources {
+            if tag = sf.Tag.Get(candidate); tag != "" {
+                source = candidate
+                break
+            }
+        }
+
+        if source == "" {
+            if nested, ok := nestedRequestStruct(fv); ok == true {
+                bindRequestStruct(r, pe, nested)
+            }
+
+            continue
+        }
+
+        defaultValue := ""
+        hasDefault := false
+
+        if i := strings.Index(tag, ",default="); i != -1 {
+            defaultValue = tag[i + len(",default="):]
+            hasDefault = true
+
+            tag = tag[:i]
+        }
+
+        parts := strings.Split(tag, ",")
+        name := parts[0]
+
+        required := source == ParameterSourcePath
+        split := false
+
+        for _, option := range parts[1:] {
+            switch option {
+            case "required":
+                required = true
+            case "csv":
+                split = true
+            default:
+                log.Panic(fmt.Errorf("request binding option not valid: [%s] [%s]", sf.Name, option))
+            }
+        }
+
+        bound[i] = boundField{ source: source, name: name }
+
+        values := pe.Values(source, name)
+        if len(values) == 0 || len(values) == 1 && values[0] == "" {
+            if required == true {
+                pe.AddProblem(source, name, "", "missing")
+                continue
+            } else if hasDefault == false {
+                continue
+            }
+
+            values = []string { defaultValue }
+            split = fv.Kind() == reflect.Slice
+        }
+
+        if split == true {
+            splitValues := make([]string, 0, len(values))
+            for _, value := range values {
+                for _, part := range strings.Split(value, ",") {
+                    splitValues = append(splitValues, strings.TrimSpace(part))
+                }
+            }
+
+            values = splitValues
+        }
+
+        if message := setRequestValue(fv, values); message != "" {
+            pe.AddProblem(source, name, strings.Join(values, ","), message)
+        }
+    }
+
+    // Validate after everything is bound since rules can refer to other
+    // fields.
+
+    for i := 0; i < t.NumField(); i++ {
+        bf, found := bound[i]
+        if found == false {
+            continue
+        }
+
+        messages, err := ricommon.CheckValidationRules(v, i)
+        log.PanicIf(err)
+
+        for _, message := range messages {
+            pe.AddProblem(bf.source, bf.name, "", message)
+        }
+    }
+}
+
+// bindRequestBody Decode the JSON body into the field and validate it. Returns
+// false if there was no body. Problems are named by the dotted path of JSON
+// keys (e.g. "items.0.user_id").
+func bindRequestBody(r *http.Request, pe *ParameterExtractor, sf reflect.StructField, fv reflect.Value) bool {
+    if format := sf.Tag.Get(BindTagBody); format != "json" {
+        log.Panic(fmt.Errorf("request body format not supported: [%s] [%s]", sf.Name, format))
+    }
+
+    if r.Body == nil {
+        return false
+    }
+
+    d := json.NewDecoder(http.MaxBytesReader(nil, r.Body, BindMaxBodySize))
+
+    err := d.Decode(fv.Addr().Interface())
+    if err == io.EOF {
+        return false
+    } else if err == nil {
+        // Only one value is allowed.
+        if _, err = d.Token(); err == io.EOF {
+            err = nil
+        } else if err == nil {
+            err = fmt.Errorf("unexpected data after the value")
+        }
+    }
+
+    var mbe *http.MaxBytesError
+    if errors.As(err, &mbe) == true {
+        pe.AddProblem(ParameterSourceBody, sf.Name, "", fmt.Sprintf("must not exceed (%d) bytes", BindMaxBodySize))
+        return false
+    } else if err != nil {
+        pe.AddProblem(ParameterSourceBody, sf.Name, "", fmt.Sprintf("JSON not valid: %s", err))
+        return false
+    }
+
+    collectBodyProblems(pe, fv, "")
+    return true
+}
+
+// collectBodyProblems Apply the validation rules (see
+// ricommon.CheckValidationRules) to every field of the decoded body,
+// descending into nested structs and lists.
+func collectBodyProblems(pe *ParameterExtractor, v reflect.Value, keyPath string) {
+    for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+        if v.IsNil() == true {
+            return
+        }
+
+        v = v.Elem()
+    }
+
+    switch v.Kind() {
+    case reflect.Struct:
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < v.Len(); i++ {
+            collectBodyProblems(pe, v.Index(i), joinBodyKeyPath(keyPath, strconv.Itoa(i)))
+        }
+
+        return
+    default:
+        return
+    }
+
+    t := v.Type()
+
+    for i := 0; i < t.NumField(); i++ {
+        sf := t.Field(i)
+        if sf.PkgPath != "" {
+            continue
+        }
+
+        name, isInline := jsonFieldName(sf)
+        if name == "-" {
+            continue
+        }
+
+        fv := v.Field(i)
+
+        if isInline == true {
+            collectBodyProblems(pe, fv, keyPath)
+            continue
+        }
+
+        fieldKeyPath := joinBodyKeyPath(keyPath, name)
+
+        messages, err := ricommon.CheckValidationRules(v, i)
+        log.PanicIf(err)
+
+        for _, message := range messages {
+            pe.AddProblem(ParameterSourceBody, fieldKeyPath, "", message)
+        }
+
+        collectBodyProblems(pe, fv, fieldKeyPath)
+    }
+}
+
+// jsonFieldName Return the key that encoding/json uses for the field.
+// Untagged embedded structs are inlined.
+func jsonFieldName(sf reflect.StructField) (name string, isInline bool) {
+    tag := sf.Tag.Get("json")
+    name = strings.Split(tag, ",")[0]
+
+    if tag == "-" {
+        return "-", false
+    } else if name != "" {
+        return name, false
+    }
+
+    ft := sf.Type
+    for ft.Kind() == reflect.Ptr {
+        ft = ft.Elem()
+    }
+
+    if sf.Anonymous == true && ft.Kind() == reflect.Struct {
+        return "", true
+    }
+
+    return sf.Name, false
+}
+
+func joinBodyKeyPath(prefix, key string) string {
+    if prefix == "" {
+        return key
+    }
+
+    return prefix + "." + key
+}
+
+func nestedRequestStruct(fv reflect.Value) (nested reflect.Value, ok bool) {
+    if fv.Kind() == reflect.Struct && fv.Type() != bindTimeType {
+        return fv, true
+    }
+
+    if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && fv.Type().Elem() != bindTimeType {
+        if fv.IsNil() == true {
+            fv.Set(reflect.New(fv.Type().Elem()))
+        }
+
+        return fv.Elem(), true
+    }
+
+    return reflect.Value{}, false
+}
+
+// setRequestValue Parse the values into the field. Returns a message if they
+// aren't valid.
+func setRequestValue(fv reflect.Value, values []string) (message string) {
+    if fv.Kind() == reflect.Slice {
+        slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+
+        for i, value := range values {
+            if message := setRequestScalar(slice.Index(i), value); message != "" {
+                return message
+            }
+        }
+
+        fv.Set(slice)
+        return ""
+    }
+
+    if len(values) > 1 {
+        return "must only be given once"
+    }
+
+    if fv.Kind() == reflect.Ptr {
+        target := reflect.New(fv.Type().Elem())
+        if message := setRequestScalar(target.Elem(), values[0]); message != "" {
+            return message
+        }
+
+        fv.Set(target)
+        return ""
+    }
+
+    return setRequestScalar(fv, values[0])
+}
+
+func setRequestScalar(fv reflect.Value, raw string) (message string) {
+    switch fv.Type() {
+    case bindDurationType:
+        duration, err := ricommon.ParseConfigDuration(raw)
+        if err != nil {
+            return "duration not valid"
+        }
+
+        fv.SetInt(int64(duration))
+        return ""
+    case bindTimeType:
+        if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+            fv.Set(reflect.ValueOf(time.Unix(seconds, 0).UTC()))
+            return ""
+        }
+
+        timestamp, err := time.Parse(time.RFC3339Nano, raw)
+        if err != nil {
+            return "timestamp not valid (expected RFC 3339 or Unix seconds)"
+        }
+
+        fv.Set(reflect.ValueOf(timestamp))
+        return ""
+    }
+
+    switch fv.Kind() {
+    case reflect.String:
+        fv.SetString(raw)
+    case reflect.Bool:
+        value, err := strconv.ParseBool(raw)
+        if err != nil {
+            return "boolean not valid"
+        }
+
+        fv.SetBool(value)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        value, err := strconv.ParseInt(raw, 10, fv.Type().Bits())
+        if err != nil {
+            return "integer not valid"
+        }
+
+        fv.SetInt(value)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        value, err := strconv.ParseUint(raw, 10, fv.Type().Bits())
+        if err != nil {
+            return "unsigned integer not valid"
+        }
+
+        fv.SetUint(value)
+    case reflect.Float32, reflect.Float64:
+        value, err := strconv.ParseFloat(raw, fv.Type().Bits())
+        if err != nil {
+            return "number not valid"
+        }
+
+        fv.SetFloat(value)
+    default:
+        log.Panic(fmt.Errorf("request field type not supported: [%s]", fv.Type()))
+    }
+
+    return ""
+}
@@ -0,0 +1,189 @@
+package rirequest
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/gorilla/mux"
+)
+
+type bindingTestPage struct {
+    Limit int `query:"limit,default=20" validate:"max=100"`
+    Cursor string `query:"cursor"`
+}
+
+type bindingTestRequest struct {
+    Id int64 `path:"id"`
+    Tenant string `header:"X-Tenant,required"`
+    Tags []string `query:"tag,csv"`
+    Kinds []string `query:"kind,default=a,b"`
+    Ids []int `query:"id"`
+    Timeout time.Duration `query:"timeout,default=30"`
+    Since *time.Time `query:"since"`
+
+    Page bindingTestPage
+}
+
+type bindingTestItem struct {
+    UserId int `json:"user_id" validate:"min=1"`
+}
+
+type bindingTestBody struct {
+    Name string `json:"name" validate:"required"`
+    Items []bindingTestItem `json:"items"`
+}
+
+type bindingTestBodyRequest struct {
+    Body bindingTestBody `body:"json"`
+}
+
+func newBindingTestRequest(method, url, body string, vars map[string]string) *http.Request {
+    r := httptest.NewRequest(method, url, strings.NewReader(body))
+    return mux.SetURLVars(r, vars)
+}
+
+// getBindingTestProblems Return the problems as "source [name]: message".
+func getBindingTestProblems(t *testing.T, err error) []string {
+    pe, ok := err.(*ParameterError)
+    if ok == false {
+        t.Fatalf("Expected a parameter error, not: [%v]", err)
+    }
+
+    problems := make([]string, len(pe.Problems))
+    for i, problem := range pe.Problems {
+        problems[i] = problem.Source + " [" + problem.Name + "]: " + problem.Message
+    }
+
+    return problems
+}
+
+func TestBindRequest(t *testing.T) {
+    r := newBindingTestRequest("GET", "/items/5?tag=x,+y&tag=z&id=1&id=2&since=2021-06-01T12:00:00Z&limit=50", "", map[string]string { "id": "5" })
+    r.Header.Set("X-Tenant", "acme")
+
+    br := new(bindingTestRequest)
+    if err := BindRequest(r, br); err != nil {
+        t.Fatalf("Request not bound: %s", err)
+    }
+
+    since := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+    if br.Id != 5 || br.Tenant != "acme" || br.Page.Limit != 50 || br.Page.Cursor != "" {
+        t.Fatalf("Scalars not correct: %v", br)
+    } else if reflect.DeepEqual(br.Tags, []string { "x", "y", "z" }) != true {
+        t.Fatalf("Split values not correct: %v", br.Tags)
+    } else if reflect.DeepEqual(br.Ids, []int { 1, 2 }) != true {
+        t.Fatalf("Repeated values not correct: %v", br.Ids)
+    } else if br.Since == nil || br.Since.Equal(since) != true {
+        t.Fatalf("Timestamp not correct: %v", br.Since)
+    }
+}
+
+func TestBindRequest_Defaults(t *testing.T) {
+    r := newBindingTestRequest("GET", "/items/5", "", map[string]string { "id": "5" })
+    r.Header.Set("X-Tenant", "acme")
+
+    br := new(bindingTestRequest)
+    if err := BindRequest(r, br); err != nil {
+        t.Fatalf("Request not bound: %s", err)
+    }
+
+    // The default for a slice is a list.
+    if reflect.DeepEqual(br.Kinds, []string { "a", "b" }) != true {
+        t.Fatalf("Slice default not correct: %v", br.Kinds)
+    } else if br.Timeout != time.Second * 30 {
+        t.Fatalf("Duration default not correct: [%s]", br.Timeout)
+    } else if br.Page.Limit != 20 {
+        t.Fatalf("Default not correct: (%d)", br.Page.Limit)
+    } else if br.Tags != nil || br.Since != nil {
+        t.Fatalf("Missing values should not be set: %v", br)
+    }
+}
+
+func TestBindRequest_Problems(t *testing.T) {
+    r := newBindingTestRequest("GET", "/items/x?limit=500&since=yesterday", "", map[string]string { "id": "x" })
+
+    err := BindRequest(r, new(bindingTestRequest))
+
+    problems := getBindingTestProblems(t, err)
+
+    expected := []string {
+        "path [id]: integer not valid",
+        "header [X-Tenant]: missing",
+        "query [since]: timestamp not valid (expected RFC 3339 or Unix seconds)",
+        "query [limit]: must be at most 100",
+    }
+
+    if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Problems not correct:\n%s", strings.Join(problems, "\n"))
+    }
+}
+
+func TestBindRequest_OptionNotValid(t *testing.T) {
+    type request struct {
+        Limit int `query:"limit,optional"`
+    }
+
+    r := newBindingTestRequest("GET", "/", "", nil)
+
+    if err := BindRequest(r, new(request)); err == nil {
+        t.Fatalf("Expected error for an unknown option.")
+    } else if _, ok := err.(*ParameterError); ok == true {
+        t.Fatalf("An unknown option is not a parameter problem: [%s]", err)
+    }
+}
+
+func TestBindRequest_Body(t *testing.T) {
+    r := newBindingTestRequest("POST", "/", `{"name": "a", "items": [{"user_id": 1}]}`, nil)
+
+    bbr := new(bindingTestBodyRequest)
+    if err := BindRequest(r, bbr); err != nil {
+        t.Fatalf("Request not bound: %s", err)
+    } else if bbr.Body.Name != "a" || len(bbr.Body.Items) != 1 || bbr.Body.Items[0].UserId != 1 {
+        t.Fatalf("Body not correct: %v", bbr.Body)
+    }
+
+    r = newBindingTestRequest("POST", "/", `{"items": [{"user_id": 1}, {"user_id": 0}]}`, nil)
+
+    problems := getBindingTestProblems(t, BindRequest(r, new(bindingTestBodyRequest)))
+
+    // Named by the JSON keys.
+    expected := []string {
+        "body [name]: is required",
+        "body [items.1.user_id]: must be at least 1",
+    }
+
+    if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Problems not correct:\n%s", strings.Join(problems, "\n"))
+    }
+}
+
+func TestBindRequest_BodyNotValid(t *testing.T) {
+    bodies := []string {
+        `{"name": `,
+        `{"name": "a"} {"name": "b"}`,
+        `{"name": "a"}}`,
+        `{"name": "a"} x`,
+        `{"name": "` + strings.Repeat("a", BindMaxBodySize) + `"}`,
+    }
+
+    for _, body := range bodies {
+        r := newBindingTestRequest("POST", "/", body, nil)
+
+        problems := getBindingTestProblems(t, BindRequest(r, new(bindingTestBodyRequest)))
+        if len(problems) != 1 || strings.HasPrefix(problems[0], "body [Body]: ") != true {
+            t.Fatalf("Problems for [%.20s] not correct: %v", body, problems)
+        }
+    }
+
+    // No body is not a problem.
+    r := newBindingTestRequest("POST", "/", "", nil)
+    if err := BindRequest(r, new(bindingTestBodyRequest)); err != nil {
+        t.Fatalf("Missing body should not be a problem: %s", err)
+    }
+}
@@ -3,9 +3,17 @@ package rirequest
 // Content types.
 const (
     CtApplicationJson = "application/json"
+    CtProblemJson = "application/problem+json"
     CtTextPlain = "text/plain"
 
     CtImageJpeg = "image/jpeg"
+    CtImageTiff = "image/tiff"
+    CtImagePng = "image/png"
+    CtImageHeic = "image/heic"
+    CtImageHeif = "image/heif"
+    CtImageWebp = "image/webp"
+
+    CtXmp = "application/rdf+xml"
 
     CtKml = "application/vnd.google-earth.kml+xml"
     CtGeojson = "application/vnd.geo+json"
@@ -1,9 +1,26 @@
 package rirequest
 
 import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+
+    "google.golang.org/appengine"
+
+    "github.com/gansidui/geohash"
+
     "github.com/randomingenuity/go-ri/common"
 )
 
+// Distance units
+const (
+    DistanceUnitMeters = "m"
+    DistanceUnitKilometers = "km"
+    DistanceUnitMiles = "mi"
+    DistanceUnitFeet = "ft"
+)
+
 // Mime-type mappings
 var (
     FormatMimetypeMapping = map[string]string{
@@ -11,3 +28,182 @@ var (
         CtGeojson: ricommon.FormatGeoJson,
     }
 )
+
+// Other
+var (
+    // The length of each unit in meters.
+    DistanceUnitMeterMapping = map[string]float64 {
+        DistanceUnitMeters: 1,
+        DistanceUnitKilometers: 1000,
+        DistanceUnitMiles: 1609.344,
+        DistanceUnitFeet: 0.3048,
+    }
+
+    // Half of the Earth's circumference. Every point is within this distance.
+    maxDistanceMeters = math.Pi * ricommon.EarthRadiusMeters
+)
+
+// ParseBoundingBox Parse a "minLng,minLat,maxLng,maxLat" box (GeoJSON order).
+// As in GeoJSON, a box whose minimum longitude is greater than its maximum
+// crosses the antimeridian; it's returned as two boxes, one on either side.
+// A longitude of 180 or -180 never produces a box with no width.
+func ParseBoundingBox(raw string) (boxes []*geohash.Box, err error) {
+    parts := strings.Split(raw, ",")
+    if len(parts) != 4 {
+        return nil, fmt.Errorf("bounding box must be \"minLng,minLat,maxLng,maxLat\"")
+    }
+
+    values := make([]float64, 4)
+    for i, part := range parts {
+        values[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+        if err != nil || math.IsNaN(values[i]) == true {
+            return nil, fmt.Errorf("bounding box coordinate not valid: [%s]", part)
+        }
+    }
+
+    minLng, minLat, maxLng, maxLat := values[0], values[1], values[2], values[3]
+
+    if minLng < -180 || minLng > 180 || maxLng < -180 || maxLng > 180 {
+        return nil, fmt.Errorf("bounding box longitude out of range")
+    } else if minLat < -90 || minLat > 90 || maxLat < -90 || maxLat > 90 {
+        return nil, fmt.Errorf("bounding box latitude out of range")
+    } else if minLat >= maxLat {
+        return nil, fmt.Errorf("bounding box minimum latitude must be less than the maximum")
+    } else if minLng == maxLng {
+        return nil, fmt.Errorf("bounding box has no width")
+    }
+
+    // -180 and 180 are the same meridian. A box can only start at the former
+    // and end at the latter, so "180,...,-180,..." is the whole world rather
+    // than two boxes with no width.
+    if minLng == 180 {
+        minLng = -180
+    }
+
+    if maxLng == -180 {
+        maxLng = 180
+    }
+
+    return splitBoxAtAntimeridian(minLat, maxLat, minLng, maxLng), nil
+}
+
+// splitBoxAtAntimeridian Return the box, or two boxes if `minLng` is greater
+// than `maxLng`.
+func splitBoxAtAntimeridian(minLat, maxLat, minLng, maxLng float64) (boxes []*geohash.Box) {
+    if minLng <= maxLng {
+        box := &geohash.Box{
+            MinLat: minLat,
+            MaxLat: maxLat,
+            MinLng: minLng,
+            MaxLng: maxLng,
+        }
+
+        return []*geohash.Box { box }
+    }
+
+    east := &geohash.Box{
+        MinLat: minLat,
+        MaxLat: maxLat,
+        MinLng: minLng,
+        MaxLng: 180,
+    }
+
+    west := &geohash.Box{
+        MinLat: minLat,
+        MaxLat: maxLat,
+        MinLng: -180,
+        MaxLng: maxLng,
+    }
+
+    return []*geohash.Box { east, west }
+}
+
+// BoxCenter Return the center of the box (e.g. for
+// ricommon.GetBoundingGeohashPrefixForBox).
+func BoxCenter(box *geohash.Box) *appengine.GeoPoint {
+    return &appengine.GeoPoint{
+        Lat: (box.MinLat + box.MaxLat) / 2,
+        Lng: (box.MinLng + box.MaxLng) / 2,
+    }
+}
+
+// ParseDistance Parse a distance such as "250", "1.5km", or "3 mi" into
+// meters. A missing unit means meters. The distance must be positive and no
+// more than half the Earth's circumference.
+func ParseDistance(raw string) (meters float64, err error) {
+    raw = strings.TrimSpace(raw)
+
+    number := strings.TrimRightFunc(raw, func(r rune) bool {
+        return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+    })
+
+    unit := strings.ToLower(raw[len(number):])
+    if unit == "" {
+        unit = DistanceUnitMeters
+    }
+
+    factor, found := DistanceUnitMeterMapping[unit]
+    if found == false {
+        return 0, fmt.Errorf("distance unit not valid: [%s]", unit)
+    }
+
+    value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+    if err != nil || math.IsNaN(value) == true {
+        return 0, fmt.Errorf("distance not valid")
+    }
+
+    meters = value * factor
+    if meters <= 0 || meters > maxDistanceMeters {
+        return 0, fmt.Errorf("distance out of range")
+    }
+
+    return meters, nil
+}
+
+// GeoCircle A center point and a radius.
+type GeoCircle struct {
+    Center *appengine.GeoPoint
+    RadiusMeters float64
+}
+
+// BoundingBoxes Return the boxes that enclose the circle, split at the
+// antimeridian. A circle that reaches a pole covers every longitude.
+func (gc *GeoCircle) BoundingBoxes() (boxes []*geohash.Box) {
+    deltaLat := gc.RadiusMeters / ricommon.EarthRadiusMeters * 180 / math.Pi
+
+    minLat := gc.Center.Lat - deltaLat
+    maxLat := gc.Center.Lat + deltaLat
+
+    if minLat <= -90 || maxLat >= 90 {
+        minLat = math.Max(minLat, -90)
+        maxLat = math.Min(maxLat, 90)
+
+        return splitBoxAtAntimeridian(minLat, maxLat, -180, 180)
+    }
+
+    // The longitude span widens toward the poles. Use the latitude farthest
+    // from the equator.
+    widest := math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180
+    deltaLng := deltaLat / math.Cos(widest)
+
+    if deltaLng >= 180 {
+        return splitBoxAtAntimeridian(minLat, maxLat, -180, 180)
+    }
+
+    minLng := gc.Center.Lng - deltaLng
+    maxLng := gc.Center.Lng + deltaLng
+
+    if minLng < -180 {
+        minLng += 360
+    } else if maxLng > 180 {
+        maxLng -= 360
+    }
+
+    return splitBoxAtAntimeridian(minLat, maxLat, minLng, maxLng)
+}
+
+// Contains Return whether the coordinates are within the circle.
+func (gc *GeoCircle) Contains(latitude, longitude float64) bool {
+    distance := ricommon.DistanceBetweenCoordinates(gc.Center.Lat, gc.Center.Lng, latitude, longitude)
+    return distance <= gc.RadiusMeters
+}
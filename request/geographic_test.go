@@ -0,0 +1,65 @@
+package rirequest
+
+import (
+    "fmt"
+    "testing"
+
+    "github.com/gansidui/geohash"
+)
+
+func getGeographicTestBoxes(boxes []*geohash.Box) []string {
+    described := make([]string, len(boxes))
+    for i, box := range boxes {
+        described[i] = fmt.Sprintf("%g,%g,%g,%g", box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+    }
+
+    return described
+}
+
+func TestParseBoundingBox(t *testing.T) {
+    cases := map[string][]string {
+        "-123,47,-122,48": []string { "-123,47,-122,48" },
+
+        // Crosses the antimeridian.
+        "170,-10,-170,10": []string { "170,-10,180,10", "-180,-10,-170,10" },
+
+        // The whole world, either way around.
+        "-180,-90,180,90": []string { "-180,-90,180,90" },
+        "180,-90,-180,90": []string { "-180,-90,180,90" },
+
+        // Starts or ends on the antimeridian.
+        "180,0,10,1": []string { "-180,0,10,1" },
+        "10,0,-180,1": []string { "10,0,180,1" },
+    }
+
+    for raw, expected := range cases {
+        boxes, err := ParseBoundingBox(raw)
+        if err != nil {
+            t.Fatalf("Box [%s] not parsed: %s", raw, err)
+        }
+
+        if actual := getGeographicTestBoxes(boxes); fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", expected) {
+            t.Fatalf("Boxes for [%s] not correct: %v", raw, actual)
+        }
+    }
+}
+
+func TestParseBoundingBox_NotValid(t *testing.T) {
+    raws := []string {
+        "1,2,3",
+        "a,0,1,1",
+        "NaN,0,1,1",
+        "-181,0,1,1",
+        "0,-91,1,1",
+        "0,1,1,1",
+        "1,0,1,1",
+        "180,0,180,1",
+        "-180,0,-180,1",
+    }
+
+    for _, raw := range raws {
+        if _, err := ParseBoundingBox(raw); err == nil {
+            t.Fatalf("Expected error for [%s].", raw)
+        }
+    }
+}
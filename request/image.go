@@ -0,0 +1,36 @@
+package rirequest
+
+import (
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Mime-type mappings
+var (
+    ImageFormatMimetypeMapping = map[string]string{
+        CtImageJpeg: ricommon.ImageFormatJpeg,
+        CtImageTiff: ricommon.ImageFormatTiff,
+        CtImagePng: ricommon.ImageFormatPng,
+        CtImageHeic: ricommon.ImageFormatHeif,
+        CtImageHeif: ricommon.ImageFormatHeif,
+        CtImageWebp: ricommon.ImageFormatWebp,
+        CtXmp: ricommon.ImageFormatXmp,
+    }
+
+    // The preferred content-type for each format (HEIF images are almost
+    // always HEVC-encoded, so we prefer "image/heic").
+    ImageFormatPreferredMimetype = map[string]string{
+        ricommon.ImageFormatJpeg: CtImageJpeg,
+        ricommon.ImageFormatTiff: CtImageTiff,
+        ricommon.ImageFormatPng: CtImagePng,
+        ricommon.ImageFormatHeif: CtImageHeic,
+        ricommon.ImageFormatWebp: CtImageWebp,
+        ricommon.ImageFormatXmp: CtXmp,
+    }
+)
+
+// SniffImageMimetype Return the content-type for the image, given its first
+// few bytes, or an empty string if not recognized.
+func SniffImageMimetype(header []byte) string {
+    format := ricommon.SniffImageFormat(header)
+    return ImageFormatPreferredMimetype[format]
+}
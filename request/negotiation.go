@@ -0,0 +1,204 @@
+package rirequest
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "net/http"
+)
+
+// MediaRange One entry of an Accept header.
+type MediaRange struct {
+    Type string
+    Subtype string
+    Parameters map[string]string
+    Quality float64
+}
+
+// specificity Rank wildcards below types below types with parameters.
+func (mr MediaRange) specificity() int {
+    if mr.Type == "*" {
+        return 0
+    } else if mr.Subtype == "*" {
+        return 1
+    } else if len(mr.Parameters) == 0 {
+        return 2
+    }
+
+    return 3
+}
+
+// matches Return whether the range includes the content-type.
+func (mr MediaRange) matches(contentType MediaRange) bool {
+    if mr.Type != "*" && mr.Type != contentType.Type {
+        return false
+    } else if mr.Subtype != "*" && mr.Subtype != contentType.Subtype {
+        return false
+    }
+
+    for name, value := range mr.Parameters {
+        if contentType.Parameters[name] != value {
+            return false
+        }
+    }
+
+    return true
+}
+
+// NotAcceptableError Nothing that the client accepts can be produced.
+type NotAcceptableError struct {
+    Acceptable []string `json:"acceptable"`
+}
+
+func (nae *NotAcceptableError) Error() string {
+    return fmt.Sprintf("no acceptable content-type; supported: %s", strings.Join(nae.Acceptable, ", "))
+}
+
+// WriteResponse Write a 406 problem response that lists the supported types.
+func (nae *NotAcceptableError) WriteResponse(w http.ResponseWriter) {
+    WriteProblem(w, nil, nae)
+}
+
+// ParseAccept Parse an Accept header into media ranges, most preferred first
+// (by quality and then by specificity). Malformed entries are skipped.
+func ParseAccept(header string) (ranges []MediaRange) {
+    ranges = make([]MediaRange, 0)
+
+    for _, entry := range strings.Split(header, ",") {
+        mr, err := parseMediaRange(entry)
+        if err != nil {
+            continue
+        }
+
+        ranges = append(ranges, mr)
+    }
+
+    sort.SliceStable(ranges, func(i, j int) bool {
+        if ranges[i].Quality != ranges[j].Quality {
+            return ranges[i].Quality > ranges[j].Quality
+        }
+
+        return ranges[i].specificity() > ranges[j].specificity()
+    })
+
+    return ranges
+}
+
+func parseMediaRange(raw string) (mr MediaRange, err error) {
+    parts := strings.Split(raw, ";")
+
+    fullType := strings.ToLower(strings.TrimSpace(parts[0]))
+    slash := strings.Index(fullType, "/")
+    if slash <= 0 || slash == len(fullType) - 1 {
+        return mr, fmt.Errorf("media range not valid: [%s]", raw)
+    }
+
+    mr = MediaRange{
+        Type: fullType[:slash],
+        Subtype: fullType[slash + 1:],
+        Parameters: make(map[string]string),
+        Quality: 1,
+    }
+
+    if mr.Type == "*" && mr.Subtype != "*" {
+        return mr, fmt.Errorf("media range not valid: [%s]", raw)
+    }
+
+    for _, parameter := range parts[1:] {
+        equals := strings.Index(parameter, "=")
+        if equals == -1 {
+            continue
+        }
+
+        name := strings.ToLower(strings.TrimSpace(parameter[:equals]))
+        value := strings.Trim(strings.TrimSpace(parameter[equals + 1:]), "\"")
+
+        if name == "q" {
+            quality, err := strconv.ParseFloat(value, 64)
+            if err != nil || quality < 0 || quality > 1 {
+                return mr, fmt.Errorf("quality not valid: [%s]", raw)
+            }
+
+            mr.Quality = quality
+
+            // Anything after "q" is an accept-extension.
+            break
+        }
+
+        mr.Parameters[name] = value
+    }
+
+    return mr, nil
+}
+
+// NegotiateContentType Return the supported content-type that the client
+// prefers. The quality of each supported type comes from the most specific
+// range that matches it. Ties go to the earlier supported type, as does an
+// empty header. Returns a *NotAcceptableError if nothing matches.
+func NegotiateContentType(accept string, supported []string) (contentType string, err error) {
+    if len(supported) == 0 {
+        return "", fmt.Errorf("no supported content-types")
+    }
+
+    if strings.TrimSpace(accept) == "" {
+        return supported[0], nil
+    }
+
+    ranges := ParseAccept(accept)
+
+    bestQuality := 0.0
+    for _, candidate := range supported {
+        ct, err := parseMediaRange(candidate)
+        if err != nil {
+            return "", err
+        }
+
+        quality := 0.0
+        specificity := -1
+
+        for _, mr := range ranges {
+            if mr.specificity() > specificity && mr.matches(ct) == true {
+                quality = mr.Quality
+                specificity = mr.specificity()
+            }
+        }
+
+        if quality > bestQuality {
+            contentType = candidate
+            bestQuality = quality
+        }
+    }
+
+    if contentType == "" {
+        nae := &NotAcceptableError{
+            Acceptable: supported,
+        }
+
+        return "", nae
+    }
+
+    return contentType, nil
+}
+
+// NegotiateFormat Negotiate the request's Accept headers against the supported
+// content-types and return the chosen type along with its format name from
+// FormatMimetypeMapping (empty if it has none). Repeated Accept headers are
+// combined.
+func NegotiateFormat(r *http.Request, supported []string) (contentType string, format string, err error) {
+    accept := strings.Join(r.Header.Values("Accept"), ",")
+
+    contentType, err = NegotiateContentType(accept, supported)
+    if err != nil {
+        return "", "", err
+    }
+
+    mr, err := parseMediaRange(contentType)
+    if err != nil {
+        return "", "", err
+    }
+
+    format = FormatMimetypeMapping[mr.Type + "/" + mr.Subtype]
+    return contentType, format, nil
+}
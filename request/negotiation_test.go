@@ -0,0 +1,114 @@
+package rirequest
+
+import (
+    "fmt"
+    "testing"
+
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+func TestParseAccept(t *testing.T) {
+    ranges := ParseAccept(`text/*;q=0.5, */*;q=0.1, text/html;level=1, bad, */html, text/plain;q=2, text/html, application/json;q=0.5;ext=1`)
+
+    described := make([]string, len(ranges))
+    for i, mr := range ranges {
+        described[i] = fmt.Sprintf("%s/%s %v %g", mr.Type, mr.Subtype, mr.Parameters, mr.Quality)
+    }
+
+    // By quality and then by specificity. Malformed entries are skipped and
+    // accept-extensions aren't parameters.
+    expected := []string {
+        "text/html map[level:1] 1",
+        "text/html map[] 1",
+        "application/json map[] 0.5",
+        "text/* map[] 0.5",
+        "*/* map[] 0.1",
+    }
+
+    if fmt.Sprintf("%v", described) != fmt.Sprintf("%v", expected) {
+        t.Fatalf("Ranges not correct:\n%v", described)
+    }
+}
+
+func TestNegotiateContentType(t *testing.T) {
+    supported := []string { CtApplicationJson, CtGeojson, CtKml }
+
+    cases := map[string]string {
+        // No preference.
+        "": CtApplicationJson,
+        "*/*": CtApplicationJson,
+
+        // By quality.
+        CtKml + ";q=0.9, " + CtGeojson: CtGeojson,
+        "application/*;q=0.5, " + CtKml: CtKml,
+
+        // The most specific range decides the quality of a type.
+        "application/*, " + CtApplicationJson + ";q=0.1": CtGeojson,
+        "*/*;q=0.1, " + CtKml + ";q=0.2": CtKml,
+
+        // Excluded with zero quality.
+        CtApplicationJson + ";q=0, */*": CtGeojson,
+
+        // Ties go to the earlier supported type.
+        CtKml + ", " + CtGeojson: CtGeojson,
+
+        // Case doesn't matter.
+        "Application/Vnd.Geo+JSON": CtGeojson,
+    }
+
+    for accept, expected := range cases {
+        contentType, err := NegotiateContentType(accept, supported)
+        if err != nil {
+            t.Fatalf("Content-type for [%s] not negotiated: %s", accept, err)
+        } else if contentType != expected {
+            t.Fatalf("Content-type for [%s] not correct: [%s]", accept, contentType)
+        }
+    }
+}
+
+func TestNegotiateContentType_NotAcceptable(t *testing.T) {
+    supported := []string { CtApplicationJson }
+
+    for _, accept := range []string { "text/html", "application/json;q=0", "application/json;version=2", "text/*, image/*" } {
+        _, err := NegotiateContentType(accept, supported)
+
+        nae, ok := err.(*NotAcceptableError)
+        if ok == false {
+            t.Fatalf("Expected not-acceptable error for [%s]: [%v]", accept, err)
+        } else if len(nae.Acceptable) != 1 || nae.Acceptable[0] != CtApplicationJson {
+            t.Fatalf("Acceptable types not correct: %v", nae.Acceptable)
+        } else if HttpStatusForError(err) != http.StatusNotAcceptable {
+            t.Fatalf("Status not correct: (%d)", HttpStatusForError(err))
+        }
+    }
+
+    if _, err := NegotiateContentType("*/*", nil); err == nil {
+        t.Fatalf("Expected error for no supported types.")
+    }
+}
+
+func TestNegotiateFormat(t *testing.T) {
+    r := httptest.NewRequest("GET", "/", nil)
+    r.Header.Add("Accept", CtApplicationJson + ";q=0.5")
+    r.Header.Add("Accept", CtKml)
+
+    contentType, format, err := NegotiateFormat(r, []string { CtApplicationJson, CtKml })
+    if err != nil {
+        t.Fatalf("Format not negotiated: %s", err)
+    } else if contentType != CtKml || format != ricommon.FormatKml {
+        t.Fatalf("Format not correct: [%s] [%s]", contentType, format)
+    }
+
+    // Types without a format.
+    r.Header.Set("Accept", CtApplicationJson)
+
+    contentType, format, err = NegotiateFormat(r, []string { CtApplicationJson, CtKml })
+    if err != nil {
+        t.Fatalf("Format not negotiated: %s", err)
+    } else if contentType != CtApplicationJson || format != "" {
+        t.Fatalf("Format not correct: [%s] [%s]", contentType, format)
+    }
+}
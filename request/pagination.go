@@ -0,0 +1,251 @@
+package rirequest
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "encoding/base64"
+    "encoding/json"
+    "net/http"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Pagination query parameters
+const (
+    PageQueryCursor = "cursor"
+    PageQueryLimit = "limit"
+)
+
+// Other
+const (
+    // Separates the cursor payload from its signature before encoding.
+    cursorSeparator = "\n"
+
+    // RFC 3339 with fixed-width nanoseconds.
+    cursorTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+// Errors
+var (
+    // Cursors that were tampered with, were signed with a retired key, or
+    // aren't cursors at all. An argument error.
+    ErrCursorNotValid = fmt.Errorf("%w: cursor not valid", ricommon.ErrArgumentError)
+)
+
+// Cursor A position in a sorted list: the sort key (e.g. a timestamp or
+// geohash) and ID of the last item on the page. The backend resumes after
+// this item (or before it when paging backward).
+type Cursor struct {
+    Key string `json:"k"`
+
+    // Breaks ties between items with the same key.
+    Id string `json:"i,omitempty"`
+
+    // Page backward from the position.
+    Reverse bool `json:"r,omitempty"`
+}
+
+// NewTimeCursor Return a cursor keyed by the timestamp. Keys are in UTC with
+// fixed-width nanoseconds so that they also sort as strings.
+func NewTimeCursor(timestamp time.Time, id string, reverse bool) Cursor {
+    return Cursor{
+        Key: timestamp.UTC().Format(cursorTimeLayout),
+        Id: id,
+        Reverse: reverse,
+    }
+}
+
+// Time Return the key of a cursor from NewTimeCursor.
+func (c Cursor) Time() (timestamp time.Time, err error) {
+    timestamp, err = time.Parse(time.RFC3339Nano, c.Key)
+    if err != nil {
+        return time.Time{}, ErrCursorNotValid
+    }
+
+    return timestamp, nil
+}
+
+// CursorCodec Encodes cursors as opaque, URL-safe strings signed with the
+// keyring so that clients can't forge positions. Keys can be rotated by
+// adding a new current key to the keyring; cursors signed with keys that are
+// still on the keyring keep working.
+type CursorCodec struct {
+    keyring *ricommon.DigestKeyring
+    scope string
+}
+
+// NewCursorCodec Return a codec that signs with the keyring. The scope (e.g.
+// the list's route and filters) is signed along with each cursor but not
+// included in it, so a cursor from one list can't be replayed against another
+// that shares the keyring.
+func NewCursorCodec(keyring *ricommon.DigestKeyring, scope string) *CursorCodec {
+    return &CursorCodec{
+        keyring: keyring,
+        scope: scope,
+    }
+}
+
+// Encode Return the opaque form of the cursor.
+func (cc *CursorCodec) Encode(c Cursor) (encoded string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = ricommon.DistillError(state)
+        }
+    }()
+
+    payload, err := json.Marshal(c)
+    log.PanicIf(err)
+
+    digest, err := cc.keyring.EncodeStringsToKeyedDigestString([]string { cc.scope, string(payload) })
+    log.PanicIf(err)
+
+    raw := string(payload) + cursorSeparator + digest
+    return base64.RawURLEncoding.EncodeToString([]byte(raw)), nil
+}
+
+// Decode Return the cursor or ErrCursorNotValid. Cursors from a codec with a
+// different scope aren't valid.
+func (cc *CursorCodec) Decode(encoded string) (c Cursor, err error) {
+    raw, err := base64.RawURLEncoding.DecodeString(encoded)
+    if err != nil {
+        return Cursor{}, ErrCursorNotValid
+    }
+
+    parts := strings.SplitN(string(raw), cursorSeparator, 2)
+    if len(parts) != 2 {
+        return Cursor{}, ErrCursorNotValid
+    }
+
+    ok, err := cc.keyring.VerifyStringsKeyedDigestString(parts[1], []string { cc.scope, parts[0] })
+    if err != nil || ok == false {
+        return Cursor{}, ErrCursorNotValid
+    }
+
+    if err := json.Unmarshal([]byte(parts[0]), &c); err != nil {
+        return Cursor{}, ErrCursorNotValid
+    }
+
+    return c, nil
+}
+
+// PageLimit Read the page size from the "limit" query parameter. Missing
+// means `defaultLimit` and anything over `maxLimit` is capped.
+func (pe *ParameterExtractor) PageLimit(defaultLimit, maxLimit int) int {
+    raw, found := pe.get(ParameterSourceQuery, PageQueryLimit, false)
+    if found == false {
+        return defaultLimit
+    }
+
+    limit, err := strconv.Atoi(raw)
+    if err != nil || limit < 1 {
+        pe.AddProblem(ParameterSourceQuery, PageQueryLimit, raw, "must be a positive integer")
+        return defaultLimit
+    }
+
+    if limit > maxLimit {
+        return maxLimit
+    }
+
+    return limit
+}
+
+// PageCursor Read the "cursor" query parameter. Returns nil for the first
+// page.
+func (pe *ParameterExtractor) PageCursor(cc *CursorCodec) *Cursor {
+    raw, found := pe.get(ParameterSourceQuery, PageQueryCursor, false)
+    if found == false {
+        return nil
+    }
+
+    c, err := cc.Decode(raw)
+    if err != nil {
+        pe.AddProblem(ParameterSourceQuery, PageQueryCursor, "", "cursor not valid")
+        return nil
+    }
+
+    return &c
+}
+
+// Page The envelope for a page of a list. The cursors are empty at either end
+// of the list.
+type Page struct {
+    Items interface{} `json:"items"`
+    Limit int `json:"limit"`
+    NextCursor string `json:"nextCursor,omitempty"`
+    PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// NewPage Encode the cursors and build the envelope. `next` and `prev` may be
+// nil. To tell whether there is a next page, backends can fetch one more item
+// than the limit.
+func (cc *CursorCodec) NewPage(items interface{}, limit int, next, prev *Cursor) (page *Page, err error) {
+    page = &Page{
+        Items: items,
+        Limit: limit,
+    }
+
+    if next != nil {
+        page.NextCursor, err = cc.Encode(*next)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    if prev != nil {
+        page.PrevCursor, err = cc.Encode(*prev)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    return page, nil
+}
+
+// LinkHeader Return an RFC 8288 Link header with "next" and "prev" links to
+// the page's cursors, or an empty string if it has neither. The links keep
+// the request's path and other query parameters.
+func (page *Page) LinkHeader(r *http.Request) string {
+    links := make([]string, 0, 2)
+
+    if page.NextCursor != "" {
+        links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageUrl(r, page.NextCursor, page.Limit)))
+    }
+
+    if page.PrevCursor != "" {
+        links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", pageUrl(r, page.PrevCursor, page.Limit)))
+    }
+
+    return strings.Join(links, ", ")
+}
+
+func pageUrl(r *http.Request, cursor string, limit int) string {
+    u := *r.URL
+
+    query := u.Query()
+    query.Set(PageQueryCursor, cursor)
+    query.Set(PageQueryLimit, strconv.Itoa(limit))
+    u.RawQuery = query.Encode()
+
+    // A relative reference, resolved against the request.
+    u.Scheme = ""
+    u.Host = ""
+    u.User = nil
+
+    return u.String()
+}
+
+// Write Write the page as JSON with its Link header.
+func (page *Page) Write(w http.ResponseWriter, r *http.Request) error {
+    if link := page.LinkHeader(r); link != "" {
+        w.Header().Set("Link", link)
+    }
+
+    w.Header().Set("Content-Type", CtApplicationJson)
+
+    return json.NewEncoder(w).Encode(page)
+}
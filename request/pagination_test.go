@@ -0,0 +1,168 @@
+package rirequest
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "encoding/base64"
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+func newPaginationTestKeyring(t *testing.T) *ricommon.DigestKeyring {
+    dk, err := ricommon.NewDigestKeyring(ricommon.HashAlgorithmSha256)
+    if err != nil {
+        t.Fatalf("Keyring not created: %s", err)
+    } else if err := dk.AddKey("k1", []byte("first key"), true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    }
+
+    return dk
+}
+
+func TestCursorCodec(t *testing.T) {
+    cc := NewCursorCodec(newPaginationTestKeyring(t), "/items")
+
+    timestamp := time.Date(2021, 6, 1, 12, 0, 0, 5, time.FixedZone("", 60 * 60))
+    c := NewTimeCursor(timestamp, "item-5", true)
+
+    encoded, err := cc.Encode(c)
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    decoded, err := cc.Decode(encoded)
+    if err != nil {
+        t.Fatalf("Cursor not decoded: %s", err)
+    } else if decoded != c {
+        t.Fatalf("Cursor not correct: %v", decoded)
+    }
+
+    if actual, err := decoded.Time(); err != nil || actual.Equal(timestamp) != true {
+        t.Fatalf("Timestamp not correct: [%s] %v", actual, err)
+    }
+}
+
+func TestCursorCodec_KeyRotation(t *testing.T) {
+    dk := newPaginationTestKeyring(t)
+    cc := NewCursorCodec(dk, "/items")
+
+    old, err := cc.Encode(Cursor{ Key: "a" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    if err := dk.AddKey("k2", []byte("second key"), true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    }
+
+    current, err := cc.Encode(Cursor{ Key: "a" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    } else if current == old {
+        t.Fatalf("Cursor not signed with the new key.")
+    }
+
+    // Both verify while the old key is on the ring.
+    for _, encoded := range []string { old, current } {
+        if _, err := cc.Decode(encoded); err != nil {
+            t.Fatalf("Cursor not decoded: %s", err)
+        }
+    }
+
+    if err := dk.RemoveKey("k1"); err != nil {
+        t.Fatalf("Key not removed: %s", err)
+    }
+
+    if _, err := cc.Decode(old); log.Is(err, ErrCursorNotValid) != true {
+        t.Fatalf("Cursor signed with a retired key should not be valid: [%v]", err)
+    } else if _, err := cc.Decode(current); err != nil {
+        t.Fatalf("Cursor not decoded: %s", err)
+    }
+}
+
+func TestCursorCodec_NotValid(t *testing.T) {
+    dk := newPaginationTestKeyring(t)
+    cc := NewCursorCodec(dk, "/items")
+
+    encoded, err := cc.Encode(Cursor{ Key: "a", Id: "1" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    raw, err := base64.RawURLEncoding.DecodeString(encoded)
+    if err != nil {
+        t.Fatalf("Cursor not base64: %s", err)
+    }
+
+    tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), `"k":"a"`, `"k":"b"`, 1)))
+
+    // Signed with a different key.
+    other, err := ricommon.NewDigestKeyring(ricommon.HashAlgorithmSha256)
+    if err != nil {
+        t.Fatalf("Keyring not created: %s", err)
+    } else if err := other.AddKey("k1", []byte("another key"), true); err != nil {
+        t.Fatalf("Key not added: %s", err)
+    }
+
+    forged, err := NewCursorCodec(other, "/items").Encode(Cursor{ Key: "a", Id: "1" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    // Same keyring but another list.
+    replayed, err := NewCursorCodec(dk, "/users").Encode(Cursor{ Key: "a", Id: "1" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    encodings := map[string]string {
+        "tampered": tampered,
+        "forged": forged,
+        "replayed": replayed,
+        "not base64": "!!",
+        "no signature": base64.RawURLEncoding.EncodeToString([]byte(`{"k":"a"}`)),
+        "truncated": encoded[:len(encoded) - 4],
+    }
+
+    for name, encoding := range encodings {
+        if _, err := cc.Decode(encoding); log.Is(err, ErrCursorNotValid) != true {
+            t.Fatalf("Error for [%s] cursor not correct: [%v]", name, err)
+        } else if HttpStatusForError(err) != http.StatusBadRequest {
+            t.Fatalf("Status for [%s] cursor not correct: (%d)", name, HttpStatusForError(err))
+        }
+    }
+}
+
+func TestParameterExtractor_PageCursor(t *testing.T) {
+    cc := NewCursorCodec(newPaginationTestKeyring(t), "/items")
+
+    encoded, err := cc.Encode(Cursor{ Key: "a" })
+    if err != nil {
+        t.Fatalf("Cursor not encoded: %s", err)
+    }
+
+    pe := NewParameterExtractor(httptest.NewRequest("GET", "/items?limit=500&cursor=" + encoded, nil))
+
+    if c := pe.PageCursor(cc); c == nil || c.Key != "a" {
+        t.Fatalf("Cursor not correct: %v", c)
+    } else if limit := pe.PageLimit(20, 100); limit != 100 {
+        t.Fatalf("Limit not capped: (%d)", limit)
+    } else if err := pe.Err(); err != nil {
+        t.Fatalf("Parameters not read: %s", err)
+    }
+
+    // The cursor isn't echoed back.
+    pe = NewParameterExtractor(httptest.NewRequest("GET", "/items?cursor=x", nil))
+
+    if c := pe.PageCursor(cc); c != nil {
+        t.Fatalf("Cursor should not be returned: %v", c)
+    } else if problems := pe.Err().(*ParameterError).Problems; len(problems) != 1 || problems[0].Value != "" {
+        t.Fatalf("Problems not correct: %v", problems)
+    }
+}
@@ -0,0 +1,516 @@
+package rirequest
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "net/http"
+
+    "google.golang.org/appengine"
+
+    "github.com/dsoprea/go-logging"
+    "github.com/gorilla/mux"
+    "github.com/gansidui/geohash"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Parameter sources
+const (
+    ParameterSourcePath = "path"
+    ParameterSourceQuery = "query"
+    ParameterSourceHeader = "header"
+    ParameterSourceForm = "form"
+    ParameterSourceBody = "body"
+)
+
+// Other
+const (
+    // ParameterProblemMaxValueLength is the most of a value that's echoed
+    // back in a problem. Longer values are truncated.
+    ParameterProblemMaxValueLength = 100
+)
+
+// ParameterProblem Describes one missing or invalid parameter.
+type ParameterProblem struct {
+    Source string `json:"source"`
+    Name string `json:"name"`
+    Value string `json:"value,omitempty"`
+    Message string `json:"message"`
+}
+
+// ParameterError Describes every invalid parameter in a request.
+type ParameterError struct {
+    Problems []ParameterProblem `json:"problems"`
+}
+
+func (pe *ParameterError) Error() string {
+    messages := make([]string, len(pe.Problems))
+    for i, problem := range pe.Problems {
+        messages[i] = fmt.Sprintf("%s [%s]: %s", problem.Source, problem.Name, problem.Message)
+    }
+
+    return fmt.Sprintf("request parameters not valid: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap Parameter errors are argument errors.
+func (pe *ParameterError) Unwrap() error {
+    return ricommon.ErrArgumentError
+}
+
+// WriteResponse Write a 400 problem response that lists every problem.
+func (pe *ParameterError) WriteResponse(w http.ResponseWriter) {
+    WriteProblem(w, nil, pe)
+}
+
+// ParameterExtractor Reads typed parameters from a request. Problems are
+// collected rather than raised so that they can all be reported at once:
+// getters return the zero value for invalid parameters, and Err() returns a
+// *ParameterError after the last one is read.
+//
+// The getters follow the GetConfigValue* pattern: the plain getter requires
+// the parameter and the "WithDefault" variant returns the default if the
+// parameter is missing or empty.
+type ParameterExtractor struct {
+    r *http.Request
+    problems []ParameterProblem
+}
+
+func NewParameterExtractor(r *http.Request) *ParameterExtractor {
+    return &ParameterExtractor{
+        r: r,
+        problems: make([]ParameterProblem, 0),
+    }
+}
+
+// Raw Return the parameter as-is or an empty string if it's not present.
+// Panics if the source isn't one of the ParameterSource* names (other than
+// the body). That's a bug in the handler rather than in the request, so it's
+// not an argument error.
+func (pe *ParameterExtractor) Raw(source, name string) string {
+    switch source {
+    case ParameterSourcePath:
+        return mux.Vars(pe.r)[name]
+    case ParameterSourceQuery:
+        return pe.r.URL.Query().Get(name)
+    case ParameterSourceHeader:
+        return pe.r.Header.Get(name)
+    case ParameterSourceForm:
+        return pe.r.PostFormValue(name)
+    }
+
+    log.Panic(fmt.Errorf("parameter source not valid: [%s] [%s]", source, name))
+    return ""
+}
+
+// Values Return every value of a repeated parameter. Path parameters have at
+// most one.
+func (pe *ParameterExtractor) Values(source, name string) []string {
+    switch source {
+    case ParameterSourceQuery:
+        return pe.r.URL.Query()[name]
+    case ParameterSourceHeader:
+        return pe.r.Header.Values(name)
+    case ParameterSourceForm:
+        // Populates PostForm.
+        pe.r.PostFormValue(name)
+        return pe.r.PostForm[name]
+    }
+
+    if raw := pe.Raw(source, name); raw != "" {
+        return []string { raw }
+    }
+
+    return nil
+}
+
+// AddProblem Record a problem (e.g. from handler-specific validation) so that
+// it's reported with the others. The value is truncated to
+// ParameterProblemMaxValueLength.
+func (pe *ParameterExtractor) AddProblem(source, name, value, message string) {
+    if len(value) > ParameterProblemMaxValueLength {
+        // Don't split a multibyte character.
+        cut := ParameterProblemMaxValueLength
+        for cut > 0 && utf8.RuneStart(value[cut]) == false {
+            cut--
+        }
+
+        value = value[:cut] + "..."
+    }
+
+    pe.problems = append(pe.problems, ParameterProblem{
+        Source: source,
+        Name: name,
+        Value: value,
+        Message: message,
+    })
+}
+
+// Err Return a *ParameterError if there were any problems, or nil.
+func (pe *ParameterExtractor) Err() error {
+    if len(pe.problems) == 0 {
+        return nil
+    }
+
+    return &ParameterError{
+        Problems: pe.problems,
+    }
+}
+
+// get Return the raw value, recording a problem if it's required and missing.
+// `found` is false if there's nothing to parse.
+func (pe *ParameterExtractor) get(source, name string, required bool) (raw string, found bool) {
+    raw = pe.Raw(source, name)
+    if raw != "" {
+        return raw, true
+    }
+
+    if required == true {
+        pe.AddProblem(source, name, "", "missing")
+    }
+
+    return "", false
+}
+
+func (pe *ParameterExtractor) String(source, name string) string {
+    raw, _ := pe.get(source, name, true)
+    return raw
+}
+
+func (pe *ParameterExtractor) StringWithDefault(source, name string, defaultValue string) string {
+    if raw, found := pe.get(source, name, false); found == true {
+        return raw
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) Int(source, name string) int64 {
+    value, _ := pe.parseInt(source, name, true)
+    return value
+}
+
+func (pe *ParameterExtractor) IntWithDefault(source, name string, defaultValue int64) int64 {
+    if value, found := pe.parseInt(source, name, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseInt(source, name string, required bool) (value int64, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return 0, false
+    }
+
+    value, err := strconv.ParseInt(raw, 10, 64)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "integer not valid")
+        return 0, false
+    }
+
+    return value, true
+}
+
+func (pe *ParameterExtractor) Float(source, name string) float64 {
+    value, _ := pe.parseFloat(source, name, true)
+    return value
+}
+
+func (pe *ParameterExtractor) FloatWithDefault(source, name string, defaultValue float64) float64 {
+    if value, found := pe.parseFloat(source, name, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseFloat(source, name string, required bool) (value float64, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return 0, false
+    }
+
+    value, err := strconv.ParseFloat(raw, 64)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "number not valid")
+        return 0, false
+    }
+
+    return value, true
+}
+
+func (pe *ParameterExtractor) Bool(source, name string) bool {
+    value, _ := pe.parseBool(source, name, true)
+    return value
+}
+
+func (pe *ParameterExtractor) BoolWithDefault(source, name string, defaultValue bool) bool {
+    if value, found := pe.parseBool(source, name, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseBool(source, name string, required bool) (value bool, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return false, false
+    }
+
+    value, err := strconv.ParseBool(raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "boolean not valid")
+        return false, false
+    }
+
+    return value, true
+}
+
+// Time Read an RFC 3339 timestamp or Unix time in seconds.
+func (pe *ParameterExtractor) Time(source, name string) time.Time {
+    value, _ := pe.parseTime(source, name, true)
+    return value
+}
+
+func (pe *ParameterExtractor) TimeWithDefault(source, name string, defaultValue time.Time) time.Time {
+    if value, found := pe.parseTime(source, name, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseTime(source, name string, required bool) (value time.Time, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return time.Time{}, false
+    }
+
+    if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+        return time.Unix(seconds, 0).UTC(), true
+    }
+
+    value, err := time.Parse(time.RFC3339Nano, raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "timestamp not valid (expected RFC 3339 or Unix seconds)")
+        return time.Time{}, false
+    }
+
+    return value, true
+}
+
+// Duration Read a duration with a unit suffix ("1m30s") or in seconds.
+func (pe *ParameterExtractor) Duration(source, name string) time.Duration {
+    value, _ := pe.parseDuration(source, name, true)
+    return value
+}
+
+func (pe *ParameterExtractor) DurationWithDefault(source, name string, defaultValue time.Duration) time.Duration {
+    if value, found := pe.parseDuration(source, name, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseDuration(source, name string, required bool) (value time.Duration, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return 0, false
+    }
+
+    value, err := ricommon.ParseConfigDuration(raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "duration not valid")
+        return 0, false
+    }
+
+    return value, true
+}
+
+// Enum Read a value that must be one of `allowed`.
+func (pe *ParameterExtractor) Enum(source, name string, allowed []string) string {
+    value, _ := pe.parseEnum(source, name, allowed, true)
+    return value
+}
+
+func (pe *ParameterExtractor) EnumWithDefault(source, name string, allowed []string, defaultValue string) string {
+    if value, found := pe.parseEnum(source, name, allowed, false); found == true {
+        return value
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseEnum(source, name string, allowed []string, required bool) (value string, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return "", false
+    }
+
+    for _, candidate := range allowed {
+        if raw == candidate {
+            return raw, true
+        }
+    }
+
+    pe.AddProblem(source, name, raw, fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", ")))
+    return "", false
+}
+
+// Geohash Read a geohash and return it (lowercased) with the box that it
+// describes.
+func (pe *ParameterExtractor) Geohash(source, name string) (hash string, box *geohash.Box) {
+    hash, box, _ = pe.parseGeohash(source, name, true)
+    return hash, box
+}
+
+// GeohashWithDefault Read a geohash or return the default (and the box that it
+// describes). Panics if the default isn't a valid geohash.
+func (pe *ParameterExtractor) GeohashWithDefault(source, name string, defaultValue string) (hash string, box *geohash.Box) {
+    if hash, box, found := pe.parseGeohash(source, name, false); found == true {
+        return hash, box
+    }
+
+    box, err := ricommon.DecodeGeohash(defaultValue)
+    log.PanicIf(err)
+
+    return strings.ToLower(defaultValue), box
+}
+
+func (pe *ParameterExtractor) parseGeohash(source, name string, required bool) (hash string, box *geohash.Box, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return "", nil, false
+    }
+
+    box, err := ricommon.DecodeGeohash(raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, "geohash not valid")
+        return "", nil, false
+    }
+
+    return strings.ToLower(raw), box, true
+}
+
+// LatLng Read a "latitude,longitude" pair.
+func (pe *ParameterExtractor) LatLng(source, name string) *appengine.GeoPoint {
+    gp, _ := pe.parseLatLng(source, name, true)
+    return gp
+}
+
+// LatLngWithDefault Read a "latitude,longitude" pair or return the default.
+func (pe *ParameterExtractor) LatLngWithDefault(source, name string, defaultValue *appengine.GeoPoint) *appengine.GeoPoint {
+    if gp, found := pe.parseLatLng(source, name, false); found == true {
+        return gp
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseLatLng(source, name string, required bool) (gp *appengine.GeoPoint, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return nil, false
+    }
+
+    gp, err := ParseLatLng(raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, err.Error())
+        return nil, false
+    }
+
+    return gp, true
+}
+
+// ParseLatLng Parse and range-check a "latitude,longitude" pair.
+func ParseLatLng(raw string) (gp *appengine.GeoPoint, err error) {
+    parts := strings.Split(raw, ",")
+    if len(parts) != 2 {
+        return nil, fmt.Errorf("coordinates must be \"latitude,longitude\"")
+    }
+
+    latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+    if err != nil {
+        return nil, fmt.Errorf("latitude not valid")
+    }
+
+    longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+    if err != nil {
+        return nil, fmt.Errorf("longitude not valid")
+    }
+
+    gp = &appengine.GeoPoint{
+        Lat: latitude,
+        Lng: longitude,
+    }
+
+    if gp.Valid() == false {
+        return nil, fmt.Errorf("coordinates out of range")
+    }
+
+    return gp, nil
+}
+
+// BoundingBox Read a "minLng,minLat,maxLng,maxLat" box. See ParseBoundingBox.
+func (pe *ParameterExtractor) BoundingBox(source, name string) []*geohash.Box {
+    boxes, _ := pe.parseBoundingBox(source, name, true)
+    return boxes
+}
+
+// BoundingBoxWithDefault Read a "minLng,minLat,maxLng,maxLat" box or return
+// the default.
+func (pe *ParameterExtractor) BoundingBoxWithDefault(source, name string, defaultValue []*geohash.Box) []*geohash.Box {
+    if boxes, found := pe.parseBoundingBox(source, name, false); found == true {
+        return boxes
+    }
+
+    return defaultValue
+}
+
+func (pe *ParameterExtractor) parseBoundingBox(source, name string, required bool) (boxes []*geohash.Box, found bool) {
+    raw, found := pe.get(source, name, required)
+    if found == false {
+        return nil, false
+    }
+
+    boxes, err := ParseBoundingBox(raw)
+    if err != nil {
+        pe.AddProblem(source, name, raw, err.Error())
+        return nil, false
+    }
+
+    return boxes, true
+}
+
+// Circle Read a "latitude,longitude" center and a radius (see ParseDistance)
+// from two parameters.
+func (pe *ParameterExtractor) Circle(source, centerName, radiusName string) *GeoCircle {
+    center := pe.LatLng(source, centerName)
+
+    raw, found := pe.get(source, radiusName, true)
+    if found == false {
+        return nil
+    }
+
+    radius, err := ParseDistance(raw)
+    if err != nil {
+        pe.AddProblem(source, radiusName, raw, err.Error())
+        return nil
+    } else if center == nil {
+        return nil
+    }
+
+    gc := &GeoCircle{
+        Center: center,
+        RadiusMeters: radius,
+    }
+
+    return gc
+}
@@ -0,0 +1,177 @@
+package rirequest
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    "net/http"
+    "net/http/httptest"
+
+    "google.golang.org/appengine"
+
+    "github.com/gansidui/geohash"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+func TestParameterExtractor(t *testing.T) {
+    r := newBindingTestRequest("GET", "/items/5?limit=50&ratio=0.5&all=true&since=2021-06-01T12:00:00Z&timeout=1m&order=asc&area=9q8yy&center=47.5,-122.3&bbox=-123,47,-122,48", "", map[string]string { "id": "5" })
+    r.Header.Set("X-Tenant", "acme")
+
+    pe := NewParameterExtractor(r)
+
+    id := pe.Int(ParameterSourcePath, "id")
+    tenant := pe.String(ParameterSourceHeader, "X-Tenant")
+    limit := pe.Int(ParameterSourceQuery, "limit")
+    ratio := pe.Float(ParameterSourceQuery, "ratio")
+    all := pe.Bool(ParameterSourceQuery, "all")
+    since := pe.Time(ParameterSourceQuery, "since")
+    timeout := pe.Duration(ParameterSourceQuery, "timeout")
+    order := pe.Enum(ParameterSourceQuery, "order", []string { "asc", "desc" })
+    hash, box := pe.Geohash(ParameterSourceQuery, "area")
+    center := pe.LatLng(ParameterSourceQuery, "center")
+    boxes := pe.BoundingBox(ParameterSourceQuery, "bbox")
+
+    if err := pe.Err(); err != nil {
+        t.Fatalf("Parameters not read: %s", err)
+    }
+
+    if id != 5 || tenant != "acme" || limit != 50 || ratio != 0.5 || all != true {
+        t.Fatalf("Scalars not correct: (%d) [%s] (%d) (%f) [%v]", id, tenant, limit, ratio, all)
+    } else if since.Equal(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)) != true {
+        t.Fatalf("Timestamp not correct: [%s]", since)
+    } else if timeout != time.Minute || order != "asc" {
+        t.Fatalf("Duration or enum not correct: [%s] [%s]", timeout, order)
+    } else if hash != "9q8yy" || box == nil || box.MinLat > 37.77 || box.MaxLat < 37.77 {
+        t.Fatalf("Geohash not correct: [%s] %v", hash, box)
+    } else if center.Lat != 47.5 || center.Lng != -122.3 {
+        t.Fatalf("Coordinates not correct: %v", center)
+    } else if len(boxes) != 1 || boxes[0].MinLng != -123 || boxes[0].MaxLat != 48 {
+        t.Fatalf("Bounding-box not correct: %v", boxes)
+    }
+}
+
+func TestParameterExtractor_WithDefault(t *testing.T) {
+    r := newBindingTestRequest("GET", "/?limit=", "", nil)
+
+    pe := NewParameterExtractor(r)
+
+    defaultCenter := &appengine.GeoPoint{ Lat: 1, Lng: 2 }
+    defaultBoxes := []*geohash.Box { &geohash.Box{ MinLng: 1, MaxLng: 2 } }
+
+    // Empty is the same as missing.
+    limit := pe.IntWithDefault(ParameterSourceQuery, "limit", 20)
+    order := pe.EnumWithDefault(ParameterSourceQuery, "order", []string { "asc", "desc" }, "desc")
+    hash, box := pe.GeohashWithDefault(ParameterSourceQuery, "area", "9Q8YY")
+    center := pe.LatLngWithDefault(ParameterSourceQuery, "center", defaultCenter)
+    boxes := pe.BoundingBoxWithDefault(ParameterSourceQuery, "bbox", defaultBoxes)
+
+    if err := pe.Err(); err != nil {
+        t.Fatalf("Defaults should not be problems: %s", err)
+    }
+
+    if limit != 20 || order != "desc" {
+        t.Fatalf("Scalar defaults not correct: (%d) [%s]", limit, order)
+    } else if hash != "9q8yy" || box == nil {
+        t.Fatalf("Geohash default not correct: [%s] %v", hash, box)
+    } else if center != defaultCenter {
+        t.Fatalf("Coordinates default not correct: %v", center)
+    } else if len(boxes) != 1 || boxes[0] != defaultBoxes[0] {
+        t.Fatalf("Bounding-box default not correct: %v", boxes)
+    }
+
+    // A value that's present is still validated.
+    r = newBindingTestRequest("GET", "/?area=a&center=91,0&bbox=1,2,3", "", nil)
+
+    pe = NewParameterExtractor(r)
+
+    pe.GeohashWithDefault(ParameterSourceQuery, "area", "9q8yy")
+    pe.LatLngWithDefault(ParameterSourceQuery, "center", defaultCenter)
+    pe.BoundingBoxWithDefault(ParameterSourceQuery, "bbox", defaultBoxes)
+
+    if problems := getBindingTestProblems(t, pe.Err()); len(problems) != 3 {
+        t.Fatalf("Problems not correct: %v", problems)
+    }
+}
+
+func TestParameterExtractor_Problems(t *testing.T) {
+    r := newBindingTestRequest("GET", "/?limit=x&order=up", "", nil)
+
+    pe := NewParameterExtractor(r)
+
+    pe.Int(ParameterSourceQuery, "limit")
+    pe.Enum(ParameterSourceQuery, "order", []string { "asc", "desc" })
+    pe.String(ParameterSourceHeader, "X-Tenant")
+    pe.LatLng(ParameterSourceQuery, "center")
+
+    err := pe.Err()
+
+    problems := getBindingTestProblems(t, err)
+
+    expected := []string {
+        "query [limit]: integer not valid",
+        "query [order]: must be one of: asc, desc",
+        "header [X-Tenant]: missing",
+        "query [center]: missing",
+    }
+
+    if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+        t.Fatalf("Problems not correct:\n%s", strings.Join(problems, "\n"))
+    } else if HttpStatusForError(err) != http.StatusBadRequest {
+        t.Fatalf("Status not correct: (%d)", HttpStatusForError(err))
+    }
+}
+
+func TestParameterExtractor_AddProblem_Truncated(t *testing.T) {
+    pe := NewParameterExtractor(newBindingTestRequest("GET", "/", "", nil))
+
+    long := strings.Repeat("a", ParameterProblemMaxValueLength - 1) + "é" + strings.Repeat("b", 10)
+    pe.AddProblem(ParameterSourceHeader, "X-Long", long, "not valid")
+    pe.AddProblem(ParameterSourceHeader, "X-Short", "short", "not valid")
+
+    problems := pe.Err().(*ParameterError).Problems
+
+    // The multibyte character straddles the limit and is dropped whole.
+    if problems[0].Value != strings.Repeat("a", ParameterProblemMaxValueLength - 1) + "..." {
+        t.Fatalf("Long value not truncated: [%s]", problems[0].Value)
+    } else if problems[1].Value != "short" {
+        t.Fatalf("Short value should not be truncated: [%s]", problems[1].Value)
+    }
+}
+
+func TestParameterExtractor_SourceNotValid(t *testing.T) {
+    pe := NewParameterExtractor(newBindingTestRequest("GET", "/?limit=5", "", nil))
+
+    var err error
+
+    func() {
+        defer func() {
+            if state := recover(); state != nil {
+                err = ricommon.DistillError(state)
+            }
+        }()
+
+        pe.Int("cookie", "limit")
+    }()
+
+    if err == nil {
+        t.Fatalf("Expected panic for an invalid source.")
+    } else if HttpStatusForError(err) != http.StatusInternalServerError {
+        t.Fatalf("An invalid source should be a server error: [%s]", err)
+    } else if pe.Err() != nil {
+        t.Fatalf("An invalid source should not be a parameter problem: %s", pe.Err())
+    }
+
+    // The recovery handler responds with a 500.
+    handler := func(w http.ResponseWriter, r *http.Request) {
+        NewParameterExtractor(r).String(ParameterSourceBody, "name")
+    }
+
+    w := httptest.NewRecorder()
+    NewRecoveryHandler(http.HandlerFunc(handler), nil).ServeHTTP(w, newBindingTestRequest("GET", "/", "", nil))
+
+    if w.Code != http.StatusInternalServerError {
+        t.Fatalf("Status not correct: (%d)", w.Code)
+    }
+}
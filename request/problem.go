@@ -0,0 +1,178 @@
+package rirequest
+
+import (
+    "errors"
+
+    "encoding/json"
+    "net/http"
+
+    goerrors "github.com/go-errors/errors"
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+    "github.com/randomingenuity/go-ri/data"
+)
+
+// Constants
+const (
+    // The header that carries the request ID in both directions.
+    RequestIdHeader = "X-Request-Id"
+
+    // The problem type when there's nothing more specific (RFC 7807).
+    ProblemTypeDefault = "about:blank"
+)
+
+// Other
+var (
+    problemLogger = log.NewLogger("ri.request.problem")
+
+    // Used by the WriteResponse methods of the error types in this package.
+    DefaultProblemWriter = NewProblemWriter(false)
+)
+
+// Problem An RFC 7807 problem description. Extensions are written alongside
+// the standard members.
+type Problem struct {
+    Type string
+    Title string
+    Status int
+    Detail string
+    Instance string
+
+    RequestId string
+
+    // Only written in debug mode.
+    Stack string
+
+    Extensions map[string]interface{}
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+    body := make(map[string]interface{})
+    for name, value := range p.Extensions {
+        body[name] = value
+    }
+
+    body["type"] = p.Type
+    body["title"] = p.Title
+    body["status"] = p.Status
+
+    if p.Detail != "" {
+        body["detail"] = p.Detail
+    }
+
+    if p.Instance != "" {
+        body["instance"] = p.Instance
+    }
+
+    if p.RequestId != "" {
+        body["requestId"] = p.RequestId
+    }
+
+    if p.Stack != "" {
+        body["stack"] = p.Stack
+    }
+
+    return json.Marshal(body)
+}
+
+// HttpStatusForError Return the status for the error: 404 for ErrNotFound,
+// 409 for ErrAlreadyExists, 413 and 415 for rejected uploads, 400 for
+// ErrArgumentError (including parameter errors), 406 for negotiation
+// failures, and 500 for anything else. Wrapped errors are unwrapped.
+func HttpStatusForError(err error) int {
+    var nae *NotAcceptableError
+
+    switch {
+    case errors.Is(err, ricommon.ErrNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ricommon.ErrAlreadyExists):
+        return http.StatusConflict
+    case errors.Is(err, ErrUploadTooLarge):
+        return http.StatusRequestEntityTooLarge
+    case errors.Is(err, ErrUploadTypeNotAllowed):
+        return http.StatusUnsupportedMediaType
+    case errors.Is(err, ricommon.ErrArgumentError):
+        return http.StatusBadRequest
+    case errors.As(err, &nae):
+        return http.StatusNotAcceptable
+    }
+
+    return http.StatusInternalServerError
+}
+
+// ProblemWriter Writes errors as problem+json responses. Details of server
+// errors (500s), including stacks, are only written in debug mode.
+type ProblemWriter struct {
+    debug bool
+}
+
+func NewProblemWriter(debug bool) *ProblemWriter {
+    return &ProblemWriter{
+        debug: debug,
+    }
+}
+
+// NewProblem Build the problem for the error. `r` may be nil.
+func (pw *ProblemWriter) NewProblem(r *http.Request, err error) *Problem {
+    status := HttpStatusForError(err)
+
+    p := &Problem{
+        Type: ProblemTypeDefault,
+        Title: http.StatusText(status),
+        Status: status,
+        Extensions: make(map[string]interface{}),
+    }
+
+    if r != nil {
+        p.Instance = r.URL.Path
+        p.RequestId = r.Header.Get(RequestIdHeader)
+    }
+
+    if p.RequestId == "" {
+        p.RequestId = ridata.NewUlid()
+    }
+
+    if status < http.StatusInternalServerError || pw.debug == true {
+        p.Detail = err.Error()
+    }
+
+    var pe *ParameterError
+    var nae *NotAcceptableError
+
+    if errors.As(err, &pe) == true {
+        p.Extensions["problems"] = pe.Problems
+    } else if errors.As(err, &nae) == true {
+        p.Extensions["acceptable"] = nae.Acceptable
+    }
+
+    if pw.debug == true {
+        var stacked *goerrors.Error
+        if errors.As(err, &stacked) == true {
+            p.Stack = stacked.ErrorStack()
+        }
+    }
+
+    return p
+}
+
+// Write Write the error as a problem+json response and return the problem.
+// `r` may be nil.
+func (pw *ProblemWriter) Write(w http.ResponseWriter, r *http.Request, err error) *Problem {
+    p := pw.NewProblem(r, err)
+
+    w.Header().Set("Content-Type", CtProblemJson)
+    w.Header().Set(RequestIdHeader, p.RequestId)
+    w.WriteHeader(p.Status)
+
+    if err := json.NewEncoder(w).Encode(p); err != nil {
+        problemLogger.Warningf(nil, "Could not write problem response: [%s]", err)
+    }
+
+    return p
+}
+
+// WriteProblem Write the error with DefaultProblemWriter.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) *Problem {
+    return DefaultProblemWriter.Write(w, r, err)
+}
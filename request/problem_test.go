@@ -0,0 +1,157 @@
+package rirequest
+
+import (
+    "fmt"
+    "testing"
+
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// writeProblemTest Write the error and return the decoded body.
+func writeProblemTest(t *testing.T, pw *ProblemWriter, r *http.Request, err error) (w *httptest.ResponseRecorder, body map[string]interface{}) {
+    w = httptest.NewRecorder()
+    pw.Write(w, r, err)
+
+    if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+        t.Fatalf("Problem not JSON: %s", err)
+    }
+
+    return w, body
+}
+
+func TestProblemWriter_ServerError(t *testing.T) {
+    err := log.Wrap(fmt.Errorf("database password [hunter2] not valid"))
+
+    r := httptest.NewRequest("GET", "/items/5", nil)
+    r.Header.Set(RequestIdHeader, "request-1")
+
+    w, body := writeProblemTest(t, NewProblemWriter(false), r, err)
+
+    if w.Code != http.StatusInternalServerError || body["status"] != float64(http.StatusInternalServerError) {
+        t.Fatalf("Status not correct: (%d) %v", w.Code, body["status"])
+    } else if w.Header().Get("Content-Type") != CtProblemJson {
+        t.Fatalf("Content-type not correct: [%s]", w.Header().Get("Content-Type"))
+    } else if body["type"] != ProblemTypeDefault || body["title"] != "Internal Server Error" || body["instance"] != "/items/5" {
+        t.Fatalf("Problem not correct: %v", body)
+    } else if body["requestId"] != "request-1" || w.Header().Get(RequestIdHeader) != "request-1" {
+        t.Fatalf("Request ID not correct: %v", body["requestId"])
+    }
+
+    // Details of server errors are hidden.
+    if _, found := body["detail"]; found == true {
+        t.Fatalf("Detail should not be written: %v", body["detail"])
+    } else if _, found := body["stack"]; found == true {
+        t.Fatalf("Stack should not be written.")
+    }
+
+    // Unless debugging.
+    _, body = writeProblemTest(t, NewProblemWriter(true), r, err)
+
+    if body["detail"] != err.Error() {
+        t.Fatalf("Detail not correct: %v", body["detail"])
+    } else if stack, ok := body["stack"].(string); ok == false || stack == "" {
+        t.Fatalf("Stack not written: %v", body["stack"])
+    }
+}
+
+func TestProblemWriter_ClientError(t *testing.T) {
+    cases := []struct {
+        err error
+        status int
+        title string
+    }{
+        { fmt.Errorf("%w: item [5]", ricommon.ErrNotFound), http.StatusNotFound, "Not Found" },
+        { ricommon.ErrAlreadyExists, http.StatusConflict, "Conflict" },
+        { fmt.Errorf("%w: limit not valid", ricommon.ErrArgumentError), http.StatusBadRequest, "Bad Request" },
+        { ErrUploadTooLarge, http.StatusRequestEntityTooLarge, "Request Entity Too Large" },
+        { ErrUploadTypeNotAllowed, http.StatusUnsupportedMediaType, "Unsupported Media Type" },
+    }
+
+    for _, c := range cases {
+        // No request: there's no instance and a request ID is generated.
+        w, body := writeProblemTest(t, NewProblemWriter(false), nil, c.err)
+
+        if w.Code != c.status || body["title"] != c.title {
+            t.Fatalf("Problem for [%s] not correct: (%d) %v", c.err, w.Code, body["title"])
+        } else if body["detail"] != c.err.Error() {
+            t.Fatalf("Detail for [%s] not correct: %v", c.err, body["detail"])
+        } else if _, found := body["instance"]; found == true {
+            t.Fatalf("Instance should not be written without a request.")
+        } else if requestId, ok := body["requestId"].(string); ok == false || requestId == "" || w.Header().Get(RequestIdHeader) != requestId {
+            t.Fatalf("Request ID not generated: %v", body["requestId"])
+        }
+    }
+}
+
+func TestProblemWriter_Extensions(t *testing.T) {
+    pe := &ParameterError{
+        Problems: []ParameterProblem {
+            { Source: ParameterSourceQuery, Name: "limit", Value: "x", Message: "integer not valid" },
+        },
+    }
+
+    w := httptest.NewRecorder()
+    pe.WriteResponse(w)
+
+    var body map[string]interface{}
+    if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+        t.Fatalf("Problem not JSON: %s", err)
+    }
+
+    problems, ok := body["problems"].([]interface{})
+    if w.Code != http.StatusBadRequest {
+        t.Fatalf("Status not correct: (%d)", w.Code)
+    } else if ok == false || len(problems) != 1 {
+        t.Fatalf("Problems not written: %v", body["problems"])
+    } else if problem := problems[0].(map[string]interface{}); problem["name"] != "limit" || problem["value"] != "x" {
+        t.Fatalf("Problem not correct: %v", problem)
+    }
+
+    nae := &NotAcceptableError{
+        Acceptable: []string { CtApplicationJson },
+    }
+
+    w = httptest.NewRecorder()
+    nae.WriteResponse(w)
+
+    body = nil
+    if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+        t.Fatalf("Problem not JSON: %s", err)
+    }
+
+    if w.Code != http.StatusNotAcceptable {
+        t.Fatalf("Status not correct: (%d)", w.Code)
+    } else if acceptable, ok := body["acceptable"].([]interface{}); ok == false || len(acceptable) != 1 || acceptable[0] != CtApplicationJson {
+        t.Fatalf("Acceptable types not written: %v", body["acceptable"])
+    }
+}
+
+func TestProblem_MarshalJSON(t *testing.T) {
+    p := &Problem{
+        Type: ProblemTypeDefault,
+        Title: "Bad Request",
+        Status: http.StatusBadRequest,
+        Extensions: map[string]interface{} {
+            "retry": true,
+
+            // Standard members win.
+            "status": 200,
+        },
+    }
+
+    encoded, err := json.Marshal(p)
+    if err != nil {
+        t.Fatalf("Problem not encoded: %s", err)
+    }
+
+    expected := `{"retry":true,"status":400,"title":"Bad Request","type":"about:blank"}`
+    if string(encoded) != expected {
+        t.Fatalf("Problem not encoded correctly: %s", encoded)
+    }
+}
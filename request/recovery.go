@@ -0,0 +1,145 @@
+package rirequest
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net"
+
+    "net/http"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Other
+var (
+    recoveryLogger = log.NewLogger("ri.request.recovery")
+)
+
+// RecoveryHandler Recovers panics from the wrapped handler (e.g. from
+// log.Panic) and writes them as problem responses with the status from
+// HttpStatusForError. Server errors are logged with their stack and client
+// errors as warnings. If the handler had already started the response then
+// the panic is only logged.
+//
+// http.ErrAbortHandler is re-raised so that the server can abort the
+// connection.
+type RecoveryHandler struct {
+    next http.Handler
+    pw *ProblemWriter
+}
+
+// NewRecoveryHandler Wrap the handler. `pw` may be nil for
+// DefaultProblemWriter.
+func NewRecoveryHandler(next http.Handler, pw *ProblemWriter) *RecoveryHandler {
+    if pw == nil {
+        pw = DefaultProblemWriter
+    }
+
+    return &RecoveryHandler{
+        next: next,
+        pw: pw,
+    }
+}
+
+// RecoveryMiddleware Return a middleware that wraps handlers with
+// NewRecoveryHandler (can be given to mux.Router.Use).
+func RecoveryMiddleware(pw *ProblemWriter) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return NewRecoveryHandler(next, pw)
+    }
+}
+
+func (rh *RecoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    tw := &trackingResponseWriter{
+        ResponseWriter: w,
+    }
+
+    defer func() {
+        state := recover()
+        if state == nil {
+            return
+        } else if state == http.ErrAbortHandler {
+            panic(state)
+        }
+
+        err := ricommon.DistillError(state)
+        status := HttpStatusForError(err)
+
+        if status >= http.StatusInternalServerError {
+            recoveryLogger.Errorf(r.Context(), err, "Request failed: [%s] [%s]", r.Method, r.URL.Path)
+        } else {
+            recoveryLogger.Warningf(r.Context(), "Request failed (%d): [%s] [%s]: %s", status, r.Method, r.URL.Path, err)
+        }
+
+        if tw.wroteHeader == true {
+            recoveryLogger.Warningf(r.Context(), "Response already started. Could not write error: [%s] [%s]", r.Method, r.URL.Path)
+            return
+        }
+
+        rh.pw.Write(w, r, err)
+    }()
+
+    rh.next.ServeHTTP(tw, r)
+}
+
+// trackingResponseWriter Records whether the response has been started. The
+// optional interfaces of the wrapped writer are passed through, and Unwrap
+// supports http.ResponseController.
+type trackingResponseWriter struct {
+    http.ResponseWriter
+
+    wroteHeader bool
+}
+
+func (trw *trackingResponseWriter) WriteHeader(statusCode int) {
+    trw.wroteHeader = true
+    trw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (trw *trackingResponseWriter) Write(data []byte) (int, error) {
+    trw.wroteHeader = true
+    return trw.ResponseWriter.Write(data)
+}
+
+// Flush Support streaming handlers.
+func (trw *trackingResponseWriter) Flush() {
+    if flusher, ok := trw.ResponseWriter.(http.Flusher); ok == true {
+        trw.wroteHeader = true
+        flusher.Flush()
+    }
+}
+
+// Hijack Support handlers that take over the connection (e.g. websockets).
+func (trw *trackingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    hijacker, ok := trw.ResponseWriter.(http.Hijacker)
+    if ok == false {
+        return nil, nil, fmt.Errorf("response writer does not support hijacking")
+    }
+
+    conn, rw, err := hijacker.Hijack()
+    if err != nil {
+        return nil, nil, err
+    }
+
+    trw.wroteHeader = true
+    return conn, rw, nil
+}
+
+// ReadFrom Keep the sendfile optimization for io.Copy.
+func (trw *trackingResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+    trw.wroteHeader = true
+
+    if rf, ok := trw.ResponseWriter.(io.ReaderFrom); ok == true {
+        return rf.ReadFrom(r)
+    }
+
+    return io.Copy(trw.ResponseWriter, r)
+}
+
+// Unwrap Return the wrapped writer.
+func (trw *trackingResponseWriter) Unwrap() http.ResponseWriter {
+    return trw.ResponseWriter
+}
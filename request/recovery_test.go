@@ -0,0 +1,148 @@
+package rirequest
+
+import (
+    "bufio"
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+
+    "net/http"
+    "net/http/httptest"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// serveRecoveryTest Serve a request to a handler that panics with `state`.
+func serveRecoveryTest(state interface{}) *httptest.ResponseRecorder {
+    handler := func(w http.ResponseWriter, r *http.Request) {
+        log.Panic(state)
+    }
+
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest("GET", "/items/5", nil)
+
+    NewRecoveryHandler(http.HandlerFunc(handler), nil).ServeHTTP(w, r)
+
+    return w
+}
+
+func TestRecoveryHandler(t *testing.T) {
+    cases := []struct {
+        state interface{}
+        status int
+    }{
+        { fmt.Errorf("%w: limit not valid", ricommon.ErrArgumentError), http.StatusBadRequest },
+        { ricommon.ErrNotFound, http.StatusNotFound },
+        { fmt.Errorf("database unavailable"), http.StatusInternalServerError },
+
+        // Not an error.
+        { "unexpected", http.StatusInternalServerError },
+    }
+
+    for _, c := range cases {
+        w := serveRecoveryTest(c.state)
+
+        if w.Code != c.status {
+            t.Fatalf("Status for [%v] not correct: (%d)", c.state, w.Code)
+        } else if w.Header().Get("Content-Type") != CtProblemJson {
+            t.Fatalf("Problem not written for [%v]: [%s]", c.state, w.Header().Get("Content-Type"))
+        }
+    }
+}
+
+func TestRecoveryHandler_AlreadyStarted(t *testing.T) {
+    handler := func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusAccepted)
+        w.Write([]byte("partial"))
+
+        log.Panic(fmt.Errorf("failed midway"))
+    }
+
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest("GET", "/", nil)
+
+    NewRecoveryHandler(http.HandlerFunc(handler), nil).ServeHTTP(w, r)
+
+    // Only logged.
+    if w.Code != http.StatusAccepted {
+        t.Fatalf("Status should not have been changed: (%d)", w.Code)
+    } else if body := w.Body.String(); body != "partial" {
+        t.Fatalf("Body should not have been changed: [%s]", body)
+    }
+}
+
+func TestRecoveryHandler_Abort(t *testing.T) {
+    defer func() {
+        if state := recover(); state != http.ErrAbortHandler {
+            t.Fatalf("Abort not re-raised: [%v]", state)
+        }
+    }()
+
+    handler := func(w http.ResponseWriter, r *http.Request) {
+        panic(http.ErrAbortHandler)
+    }
+
+    w := httptest.NewRecorder()
+    r := httptest.NewRequest("GET", "/", nil)
+
+    NewRecoveryHandler(http.HandlerFunc(handler), nil).ServeHTTP(w, r)
+}
+
+type hijackTestResponseWriter struct {
+    *httptest.ResponseRecorder
+
+    hijacked bool
+}
+
+func (htrw *hijackTestResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    htrw.hijacked = true
+    return nil, nil, nil
+}
+
+func TestTrackingResponseWriter_Passthrough(t *testing.T) {
+    w := &hijackTestResponseWriter{
+        ResponseRecorder: httptest.NewRecorder(),
+    }
+
+    tw := &trackingResponseWriter{
+        ResponseWriter: w,
+    }
+
+    if tw.Unwrap() != w {
+        t.Fatalf("Wrapped writer not returned.")
+    }
+
+    if _, _, err := tw.Hijack(); err != nil {
+        t.Fatalf("Connection not hijacked: %s", err)
+    } else if w.hijacked != true {
+        t.Fatalf("Hijack not passed through.")
+    } else if tw.wroteHeader != true {
+        t.Fatalf("A hijacked response should be considered started.")
+    }
+
+    // The recorder can't be hijacked.
+    tw = &trackingResponseWriter{
+        ResponseWriter: httptest.NewRecorder(),
+    }
+
+    if _, _, err := tw.Hijack(); err == nil {
+        t.Fatalf("Expected error for a writer that can't be hijacked.")
+    } else if tw.wroteHeader == true {
+        t.Fatalf("A failed hijack should not start the response.")
+    }
+
+    recorder := httptest.NewRecorder()
+
+    tw = &trackingResponseWriter{
+        ResponseWriter: recorder,
+    }
+
+    if n, err := tw.ReadFrom(strings.NewReader("body")); err != nil || n != 4 {
+        t.Fatalf("Body not copied: (%d) %v", n, err)
+    } else if recorder.Body.String() != "body" || tw.wroteHeader != true {
+        t.Fatalf("Copied body not correct: [%s]", recorder.Body.String())
+    }
+}
@@ -0,0 +1,339 @@
+package rirequest
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "os"
+
+    "mime/multipart"
+    "net/http"
+    "net/url"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+// Upload limits
+const (
+    DefaultUploadMaxFileSize = 32 * 1024 * 1024
+    DefaultUploadMaxFiles = 1
+
+    // The total size of the non-file fields.
+    uploadMaxFieldsSize = 1024 * 1024
+
+    // How much of each file is used to identify its format.
+    uploadSniffSize = 512
+
+    // Room in the body for the multipart boundaries and part headers.
+    uploadBodySlack = 64 * 1024
+)
+
+// Errors
+var (
+    // A file or the form fields exceeded their limit. An argument error.
+    ErrUploadTooLarge = fmt.Errorf("%w: upload too large", ricommon.ErrArgumentError)
+
+    // A file isn't one of the allowed image formats. An argument error.
+    ErrUploadTypeNotAllowed = fmt.Errorf("%w: upload type not allowed", ricommon.ErrArgumentError)
+)
+
+// Other
+var (
+    uploadLogger = log.NewLogger("ri.request.upload")
+
+    // The content-types allowed when UploadOptions doesn't give any.
+    DefaultUploadContentTypes = []string {
+        CtImageJpeg,
+        CtImageTiff,
+        CtImagePng,
+        CtImageHeic,
+        CtImageWebp,
+    }
+)
+
+// UploadOptions Configures ReadImageUploads. Zero values mean the defaults.
+type UploadOptions struct {
+    // Only read files from this form field. Files in other fields are
+    // skipped.
+    FieldName string
+
+    MaxFileSize int64
+    MaxFiles int
+
+    // The Ct* content-types to accept. HEIC and HEIF are interchangeable.
+    AllowedContentTypes []string
+
+    // One of the ricommon.HashAlgorithm* names.
+    HashAlgorithm string
+
+    // Where to spool files (the system default if empty).
+    TempPath string
+}
+
+// withDefaults Fill in the defaults and check the limits. Bad options are a
+// bug in the caller rather than in the request, so the error isn't an
+// argument error.
+func (uo UploadOptions) withDefaults() (UploadOptions, error) {
+    if uo.MaxFileSize == 0 {
+        uo.MaxFileSize = DefaultUploadMaxFileSize
+    }
+
+    if uo.MaxFiles == 0 {
+        uo.MaxFiles = DefaultUploadMaxFiles
+    }
+
+    if uo.AllowedContentTypes == nil {
+        uo.AllowedContentTypes = DefaultUploadContentTypes
+    }
+
+    if uo.HashAlgorithm == "" {
+        uo.HashAlgorithm = ricommon.DefaultHashAlgorithm
+    }
+
+    if uo.MaxFileSize < 0 || uo.MaxFiles < 0 {
+        return uo, fmt.Errorf("upload limits can not be negative: (%d) (%d)", uo.MaxFileSize, uo.MaxFiles)
+    }
+
+    // The body cap (see ReadImageUploads) must not overflow.
+    if uo.MaxFileSize > (math.MaxInt64 - uploadMaxFieldsSize - uploadBodySlack) / int64(uo.MaxFiles) {
+        return uo, fmt.Errorf("upload limits too large: (%d) files of (%d) bytes", uo.MaxFiles, uo.MaxFileSize)
+    }
+
+    return uo, nil
+}
+
+// ImageUpload Describes an uploaded image that was spooled to a temporary
+// file. The caller owns the file and must call Remove() when done with it.
+type ImageUpload struct {
+    FieldName string
+    Filename string
+
+    // DeclaredContentType What the client said the file was. Not trusted.
+    DeclaredContentType string
+
+    // ContentType The content-type sniffed from the data.
+    ContentType string
+
+    // Format One of the ricommon.ImageFormat* names.
+    Format string
+
+    Size int64
+    Digest string
+
+    // Metadata The image's EXIF/XMP metadata. Nil if it couldn't be read.
+    Metadata *ricommon.ImageMetadata
+
+    Filepath string
+}
+
+// Open Open the spooled file.
+func (iu *ImageUpload) Open() (f *os.File, err error) {
+    return os.Open(iu.Filepath)
+}
+
+// Remove Delete the spooled file.
+func (iu *ImageUpload) Remove() error {
+    return os.Remove(iu.Filepath)
+}
+
+// ReadImageUploads Stream the parts of a multipart request. Each file is
+// sniffed, checked against the allowed content-types, and then spooled to a
+// temporary file while being hashed and having its metadata extracted.
+// Metadata extraction buffers the file in memory (see
+// ricommon.NewImageMetadataWithReader), so MaxFileSize also bounds memory.
+// Files larger than ricommon.ImageMetadataMaxSize are spooled without
+// metadata.
+// The other form fields are returned as `fields`.
+//
+// The body is capped at MaxFiles files of MaxFileSize plus the fields, and
+// files in other form fields are skipped but still held to MaxFileSize.
+// Oversized files and bodies fail with ErrUploadTooLarge and unrecognized or
+// disallowed ones with ErrUploadTypeNotAllowed. Too many files is an argument
+// error. No files are left behind on error.
+func ReadImageUploads(r *http.Request, options UploadOptions) (uploads []*ImageUpload, fields url.Values, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            for _, iu := range uploads {
+                iu.Remove()
+            }
+
+            uploads = nil
+            fields = nil
+            err = ricommon.DistillError(state)
+        }
+    }()
+
+    options, err = options.withDefaults()
+    log.PanicIf(err)
+
+    r.Body = http.MaxBytesReader(nil, r.Body, int64(options.MaxFiles) * options.MaxFileSize + uploadMaxFieldsSize + uploadBodySlack)
+
+    mr, err := r.MultipartReader()
+    if err != nil {
+        log.Panic(fmt.Errorf("%w: request not multipart: %s", ricommon.ErrArgumentError, err))
+    }
+
+    uploads = make([]*ImageUpload, 0)
+    fields = make(url.Values)
+    fieldsSize := int64(0)
+
+    for {
+        part, err := mr.NextPart()
+        if err == io.EOF {
+            break
+        } else if err = uploadBodyError(err); errors.Is(err, ErrUploadTooLarge) == true {
+            log.Panic(err)
+        } else if err != nil {
+            log.Panic(fmt.Errorf("%w: multipart body not valid: %s", ricommon.ErrArgumentError, err))
+        }
+
+        if part.FileName() == "" {
+            value, err := ioutil.ReadAll(io.LimitReader(part, uploadMaxFieldsSize - fieldsSize + 1))
+            log.PanicIf(uploadBodyError(err))
+
+            fieldsSize += int64(len(value))
+            if fieldsSize > uploadMaxFieldsSize {
+                log.Panic(fmt.Errorf("%w: form fields exceed (%d) bytes", ErrUploadTooLarge, uploadMaxFieldsSize))
+            }
+
+            fields.Add(part.FormName(), string(value))
+            continue
+        }
+
+        if options.FieldName != "" && part.FormName() != options.FieldName {
+            n, err := io.CopyN(ioutil.Discard, part, options.MaxFileSize + 1)
+            if err != nil && err != io.EOF {
+                log.Panic(uploadBodyError(err))
+            } else if n > options.MaxFileSize {
+                log.Panic(fmt.Errorf("%w: [%s] exceeds (%d) bytes", ErrUploadTooLarge, part.FileName(), options.MaxFileSize))
+            }
+
+            continue
+        }
+
+        if len(uploads) == options.MaxFiles {
+            log.Panic(fmt.Errorf("%w: more than (%d) files", ricommon.ErrArgumentError, options.MaxFiles))
+        }
+
+        iu := spoolImageUpload(part, options)
+        uploads = append(uploads, iu)
+    }
+
+    return uploads, fields, nil
+}
+
+// spoolImageUpload Sniff the part and copy it to a temporary file, the hash,
+// and the metadata reader at once.
+func spoolImageUpload(part *multipart.Part, options UploadOptions) (iu *ImageUpload) {
+    iu = &ImageUpload{
+        FieldName: part.FormName(),
+        Filename: part.FileName(),
+        DeclaredContentType: part.Header.Get("Content-Type"),
+    }
+
+    header := make([]byte, uploadSniffSize)
+
+    n, err := io.ReadFull(part, header)
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+        log.Panic(uploadBodyError(err))
+    }
+
+    header = header[:n]
+
+    iu.ContentType = SniffImageMimetype(header)
+    iu.Format = ImageFormatMimetypeMapping[iu.ContentType]
+
+    if iu.Format == "" {
+        log.Panic(fmt.Errorf("%w: [%s] is not a recognized image", ErrUploadTypeNotAllowed, iu.Filename))
+    } else if isUploadFormatAllowed(iu.Format, options.AllowedContentTypes) == false {
+        log.Panic(fmt.Errorf("%w: [%s] is not an allowed image (%s)", ErrUploadTypeNotAllowed, iu.Filename, iu.ContentType))
+    }
+
+    f, err := ioutil.TempFile(options.TempPath, "upload-")
+    log.PanicIf(err)
+
+    iu.Filepath = f.Name()
+
+    spooled := false
+    defer func() {
+        if spooled == false {
+            f.Close()
+            os.Remove(iu.Filepath)
+        }
+    }()
+
+    dw, err := ricommon.NewDigestWriter(options.HashAlgorithm)
+    log.PanicIf(err)
+
+    pr, pw := io.Pipe()
+    metadataDone := make(chan struct{})
+
+    go func() {
+        defer close(metadataDone)
+
+        // Keep the copy moving if the reader stops early. Nothing more than
+        // what the copy below is limited to can be left.
+        defer io.CopyN(ioutil.Discard, pr, options.MaxFileSize + 1)
+
+        im, err := ricommon.NewImageMetadataWithReader(pr)
+        if err != nil {
+            uploadLogger.Warningf(nil, "Could not read metadata of upload [%s]: [%s]", iu.Filename, err)
+            return
+        }
+
+        iu.Metadata = im
+    }()
+
+    data := io.LimitReader(io.MultiReader(bytes.NewReader(header), part), options.MaxFileSize + 1)
+
+    iu.Size, err = io.Copy(io.MultiWriter(f, dw, pw), data)
+    if err != nil {
+        err = uploadBodyError(err)
+    } else if iu.Size > options.MaxFileSize {
+        err = fmt.Errorf("%w: [%s] exceeds (%d) bytes", ErrUploadTooLarge, iu.Filename, options.MaxFileSize)
+    }
+
+    if err != nil {
+        pw.CloseWithError(err)
+        <-metadataDone
+
+        log.Panic(err)
+    }
+
+    pw.Close()
+    <-metadataDone
+
+    err = f.Close()
+    log.PanicIf(err)
+
+    iu.Digest = dw.Digest()
+
+    spooled = true
+    return iu
+}
+
+// uploadBodyError Return ErrUploadTooLarge if the body exceeded its cap.
+// Other errors are returned unchanged.
+func uploadBodyError(err error) error {
+    var mbe *http.MaxBytesError
+    if errors.As(err, &mbe) == true {
+        return fmt.Errorf("%w: body exceeds (%d) bytes", ErrUploadTooLarge, mbe.Limit)
+    }
+
+    return err
+}
+
+func isUploadFormatAllowed(format string, allowed []string) bool {
+    for _, contentType := range allowed {
+        if ImageFormatMimetypeMapping[contentType] == format {
+            return true
+        }
+    }
+
+    return false
+}
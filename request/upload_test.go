@@ -0,0 +1,289 @@
+package rirequest
+
+import (
+    "bytes"
+    "math"
+    "os"
+    "strings"
+    "testing"
+
+    "crypto/sha256"
+    "io/ioutil"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "net/textproto"
+    "path/filepath"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/randomingenuity/go-ri/common"
+)
+
+type uploadTestPart struct {
+    fieldName string
+    filename string
+    contentType string
+    data []byte
+}
+
+func getUploadTestImage(t *testing.T, filename string) []byte {
+    raw, err := ioutil.ReadFile(filepath.Join("..", "common", "testdata", filename))
+    if err != nil {
+        t.Fatalf("Fixture not read: %s", err)
+    }
+
+    return raw
+}
+
+// newUploadTestRequest Return a multipart request with the parts. Parts
+// without a filename are plain fields.
+func newUploadTestRequest(t *testing.T, parts []uploadTestPart) *http.Request {
+    b := new(bytes.Buffer)
+    mw := multipart.NewWriter(b)
+
+    for _, part := range parts {
+        if part.filename == "" {
+            if err := mw.WriteField(part.fieldName, string(part.data)); err != nil {
+                t.Fatalf("Field not written: %s", err)
+            }
+
+            continue
+        }
+
+        header := make(textproto.MIMEHeader)
+        header.Set("Content-Disposition", `form-data; name="` + part.fieldName + `"; filename="` + part.filename + `"`)
+        header.Set("Content-Type", part.contentType)
+
+        w, err := mw.CreatePart(header)
+        if err != nil {
+            t.Fatalf("Part not created: %s", err)
+        } else if _, err := w.Write(part.data); err != nil {
+            t.Fatalf("Part not written: %s", err)
+        }
+    }
+
+    if err := mw.Close(); err != nil {
+        t.Fatalf("Body not finished: %s", err)
+    }
+
+    r := httptest.NewRequest("POST", "/upload", b)
+    r.Header.Set("Content-Type", mw.FormDataContentType())
+
+    return r
+}
+
+// newUploadTestPath Return an empty directory to spool to.
+func newUploadTestPath(t *testing.T) string {
+    path, err := ioutil.TempDir("", "upload_test")
+    if err != nil {
+        t.Fatalf("Temporary directory not created: %s", err)
+    }
+
+    t.Cleanup(func() {
+        os.RemoveAll(path)
+    })
+
+    return path
+}
+
+func assertUploadTestPathEmpty(t *testing.T, path string) {
+    files, err := ioutil.ReadDir(path)
+    if err != nil {
+        t.Fatalf("Directory not read: %s", err)
+    } else if len(files) != 0 {
+        t.Fatalf("Files left behind: (%d)", len(files))
+    }
+}
+
+func TestReadImageUploads(t *testing.T) {
+    jpeg := getUploadTestImage(t, "exif.jpg")
+    path := newUploadTestPath(t)
+
+    parts := []uploadTestPart {
+        { fieldName: "caption", data: []byte("a photo") },
+
+        // The declared type isn't trusted.
+        { fieldName: "image", filename: "photo.png", contentType: CtImagePng, data: jpeg },
+    }
+
+    uploads, fields, err := ReadImageUploads(newUploadTestRequest(t, parts), UploadOptions{ TempPath: path })
+    if err != nil {
+        t.Fatalf("Uploads not read: %s", err)
+    } else if len(uploads) != 1 {
+        t.Fatalf("Upload count not correct: (%d)", len(uploads))
+    } else if fields.Get("caption") != "a photo" {
+        t.Fatalf("Fields not correct: %v", fields)
+    }
+
+    iu := uploads[0]
+    defer iu.Remove()
+
+    sum := sha256.Sum256(jpeg)
+    digest := ricommon.FormatDigest(ricommon.HashAlgorithmSha256, sum[:])
+
+    if iu.FieldName != "image" || iu.Filename != "photo.png" || iu.DeclaredContentType != CtImagePng {
+        t.Fatalf("Part not described correctly: %v", iu)
+    } else if iu.ContentType != CtImageJpeg || iu.Format != ricommon.ImageFormatJpeg {
+        t.Fatalf("Content-type not sniffed: [%s] [%s]", iu.ContentType, iu.Format)
+    } else if iu.Size != int64(len(jpeg)) || iu.Digest != digest {
+        t.Fatalf("Size or digest not correct: (%d) [%s]", iu.Size, iu.Digest)
+    } else if iu.Metadata == nil || iu.Metadata.Exif == nil || math.Abs(iu.Metadata.Exif.Latitude - 47.60625) > 0.000001 {
+        t.Fatalf("Metadata not read: %v", iu.Metadata)
+    } else if filepath.Dir(iu.Filepath) != path {
+        t.Fatalf("Not spooled to the given path: [%s]", iu.Filepath)
+    }
+
+    spooled, err := ioutil.ReadFile(iu.Filepath)
+    if err != nil {
+        t.Fatalf("Spooled file not read: %s", err)
+    } else if bytes.Equal(spooled, jpeg) != true {
+        t.Fatalf("Spooled file not correct.")
+    }
+}
+
+func TestReadImageUploads_FieldName(t *testing.T) {
+    parts := []uploadTestPart {
+        { fieldName: "thumbnail", filename: "a.png", contentType: CtImagePng, data: getUploadTestImage(t, "exif.png") },
+        { fieldName: "image", filename: "b.webp", contentType: CtImageWebp, data: getUploadTestImage(t, "exif.webp") },
+    }
+
+    uploads, _, err := ReadImageUploads(newUploadTestRequest(t, parts), UploadOptions{ FieldName: "image", TempPath: newUploadTestPath(t) })
+    if err != nil {
+        t.Fatalf("Uploads not read: %s", err)
+    } else if len(uploads) != 1 || uploads[0].Filename != "b.webp" {
+        t.Fatalf("Other fields not skipped: (%d)", len(uploads))
+    }
+
+    uploads[0].Remove()
+}
+
+func TestReadImageUploads_TypeNotAllowed(t *testing.T) {
+    cases := []uploadTestPart {
+        // Not an image, whatever it claims.
+        { fieldName: "image", filename: "a.jpg", contentType: CtImageJpeg, data: []byte("not an image") },
+
+        // An image, but not an allowed one.
+        { fieldName: "image", filename: "b.webp", contentType: CtImageWebp, data: getUploadTestImage(t, "exif.webp") },
+    }
+
+    for _, part := range cases {
+        path := newUploadTestPath(t)
+
+        parts := []uploadTestPart {
+            { fieldName: "image", filename: "ok.png", contentType: CtImagePng, data: getUploadTestImage(t, "exif.png") },
+            part,
+        }
+
+        options := UploadOptions{
+            MaxFiles: 2,
+            AllowedContentTypes: []string { CtImagePng, CtImageJpeg },
+            TempPath: path,
+        }
+
+        uploads, _, err := ReadImageUploads(newUploadTestRequest(t, parts), options)
+        if log.Is(err, ErrUploadTypeNotAllowed) != true {
+            t.Fatalf("Error for [%s] not correct: [%v]", part.filename, err)
+        } else if HttpStatusForError(err) != http.StatusUnsupportedMediaType {
+            t.Fatalf("Status for [%s] not correct: (%d)", part.filename, HttpStatusForError(err))
+        } else if uploads != nil {
+            t.Fatalf("Uploads should not be returned on error.")
+        }
+
+        // The first file was removed.
+        assertUploadTestPathEmpty(t, path)
+    }
+}
+
+func TestReadImageUploads_TooLarge(t *testing.T) {
+    jpeg := getUploadTestImage(t, "exif.jpg")
+
+    cases := map[string][]uploadTestPart {
+        "file": []uploadTestPart {
+            { fieldName: "image", filename: "a.png", contentType: CtImagePng, data: getUploadTestImage(t, "exif.png") },
+            { fieldName: "image", filename: "b.jpg", contentType: CtImageJpeg, data: jpeg },
+        },
+        "fields": []uploadTestPart {
+            { fieldName: "caption", data: bytes.Repeat([]byte("a"), uploadMaxFieldsSize + 1) },
+        },
+        "skipped file": []uploadTestPart {
+            { fieldName: "other", filename: "b.jpg", contentType: CtImageJpeg, data: jpeg },
+        },
+    }
+
+    for name, parts := range cases {
+        path := newUploadTestPath(t)
+
+        options := UploadOptions{
+            FieldName: "image",
+            MaxFiles: 2,
+            MaxFileSize: int64(len(jpeg) - 1),
+            TempPath: path,
+        }
+
+        _, _, err := ReadImageUploads(newUploadTestRequest(t, parts), options)
+        if log.Is(err, ErrUploadTooLarge) != true {
+            t.Fatalf("Error for [%s] not correct: [%v]", name, err)
+        } else if HttpStatusForError(err) != http.StatusRequestEntityTooLarge {
+            t.Fatalf("Status for [%s] not correct: (%d)", name, HttpStatusForError(err))
+        }
+
+        assertUploadTestPathEmpty(t, path)
+    }
+}
+
+func TestReadImageUploads_TooManyFiles(t *testing.T) {
+    path := newUploadTestPath(t)
+    png := getUploadTestImage(t, "exif.png")
+
+    parts := []uploadTestPart {
+        { fieldName: "image", filename: "a.png", contentType: CtImagePng, data: png },
+        { fieldName: "image", filename: "b.png", contentType: CtImagePng, data: png },
+    }
+
+    _, _, err := ReadImageUploads(newUploadTestRequest(t, parts), UploadOptions{ TempPath: path })
+    if log.Is(err, ricommon.ErrArgumentError) != true || log.Is(err, ErrUploadTooLarge) == true {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+
+    assertUploadTestPathEmpty(t, path)
+}
+
+func TestReadImageUploads_NotMultipart(t *testing.T) {
+    r := httptest.NewRequest("POST", "/upload", strings.NewReader("{}"))
+    r.Header.Set("Content-Type", "application/json")
+
+    if _, _, err := ReadImageUploads(r, UploadOptions{}); HttpStatusForError(err) != http.StatusBadRequest {
+        t.Fatalf("Error not correct: [%v]", err)
+    }
+}
+
+func TestUploadOptions_WithDefaults(t *testing.T) {
+    options, err := UploadOptions{}.withDefaults()
+    if err != nil {
+        t.Fatalf("Defaults not valid: %s", err)
+    } else if options.MaxFileSize != DefaultUploadMaxFileSize || options.MaxFiles != DefaultUploadMaxFiles || options.HashAlgorithm != ricommon.DefaultHashAlgorithm {
+        t.Fatalf("Defaults not correct: %v", options)
+    }
+
+    notValid := []UploadOptions {
+        UploadOptions{ MaxFileSize: -1 },
+        UploadOptions{ MaxFiles: -1 },
+        UploadOptions{ MaxFiles: 2, MaxFileSize: math.MaxInt64 / 2 },
+    }
+
+    for _, options := range notValid {
+        if _, err := options.withDefaults(); err == nil {
+            t.Fatalf("Expected error for (%d) files of (%d) bytes.", options.MaxFiles, options.MaxFileSize)
+        }
+    }
+
+    // Bad options aren't the client's fault.
+    r := newUploadTestRequest(t, nil)
+
+    if _, _, err := ReadImageUploads(r, UploadOptions{ MaxFiles: -1 }); err == nil {
+        t.Fatalf("Expected error for negative limits.")
+    } else if HttpStatusForError(err) != http.StatusInternalServerError {
+        t.Fatalf("Status not correct: (%d)", HttpStatusForError(err))
+    }
+}